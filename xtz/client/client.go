@@ -2,11 +2,13 @@ package client
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"math/big"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/t-dx/tg-blocksd/internal/config"
 	pool "github.com/t-dx/tg-blocksd/internal/worker"
@@ -93,6 +95,250 @@ func (c *Client) GetRawTransactionHash(ctx context.Context, rawTransaction strin
 	return b58Hash, nil
 }
 
+// blockHashPrefix is the base58check prefix of block hashes.
+var blockHashPrefix = []byte{0x01, 0x34}
+
+// GetOperationExpiry returns the branch of a forged operation, and the last level at which it can be included.
+func (c *Client) GetOperationExpiry(ctx context.Context, rawTransaction string) (*model.OperationExpiry, error) {
+	branch, err := operationBranch(rawTransaction)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := c.client.Block(branch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get branch %q", branch)
+	}
+
+	head, err := c.client.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	level, ttl := uint64(block.Header.Level), uint64(head.Metadata.MaxOperationsTTL)
+	expiryTime, err := c.estimateLevelTime(head, level+ttl, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &model.OperationExpiry{
+		Branch:           branch,
+		BranchLevel:      level,
+		MaxOperationsTTL: ttl,
+		ExpiryLevel:      level + ttl,
+		ExpiryTime:       expiryTime,
+	}, nil
+}
+
+// estimateLevelTime returns the time of a level from the head, with the mean block time over the span blocks before it.
+func (c *Client) estimateLevelTime(head *gotezos.Block, level, span uint64) (time.Time, error) {
+	headLevel, headTime := uint64(head.Header.Level), head.Header.Timestamp.UTC()
+	if span == 0 || span > headLevel {
+		span = headLevel
+	}
+	if span == 0 {
+		return headTime, nil
+	}
+
+	past, err := c.client.Block(int(headLevel - span))
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "could not get block %d", headLevel-span)
+	}
+	blockTime := headTime.Sub(past.Header.Timestamp.UTC()) / time.Duration(span)
+	return headTime.Add(time.Duration(int64(level)-int64(headLevel)) * blockTime), nil
+}
+
+// operationBranch returns the hash of the block a forged operation is branched on, its first 32 bytes.
+func operationBranch(rawTransaction string) (string, error) {
+	data, err := hex.DecodeString(rawTransaction)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < 32 {
+		return "", errors.New("raw transaction is too short to contain a branch")
+	}
+
+	// Base58check function only allow 1 byte prefix, so we append prefix[1] here, and prefix[0] during the call to CheckEncode.
+	branch := append([]byte{blockHashPrefix[1]}, data[:32]...)
+	return base58.CheckEncode(branch, blockHashPrefix[0]), nil
+}
+
+// Tags of the manager operations, and prefixes of the implicit account addresses by tag.
+var (
+	managerOperationTags = map[byte]string{0x6b: "reveal", 0x6c: "transaction", 0x6d: "origination", 0x6e: "delegation"}
+	implicitPrefixes     = map[byte][]byte{0x00: {0x06, 0xa1, 0x9f}, 0x01: {0x06, 0xa1, 0xa1}, 0x02: {0x06, 0xa1, 0xa4}}
+)
+
+// signatureLength is the length of the signature ending a forged and signed operation.
+const signatureLength = 64
+
+// publicKeyLengths are the lengths of the public keys revealed by their tag.
+var publicKeyLengths = map[byte]int{0x00: 32, 0x01: 33, 0x02: 33}
+
+// GetManagerOperation returns the kind, source and counter of the first content of a forged and signed manager
+// operation, and the fee of all its contents. The contents of a batch all have the same source.
+func (c *Client) GetManagerOperation(ctx context.Context, rawTransaction string) (*model.ManagerOperation, error) {
+	data, err := hex.DecodeString(rawTransaction)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 32+signatureLength {
+		return nil, errors.New("raw transaction is too short to contain a manager operation")
+	}
+
+	// The branch, then the contents, then the signature.
+	r := &forgedReader{data: data[32 : len(data)-signatureLength]}
+	var operation *model.ManagerOperation
+	for len(r.data) > 0 {
+		kind, source, fee, counter, err := r.managerContent()
+		if err != nil {
+			return nil, err
+		}
+		if operation == nil {
+			operation = &model.ManagerOperation{Kind: kind, Source: source, Counter: counter, Fee: new(big.Int)}
+		}
+		if source != operation.Source {
+			return nil, errors.Errorf("contents have different sources %q and %q", operation.Source, source)
+		}
+		operation.Fee.Add(operation.Fee, fee)
+	}
+	if operation == nil {
+		return nil, errors.New("raw transaction has no content")
+	}
+	return operation, nil
+}
+
+// forgedReader reads the fields of a forged operation in order. Once a read fails, the following ones fail as well.
+type forgedReader struct {
+	data []byte
+	err  error
+}
+
+// managerContent reads a manager operation content, and returns its kind, source, fee and counter.
+func (r *forgedReader) managerContent() (string, string, *big.Int, *big.Int, error) {
+	tag := r.byte()
+	kind, ok := managerOperationTags[tag]
+	if r.err == nil && !ok {
+		return "", "", nil, nil, errors.Errorf("unsupported operation tag %d", tag)
+	}
+
+	sourceTag := r.byte()
+	prefix, ok := implicitPrefixes[sourceTag]
+	if r.err == nil && !ok {
+		return "", "", nil, nil, errors.Errorf("unsupported source tag %d", sourceTag)
+	}
+	// Base58check function only allow 1 byte prefix, so we append prefix[1:] here, and prefix[0] during the call to CheckEncode.
+	source := base58.CheckEncode(append(append([]byte{}, prefix[1:]...), r.bytes(20)...), prefix[0])
+
+	fee := r.zarith()
+	counter := r.zarith()
+	// Gas and storage limits.
+	r.zarith()
+	r.zarith()
+
+	switch kind {
+	case "reveal":
+		keyTag := r.byte()
+		length, ok := publicKeyLengths[keyTag]
+		if r.err == nil && !ok {
+			return "", "", nil, nil, errors.Errorf("unsupported public key tag %d", keyTag)
+		}
+		r.bytes(length)
+	case "transaction":
+		// Amount, destination and optional parameters.
+		r.zarith()
+		r.bytes(22)
+		if r.bool() {
+			// Named entrypoints have a tag, the others their name prefixed by its length.
+			if r.byte() == 0xff {
+				r.bytes(int(r.byte()))
+			}
+			r.variable()
+		}
+	case "origination":
+		// Balance, optional delegate, code and storage.
+		r.zarith()
+		if r.bool() {
+			r.bytes(21)
+		}
+		r.variable()
+		r.variable()
+	case "delegation":
+		// Optional delegate.
+		if r.bool() {
+			r.bytes(21)
+		}
+	}
+
+	if r.err != nil {
+		return "", "", nil, nil, errors.Wrapf(r.err, "could not decode %s content", kind)
+	}
+	return kind, source, fee, counter, nil
+}
+
+func (r *forgedReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data) {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+	res := r.data[:n]
+	r.data = r.data[n:]
+	return res
+}
+
+func (r *forgedReader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *forgedReader) bool() bool {
+	return r.byte() == 0xff
+}
+
+func (r *forgedReader) zarith() *big.Int {
+	if r.err != nil {
+		return nil
+	}
+	res, n, err := decodeZarith(r.data)
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	r.data = r.data[n:]
+	return res
+}
+
+// variable reads bytes prefixed by their length on 4 bytes.
+func (r *forgedReader) variable() []byte {
+	length := r.bytes(4)
+	if length == nil {
+		return nil
+	}
+	return r.bytes(int(binary.BigEndian.Uint32(length)))
+}
+
+// decodeZarith decodes a natural number encoded in 7 bit groups, least significant first, the high bit of each byte
+// telling whether another one follows. It returns the number and the count of bytes read.
+func decodeZarith(data []byte) (*big.Int, int, error) {
+	var (
+		res   = new(big.Int)
+		shift uint
+	)
+	for i, b := range data {
+		res.Or(res, new(big.Int).Lsh(big.NewInt(int64(b&0x7f)), shift))
+		if b&0x80 == 0 {
+			return res, i + 1, nil
+		}
+		shift += 7
+	}
+	return nil, 0, errors.New("unterminated number")
+}
+
 var defaultMinimalFees = big.NewInt(100000)
 var defaultMinimalNanotezPerGasUnit = big.NewInt(100)
 
@@ -217,13 +463,20 @@ func (c *Client) GetBlock(ctx context.Context, blockNumber uint64) (*common_mode
 		return nil, err
 	}
 
-	timestamp := block.Header.Timestamp.UTC()
-	return &common_model.Block{
-		Number:       blockNumber,
-		Hash:         &block.Hash,
-		PreviousHash: &block.Header.Predecessor,
-		Timestamp:    &timestamp,
-	}, nil
+	return toBlock(blockNumber, block), nil
+}
+
+// GetBlockWithTransactions returns the header and the transactions of a block, fetching it only once from the node,
+// and the time spent parsing the block into its header and transactions.
+func (c *Client) GetBlockWithTransactions(ctx context.Context, blockNumber uint64) (*common_model.Block, []*model.Transaction, time.Duration, error) {
+	block, err := c.client.Block(int(blockNumber))
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	begin := time.Now()
+	header, transactions := toBlock(blockNumber, block), toTransactions(blockNumber, block)
+	return header, transactions, time.Since(begin), nil
 }
 
 func (c *Client) GetHeight(ctx context.Context) (*model.Height, error) {
@@ -326,31 +579,66 @@ func (c *Client) GetTransactions(ctx context.Context, blockNumber uint64) ([]*mo
 		return nil, err
 	}
 
-	transactionIndex := map[string]uint64{}
+	return toTransactions(blockNumber, block), nil
+}
+
+func toBlock(blockNumber uint64, block *gotezos.Block) *common_model.Block {
+	timestamp := block.Header.Timestamp.UTC()
+	return &common_model.Block{
+		Number:       blockNumber,
+		Hash:         &block.Hash,
+		PreviousHash: &block.Header.Predecessor,
+		Timestamp:    &timestamp,
+	}
+}
+
+// toTransactions returns the manager contents of the operations of a block, indexed by their position in their
+// operation. The contents other than transactions have their kind set and no amount.
+func toTransactions(blockNumber uint64, block *gotezos.Block) []*model.Transaction {
 	transactions := []*model.Transaction{}
 	for _, operations := range block.Operations {
 		for _, operation := range operations {
-			for _, content := range operation.Contents {
+			for i, content := range operation.Contents {
 				switch content.Kind {
-				case "transaction":
-					ts := block.Header.Timestamp.UTC()
-					transactions = append(transactions, &model.Transaction{
-						Hash:               operation.Hash,
-						Index:              transactionIndex[operation.Hash],
-						BlockNumber:        &blockNumber,
-						SourceAddress:      &content.Source,
-						DestinationAddress: &content.Destination,
-						Amount:             content.Amount.Big,
-						Counter:            content.Counter.Big,
-						Fee:                content.Fee.Big,
-						Timestamp:          &ts,
-						Status:             common_model.SUCCESS.String(),
-					})
-					transactionIndex[operation.Hash]++
+				case "transaction", "reveal", "origination", "delegation":
+				default:
+					continue
+				}
+
+				content := content
+				ts := block.Header.Timestamp.UTC()
+				status, message := contentStatus(content)
+				tx := &model.Transaction{
+					Hash:          operation.Hash,
+					Index:         uint64(i),
+					BlockNumber:   &blockNumber,
+					SourceAddress: &content.Source,
+					Counter:       content.Counter.Big,
+					Fee:           content.Fee.Big,
+					Timestamp:     &ts,
+					Status:        status.String(),
+					Message:       message,
+				}
+				if content.Kind == "transaction" {
+					tx.DestinationAddress, tx.Amount = &content.Destination, content.Amount.Big
+				} else {
+					tx.Kind = &content.Kind
 				}
+				transactions = append(transactions, tx)
 			}
 		}
 	}
 
-	return transactions, nil
+	return transactions
+}
+
+// contentStatus returns the status of an included content from its operation result. The contents of an operation
+// are applied all or none: when one fails, the ones before are backtracked and the ones after are skipped.
+// A content without result is considered applied.
+func contentStatus(content gotezos.Contents) (common_model.Status, *string) {
+	if content.Metadata == nil || content.Metadata.OperationResult == nil || content.Metadata.OperationResult.Status == "applied" {
+		return common_model.SUCCESS, nil
+	}
+	message := fmt.Sprintf("operation content %s", content.Metadata.OperationResult.Status)
+	return common_model.FAILURE, &message
 }
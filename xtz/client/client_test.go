@@ -10,7 +10,9 @@ import (
 	"time"
 
 	"github.com/t-dx/tg-blocksd/internal/config"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 
+	gotezos "github.com/goat-systems/go-tezos/v2"
 	"github.com/stretchr/testify/require"
 )
 
@@ -47,6 +49,99 @@ func Test_GetRawTransactionHash(t *testing.T) {
 	}
 }
 
+func Test_OperationBranch(t *testing.T) {
+	branch, err := operationBranch("85a9ef47f6b1cc1432faaf87a242b08a42ea9e0c552b73ad6751efa5a75440376e00b1c4383a317576851a825b86aa59dc030e2ecb38dc0be0ab1ebc5000ff00a31e81ac3425310e3274a4698a793b2839dc0afa5f5d8672a4ee19cec93d8b7aa354a82dcaf534deeeb6345daa296eab5dba0520a334cebc8ed1b8c1a4d15de399dd0ad6494e3e17fff88b416131ade7d0d79e00")
+	require.Nil(t, err)
+	require.Equal(t, "BLj9beWDct8x7v83NAv5zViYFWJcYPJiqXehPXudGs83XNCbZB9", branch)
+
+	_, err = operationBranch("85a9ef47")
+	require.NotNil(t, err)
+
+	_, err = operationBranch("not hex")
+	require.NotNil(t, err)
+}
+
+func Test_GetManagerOperation(t *testing.T) {
+	client := &Client{}
+
+	ctx := context.Background()
+
+	operation, err := client.GetManagerOperation(ctx, "85a9ef47f6b1cc1432faaf87a242b08a42ea9e0c552b73ad6751efa5a75440376e00b1c4383a317576851a825b86aa59dc030e2ecb38dc0be0ab1ebc5000ff00a31e81ac3425310e3274a4698a793b2839dc0afa5f5d8672a4ee19cec93d8b7aa354a82dcaf534deeeb6345daa296eab5dba0520a334cebc8ed1b8c1a4d15de399dd0ad6494e3e17fff88b416131ade7d0d79e00")
+	require.Nil(t, err)
+	require.Equal(t, "delegation", operation.Kind)
+	require.Equal(t, "tz1bqyMeoid3NKnK1bPtnD1ceb2eSSqAd7Qi", operation.Source)
+	require.Equal(t, 0, big.NewInt(1500).Cmp(operation.Fee))
+	require.Equal(t, 0, big.NewInt(497120).Cmp(operation.Counter))
+
+	// A reveal batched with a transaction, the fees of both are summed.
+	operation, err = client.GetManagerOperation(ctx, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f6b00b1c4383a317576851a825b86aa59dc030e2ecb38e8070ae807000007070707070707070707070707070707070707070707070707070707070707076c00b1c4383a317576851a825b86aa59dc030e2ecb38dc0b0bbc508102050000a31e81ac3425310e3274a4698a793b2839dc0afa0009090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909")
+	require.Nil(t, err)
+	require.Equal(t, "reveal", operation.Kind)
+	require.Equal(t, "tz1bqyMeoid3NKnK1bPtnD1ceb2eSSqAd7Qi", operation.Source)
+	require.Equal(t, 0, big.NewInt(2500).Cmp(operation.Fee))
+	require.Equal(t, 0, big.NewInt(10).Cmp(operation.Counter))
+
+	// The contents of a batch have the same source.
+	_, err = client.GetManagerOperation(ctx, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f6b00b1c4383a317576851a825b86aa59dc030e2ecb38e8070ae807000007070707070707070707070707070707070707070707070707070707070707076c00a31e81ac3425310e3274a4698a793b2839dc0afadc0b0bbc508102050000b1c4383a317576851a825b86aa59dc030e2ecb380009090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909090909")
+	require.NotNil(t, err)
+
+	_, err = client.GetManagerOperation(ctx, "85a9ef47")
+	require.NotNil(t, err)
+}
+
+func Test_DecodeZarith(t *testing.T) {
+	value, n, err := decodeZarith([]byte{0xdc, 0x0b, 0xe0})
+	require.Nil(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, 0, big.NewInt(1500).Cmp(value))
+
+	_, _, err = decodeZarith([]byte{0xdc, 0x8b})
+	require.NotNil(t, err)
+}
+
+func Test_ContentStatus(t *testing.T) {
+	status, message := contentStatus(gotezos.Contents{Kind: "transaction"})
+	require.Equal(t, common_model.SUCCESS, status)
+	require.Nil(t, message)
+
+	status, message = contentStatus(gotezos.Contents{Kind: "transaction", Metadata: &gotezos.ContentsMetadata{OperationResult: &gotezos.OperationResult{Status: "applied"}}})
+	require.Equal(t, common_model.SUCCESS, status)
+	require.Nil(t, message)
+
+	status, message = contentStatus(gotezos.Contents{Kind: "transaction", Metadata: &gotezos.ContentsMetadata{OperationResult: &gotezos.OperationResult{Status: "backtracked"}}})
+	require.Equal(t, common_model.FAILURE, status)
+	require.Equal(t, "operation content backtracked", *message)
+}
+
+func Test_ToTransactions(t *testing.T) {
+	block := &gotezos.Block{
+		Header: gotezos.Header{Timestamp: time.Date(2020, time.March, 17, 9, 18, 0, 0, time.UTC)},
+		Operations: [][]gotezos.Operations{{{
+			Hash: "ooV9NJ8uToUpaPV3ybvbF49gH8kFQ5E69XehwoMAPzeRVWmauba",
+			Contents: []gotezos.Contents{
+				{Kind: "reveal", Source: "tz1a", Counter: &gotezos.Int{Big: big.NewInt(10)}, Fee: &gotezos.Int{Big: big.NewInt(1000)}},
+				{Kind: "transaction", Source: "tz1a", Destination: "tz1b", Amount: &gotezos.Int{Big: big.NewInt(5)}, Counter: &gotezos.Int{Big: big.NewInt(11)}, Fee: &gotezos.Int{Big: big.NewInt(1500)}},
+				{Kind: "delegation", Source: "tz1a", Counter: &gotezos.Int{Big: big.NewInt(12)}, Fee: &gotezos.Int{Big: big.NewInt(1000)},
+					Metadata: &gotezos.ContentsMetadata{OperationResult: &gotezos.OperationResult{Status: "failed"}}},
+			},
+		}}},
+	}
+
+	transactions := toTransactions(868970, block)
+	require.Len(t, transactions, 3)
+	for i, tx := range transactions {
+		require.Equal(t, uint64(i), tx.Index)
+		require.Equal(t, "tz1a", *tx.SourceAddress)
+	}
+	require.Equal(t, "reveal", *transactions[0].Kind)
+	require.Nil(t, transactions[0].Amount)
+	require.Nil(t, transactions[1].Kind)
+	require.Equal(t, "tz1b", *transactions[1].DestinationAddress)
+	require.Equal(t, 0, big.NewInt(5).Cmp(transactions[1].Amount))
+	require.Equal(t, "delegation", *transactions[2].Kind)
+	require.Equal(t, common_model.FAILURE.String(), transactions[2].Status)
+}
+
 func Test_GetEstimatedFee(t *testing.T) {
 	client, err := NewClient(cfg)
 	require.Nil(t, err)
@@ -143,3 +238,20 @@ func Test_GetTransactions(t *testing.T) {
 	require.Nil(t, err)
 	require.Len(t, transactions, 2)
 }
+
+func Test_GetBlockWithTransactions(t *testing.T) {
+	c, err := NewClient(cfg)
+	require.Nil(t, err)
+
+	var blockNumber uint64 = 868984
+
+	block, transactions, _, err := c.GetBlockWithTransactions(context.Background(), blockNumber)
+	require.Nil(t, err)
+	require.Equal(t, blockNumber, block.Number)
+	require.NotNil(t, block.Hash)
+	require.NotNil(t, block.PreviousHash)
+	require.Len(t, transactions, 2)
+	for _, transaction := range transactions {
+		require.Equal(t, blockNumber, *transaction.BlockNumber)
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"time"
 
 	"github.com/t-dx/tg-blocksd/internal/logger"
 	"github.com/t-dx/tg-blocksd/internal/utils/cache"
@@ -82,6 +83,25 @@ func (mw *caching) GetBlock(ctx context.Context, blockNumber uint64) (*common_mo
 	return block, nil
 }
 
+func (mw *caching) GetBlockWithTransactions(ctx context.Context, blockNumber uint64) (*common_model.Block, []*model.Transaction, time.Duration, error) {
+	block, transactions, parseDuration, err := mw.next.GetBlockWithTransactions(ctx, blockNumber)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// Only the header is cached, so that a later GetBlock on the same level does not download the block again.
+	if key, err := cache.GenKey("GetBlock", blockNumber); err == nil {
+		if toCache, err := cache.Encode(block); err == nil {
+			err = mw.cache.Set(key, toCache, getBlockCacheExpiration)
+			if err != nil {
+				logger.TechLog.Error(ctx, "cache error", zap.Error(err))
+			}
+		}
+	}
+
+	return block, transactions, parseDuration, nil
+}
+
 func (mw *caching) GetHeight(ctx context.Context) (*model.Height, error) {
 	key, err := cache.GenKey("GetHeight")
 	if err != nil {
@@ -124,6 +144,14 @@ func (mw *caching) GetRawTransactionHash(ctx context.Context, rawTransaction str
 	return mw.next.GetRawTransactionHash(ctx, rawTransaction)
 }
 
+func (mw *caching) GetOperationExpiry(ctx context.Context, rawTransaction string) (*model.OperationExpiry, error) {
+	return mw.next.GetOperationExpiry(ctx, rawTransaction)
+}
+
+func (mw *caching) GetManagerOperation(ctx context.Context, rawTransaction string) (*model.ManagerOperation, error) {
+	return mw.next.GetManagerOperation(ctx, rawTransaction)
+}
+
 func (mw *caching) GetTransactions(ctx context.Context, blockNumber uint64) ([]*model.Transaction, error) {
 	return mw.next.GetTransactions(ctx, blockNumber)
 }
@@ -0,0 +1,185 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	job "github.com/t-dx/go-jobs/v4"
+	"github.com/t-dx/tg-blocksd/internal/logger"
+	pool "github.com/t-dx/tg-blocksd/internal/worker"
+	"github.com/t-dx/tg-blocksd/pkg/helper"
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Backfiller indexes past ranges of blocks requested through CreateBackfill or address onboardings, independently of the BlockFetcher.
+// The shards of a backfill are indexed in parallel, and each block is checkpointed with its transactions so that
+// an interrupted backfill resumes where it stopped. The block entries are not stored, so the last block of the
+// BlockFetcher is not affected.
+type Backfiller struct {
+	TransactionStore xtz_service.TransactionStore
+	Client           xtz_service.Client
+
+	// MaxOffset is the BlockFetcher offset from the head, blocks closer to the head are not backfilled yet.
+	MaxOffset uint64
+	// MaxWorkers is the maximum number of shards indexed in parallel. Zero means one worker per shard.
+	MaxWorkers int
+
+	MetricsBlocksBackfilled *prometheus.CounterVec
+	MetricsJobDuration      *prometheus.SummaryVec
+}
+
+func (j *Backfiller) Do(ctx context.Context, meta job.JobMeta, arg interface{}) (_ interface{}, _ map[string]string, err error) {
+	log := logger.With(logger.TechLog, zap.String("job_name", meta.JobName), zap.String("job_id", meta.JobID))
+
+	// Duration metrics
+	defer func(begin time.Time) {
+		status := "success"
+		if err != nil {
+			status = "failed"
+		}
+		j.MetricsJobDuration.With(helper.MakePrometheusLabels("name", meta.JobName, "status", status)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	log.Info(ctx, "job started", zap.Time("now", time.Now().UTC()))
+
+	backfills, err := j.TransactionStore.GetPendingBackfills(ctx)
+	if err != nil {
+		log.Error(ctx, "could not get pending backfills", zap.Error(err))
+		return nil, map[string]string{"msg": "could not get pending backfills", "error": err.Error()}, err
+	}
+
+	// No work to do.
+	if len(backfills) == 0 {
+		log.Info(ctx, "no work to do")
+		return nil, map[string]string{"msg": "no work to do"}, nil
+	}
+
+	height, err := j.Client.GetHeight(ctx)
+	if err != nil {
+		log.Error(ctx, "could not get block count", zap.Error(err))
+		return nil, map[string]string{"msg": "could not get block count", "error": err.Error()}, err
+	}
+	headBlock := height.Height - j.MaxOffset
+
+	for _, backfill := range backfills {
+		log.Info(ctx, "start backfill", zap.String("backfill_id", backfill.ID), zap.Uint64("from_block", backfill.FromBlock), zap.Uint64("to_block", backfill.ToBlock), zap.Int("num_shards", len(backfill.Shards)))
+
+		err = j.backfill(ctx, backfill, headBlock, log)
+		if err != nil {
+			log.Error(ctx, "could not backfill", zap.String("backfill_id", backfill.ID), zap.Error(err))
+			return nil, map[string]string{"msg": "could not backfill", "error": err.Error()}, err
+		}
+
+		err = j.TransactionStore.CompleteBackfill(ctx, backfill.ID)
+		if err != nil {
+			log.Error(ctx, "could not complete backfill", zap.String("backfill_id", backfill.ID), zap.Error(err))
+			return nil, map[string]string{"msg": "could not complete backfill", "error": err.Error()}, err
+		}
+
+		err = meta.Update(ctx, map[string]string{"msg": fmt.Sprintf("finished with backfill %s", backfill.ID)})
+		if err != nil {
+			log.Info(ctx, "could not update job status", zap.Error(err))
+		}
+	}
+	log.Info(ctx, "successfully finished")
+
+	return nil, map[string]string{"msg": fmt.Sprintf("finished with %d backfills", len(backfills))}, nil
+}
+
+// backfill indexes the remaining blocks of the shards of a backfill, with up to MaxWorkers shards in parallel.
+// Blocks after headBlock are left for a later run. The transactions of a backfill restricted to addresses (an onboarding)
+// are pinned, so that the garbage collection does not delete them right away; a backfill of the whole chain is not pinned.
+func (j *Backfiller) backfill(ctx context.Context, backfill *xtz_model.Backfill, headBlock uint64, log *logger.ContextLogger) error {
+	var shards []*xtz_model.BackfillShard
+	for _, shard := range backfill.Shards {
+		if shard.CompletedAt == nil {
+			shards = append(shards, shard)
+		}
+	}
+	if len(shards) == 0 {
+		return nil
+	}
+
+	var addresses = map[string]struct{}{}
+	for _, address := range backfill.Addresses {
+		addresses[address] = struct{}{}
+	}
+
+	worker := func(ctx context.Context, i interface{}) error {
+		shard, ok := i.(*xtz_model.BackfillShard)
+		if !ok {
+			return errors.Errorf("wrong type %T, should be *model.BackfillShard", i)
+		}
+
+		for blockNumber := shard.NextBlock; blockNumber <= shard.ToBlock && blockNumber <= headBlock; blockNumber++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			_, transactions, _, err := j.Client.GetBlockWithTransactions(ctx, blockNumber)
+			if err != nil {
+				return errors.Wrapf(err, "could not get block %d", blockNumber)
+			}
+			transactions, _ = splitTransfers(transactions)
+			if len(addresses) > 0 {
+				transactions = filterTransactions(transactions, addresses)
+				for _, tx := range transactions {
+					tx.Pinned = true
+				}
+			}
+
+			err = j.TransactionStore.CommitBackfillBlock(ctx, shard.BackfillID, shard.Shard, blockNumber, transactions)
+			if err != nil {
+				return errors.Wrapf(err, "could not commit block %d of shard %d", blockNumber, shard.Shard)
+			}
+
+			j.MetricsBlocksBackfilled.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(1)
+			log.Debug(ctx, "backfilled block", zap.String("backfill_id", shard.BackfillID), zap.Uint64("shard", shard.Shard), zap.Uint64("block_number", blockNumber))
+		}
+		return nil
+	}
+
+	numWorkers := len(shards)
+	if j.MaxWorkers > 0 && j.MaxWorkers < numWorkers {
+		numWorkers = j.MaxWorkers
+	}
+	var workers []pool.Worker
+	for i := 0; i < numWorkers; i++ {
+		workers = append(workers, worker)
+	}
+
+	inputc, errc := pool.RegisterContext(ctx, workers, len(shards))
+	for _, shard := range shards {
+		inputc <- shard
+	}
+	close(inputc)
+
+	var aggrErr error
+	for err := range errc {
+		if err != nil {
+			if aggrErr != nil {
+				aggrErr = errors.Wrap(aggrErr, err.Error())
+			} else {
+				aggrErr = err
+			}
+		}
+	}
+	return aggrErr
+}
+
+// filterTransactions keeps the transactions from or to the given addresses.
+func filterTransactions(transactions []*xtz_model.Transaction, addresses map[string]struct{}) []*xtz_model.Transaction {
+	var res []*xtz_model.Transaction
+	for _, tx := range transactions {
+		if touchesAddresses(tx, addresses) {
+			res = append(res, tx)
+		}
+	}
+	return res
+}
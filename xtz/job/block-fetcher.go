@@ -8,6 +8,7 @@ import (
 
 	job "github.com/t-dx/go-jobs/v4"
 	"github.com/t-dx/tg-blocksd/internal/logger"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	"github.com/t-dx/tg-blocksd/pkg/common/service"
 	"github.com/t-dx/tg-blocksd/pkg/common/store/cockroach"
 	"github.com/t-dx/tg-blocksd/pkg/helper"
@@ -24,21 +25,94 @@ type MetricStore interface {
 	Put(ctx context.Context, module, key string, metric json.RawMessage) error
 }
 
+// Indexing stages, as labelled in MetricsStageDuration.
+const (
+	// stageFetch is the download of the block from the node.
+	stageFetch = "fetch"
+	// stageParse is the parsing of the downloaded block into its header and transactions.
+	stageParse = "parse"
+	// stageReorgCheck is the check that the block follows the last stored block, including the rollback of a reorg.
+	stageReorgCheck = "reorg_check"
+	// stageFilter is the filtering of the transactions on the watched addresses in WatchedOnly mode.
+	stageFilter = "filter"
+	// stageInsert is the preparation of the insertion of the transactions and of the block entry.
+	stageInsert = "insert"
+	// stageCommit is the execution of the insertion in a single database transaction, sent in one round trip so
+	// that a block is stored atomically.
+	stageCommit = "commit"
+)
+
+// headRefreshInterval is how often the head of the blockchain is fetched again during a run, to keep the lag accurate
+// while catching up.
+const headRefreshInterval = 30 * time.Second
+
+// indexerStatus is the status of the indexer stored in the metric store after each block.
+type indexerStatus struct {
+	BlockNumber    uint64     `json:"block_number"`
+	BlockTimestamp *time.Time `json:"block_timestamp,omitempty"`
+	HeadBlock      uint64     `json:"head_block"`
+	// Lag is the number of blocks between the head of the blockchain and the indexed block.
+	Lag       uint64    `json:"lag"`
+	IndexedAt time.Time `json:"indexed_at"`
+	// DelaySeconds is the time between the block production and its indexing.
+	DelaySeconds float64 `json:"delay_seconds"`
+}
+
 type BlockFetcher struct {
 	BlockStore       service.BlockStore
 	TransactionStore xtz_service.TransactionStore
 	Client           xtz_service.Client
 
+	// Deprecated: BatchSize and ParallelBatch are ignored, the transactions of a block are inserted with the block
+	// entry in a single database transaction.
 	BatchSize     int
 	ParallelBatch int
 	MaxOffset     uint64
 	StartBlock    uint64
 
+	// PrefetchWindow is the number of blocks downloaded and parsed concurrently ahead of the block being stored.
+	PrefetchWindow int
+	// PrefetchMaxTransactions bounds the memory used by the prefetched blocks: no new block is fetched while
+	// more transactions than this are waiting to be stored. Zero means no limit.
+	PrefetchMaxTransactions int
+
+	// MaxReorgDepth is the deepest reorg rolled back without an operator decision. Zero means no limit.
+	MaxReorgDepth uint64
+
+	// MaxBlocksPerRun and MaxRunDuration bound each run, zero meaning no bound. A run stops between blocks when
+	// a bound is reached or when its context is cancelled, and the next run resumes from the last stored block.
+	// The block entry is stored with the transactions of the block, it is the progress of the fetcher, and it is
+	// rolled back with the block on reorg.
+	MaxBlocksPerRun uint64
+	MaxRunDuration  time.Duration
+
+	// WatchedOnly restricts the stored transactions to the ones from or to the addresses of 'xtz_addresses',
+	// and to the tracked broadcasts. The watched addresses are refreshed before each block, so the transactions
+	// of an address are stored from the first block indexed after its creation on. Use a since block or date when
+	// adding it to scan its history.
+	WatchedOnly bool
+	watchlist   *watchlist
+
 	MetricStore                 MetricStore
 	MetricsBlockIndexed         *prometheus.GaugeVec
 	MetricsTransactionsInserted *prometheus.CounterVec
 	MetricsBlocksFetched        *prometheus.CounterVec
 	MetricsJobDuration          *prometheus.SummaryVec
+
+	// MetricsIndexerLag is the number of blocks between the head of the blockchain and the last indexed block.
+	MetricsIndexerLag *prometheus.GaugeVec
+	// MetricsIndexingDelay is the time between the production of a block and its indexing, in seconds.
+	MetricsIndexingDelay     *prometheus.HistogramVec
+	MetricsLastIndexingDelay *prometheus.GaugeVec
+	// MetricsStageDuration is the time spent on each indexing stage of a block, in seconds.
+	MetricsStageDuration *prometheus.HistogramVec
+
+	MetricsReorgs                 *prometheus.CounterVec
+	MetricsReorgDepth             *prometheus.HistogramVec
+	MetricsTransactionsRolledBack *prometheus.CounterVec
+	MetricsReorgHalted            *prometheus.GaugeVec
+
+	MetricsTransactionsSkipped *prometheus.CounterVec
 }
 
 func (bf *BlockFetcher) Do(ctx context.Context, meta job.JobMeta, arg interface{}) (_ interface{}, _ map[string]string, err error) {
@@ -55,6 +129,36 @@ func (bf *BlockFetcher) Do(ctx context.Context, meta job.JobMeta, arg interface{
 
 	log.Info(ctx, "job started", zap.Time("now", time.Now().UTC()))
 
+	// A reorg deeper than MaxReorgDepth halts the fetcher until an operator decides what to do.
+	maxReorgDepth := bf.MaxReorgDepth
+	halt, err := bf.TransactionStore.GetIndexerHalt(ctx)
+	if err != nil {
+		log.Error(ctx, "could not get indexer halt", zap.Error(err))
+		return nil, map[string]string{"msg": "could not get indexer halt", "error": err.Error()}, err
+	}
+	if halt != nil {
+		switch {
+		case halt.Decision == nil:
+			bf.MetricsReorgHalted.With(helper.MakePrometheusLabels("coin", "XTZ")).Set(1)
+			log.Warn(ctx, "halted, waiting for an operator decision", zap.String("halt_id", halt.ID), zap.Uint64("block_number", halt.BlockNumber), zap.Uint64("depth", halt.Depth))
+			return nil, map[string]string{"msg": fmt.Sprintf("halted at block %d, waiting for an operator decision", halt.BlockNumber)}, nil
+		case *halt.Decision == xtz_model.HaltDecisionAccept:
+			// The reorg is rolled back whatever its depth, then the halt is closed.
+			log.Info(ctx, "reorg accepted by operator", zap.String("halt_id", halt.ID), zap.Stringp("decided_by", halt.DecidedBy))
+			maxReorgDepth = 0
+		default:
+			// The node has been switched, the reorg is checked again against the new node.
+			log.Info(ctx, "node switched by operator", zap.String("halt_id", halt.ID), zap.Stringp("decided_by", halt.DecidedBy))
+			err = bf.TransactionStore.CloseIndexerHalt(ctx, halt.ID)
+			if err != nil {
+				log.Error(ctx, "could not close indexer halt", zap.String("halt_id", halt.ID), zap.Error(err))
+				return nil, map[string]string{"msg": "could not close indexer halt", "error": err.Error()}, err
+			}
+			halt = nil
+		}
+	}
+	bf.MetricsReorgHalted.With(helper.MakePrometheusLabels("coin", "XTZ")).Set(0)
+
 	// Get the head of the blockchain to compute the headBlock (head - maxOffset).
 	height, err := bf.Client.GetHeight(ctx)
 	if err != nil {
@@ -62,6 +166,7 @@ func (bf *BlockFetcher) Do(ctx context.Context, meta job.JobMeta, arg interface{
 		return nil, map[string]string{"msg": "could not get block count", "error": err.Error()}, err
 	}
 	headBlock := height.Height - bf.MaxOffset
+	heightAt := time.Now()
 
 	log.Info(ctx, "successfully got headBlock", zap.Uint64("head_block", headBlock))
 
@@ -76,54 +181,125 @@ func (bf *BlockFetcher) Do(ctx context.Context, meta job.JobMeta, arg interface{
 	default:
 		nextBlock = currentBlock.Number + 1
 	}
+	bf.MetricsIndexerLag.With(helper.MakePrometheusLabels("coin", "XTZ")).Set(float64(lag(height.Height, nextBlock)))
 
 	log.Info(ctx, "successfully got nextBlock", zap.Uint64("next_block", nextBlock))
 
+	toBlock := headBlock
+	if bf.MaxBlocksPerRun > 0 && nextBlock+bf.MaxBlocksPerRun-1 < toBlock {
+		toBlock = nextBlock + bf.MaxBlocksPerRun - 1
+	}
+	var deadline time.Time
+	if bf.MaxRunDuration > 0 {
+		deadline = time.Now().Add(bf.MaxRunDuration)
+	}
+
+	// Blocks are fetched ahead by the prefetcher, but they are still processed in order.
+	pf := newPrefetcher(ctx, bf.Client, nextBlock, toBlock, bf.PrefetchWindow, bf.PrefetchMaxTransactions)
+	defer func() {
+		pf.stop()
+	}()
+
 	var processedBlock uint64
 	// Put entries in blockstore for the block we have to process.
-	for processedBlock = nextBlock; processedBlock <= headBlock; processedBlock++ {
-		log.Info(ctx, "start fetching blocks", zap.Uint64("start", nextBlock), zap.Uint64("end", headBlock), zap.Uint64("current", processedBlock))
+	for processedBlock = nextBlock; processedBlock <= toBlock; processedBlock++ {
+		// Stop between blocks, the block being processed is either fully stored or not at all.
+		if reason := stopReason(ctx, deadline); reason != "" {
+			log.Info(ctx, "stopping", zap.String("reason", reason), zap.Uint64("next_block", processedBlock), zap.Uint64("head_block", headBlock))
+			return nil, map[string]string{"msg": fmt.Sprintf("%s, stopped before block %d", reason, processedBlock)}, nil
+		}
+
+		log.Info(ctx, "start fetching blocks", zap.Uint64("start", nextBlock), zap.Uint64("end", toBlock), zap.Uint64("current", processedBlock))
 
-		block, err := bf.Client.GetBlock(ctx, processedBlock)
+		block, transactions, err := pf.next(ctx)
+		if err != nil && ctx.Err() != nil {
+			log.Info(ctx, "stopping", zap.String("reason", "cancelled"), zap.Uint64("next_block", processedBlock), zap.Uint64("head_block", headBlock))
+			return nil, map[string]string{"msg": fmt.Sprintf("cancelled, stopped before block %d", processedBlock)}, nil
+		}
 		if err != nil {
 			log.Error(ctx, "could not get block", zap.Error(err))
 			return nil, map[string]string{"msg": "could not get block", "error": err.Error()}, err
 		}
 		log.Info(ctx, "fetch block", zap.Uint64("block_number", processedBlock))
+		bf.observeStage(stageFetch, pf.fetchDuration)
+		bf.observeStage(stageParse, pf.parseDuration)
 
 		// Check for reorgs.
-		isReorg, reorgsFromBlockNumber := bf.reorgs(ctx, processedBlock, log)
+		begin := time.Now()
+		isReorg, reorgsFromBlockNumber, err := bf.reorgs(ctx, block, maxReorgDepth, log)
+		bf.observeStage(stageReorgCheck, time.Since(begin))
+		if tooDeep, ok := err.(*errReorgTooDeep); ok {
+			log.Error(ctx, "reorg too deep, halting", zap.Uint64("block_number", processedBlock), zap.Uint64("depth", tooDeep.halt.Depth), zap.Uint64("max_depth", maxReorgDepth))
+			if _, herr := bf.TransactionStore.CreateIndexerHalt(ctx, tooDeep.halt); herr != nil {
+				log.Error(ctx, "could not create indexer halt", zap.Uint64("block_number", processedBlock), zap.Error(herr))
+				return nil, map[string]string{"msg": "could not create indexer halt", "error": herr.Error()}, herr
+			}
+			bf.MetricsReorgHalted.With(helper.MakePrometheusLabels("coin", "XTZ")).Set(1)
+			return nil, map[string]string{"msg": "reorg too deep, waiting for an operator decision", "error": err.Error()}, err
+		}
+		if err != nil {
+			log.Error(ctx, "could not check for reorg", zap.Uint64("block_number", processedBlock), zap.Error(err))
+			return nil, map[string]string{"msg": "could not check for reorg", "error": err.Error()}, err
+		}
+		if halt != nil {
+			// The accepted reorg has been rolled back, the limit applies again.
+			err = bf.TransactionStore.CloseIndexerHalt(ctx, halt.ID)
+			if err != nil {
+				log.Error(ctx, "could not close indexer halt", zap.String("halt_id", halt.ID), zap.Error(err))
+				return nil, map[string]string{"msg": "could not close indexer halt", "error": err.Error()}, err
+			}
+			halt = nil
+			maxReorgDepth = bf.MaxReorgDepth
+		}
 		if isReorg {
 			log.Info(ctx, "reorg", zap.Uint64("current_block", processedBlock), zap.Uint64("reorg_block", reorgsFromBlockNumber))
 			processedBlock = reorgsFromBlockNumber
-			continue
-		}
 
-		transactions, err := bf.Client.GetTransactions(ctx, processedBlock)
-		if err != nil {
-			log.Error(ctx, "could not get transactions", zap.Error(err))
-			return nil, map[string]string{"msg": "could not get transactions", "error": err.Error()}, err
+			// The rolled back blocks have to be fetched again, restart the prefetching from the fork.
+			pf.stop()
+			pf = newPrefetcher(ctx, bf.Client, processedBlock+1, toBlock, bf.PrefetchWindow, bf.PrefetchMaxTransactions)
+			continue
 		}
 
-		err = bf.storeTransactions(ctx, transactions, log)
+		// Store the transactions and the block entry atomically, the block entry means the block is finished processing.
+		err = bf.storeBlock(ctx, block, transactions, false)
 		if err != nil {
-			log.Error(ctx, "could not store transactions", zap.Error(err))
-			return nil, map[string]string{"msg": "could not store transactions", "error": err.Error()}, err
+			log.Error(ctx, "could not commit block", zap.Uint64("block_number", processedBlock), zap.Error(err))
+			return nil, map[string]string{"msg": "could not commit block", "error": err.Error()}, err
 		}
 
-		// Create block entry, it means the block is finish processing.
-		err = bf.BlockStore.CreateBlock(ctx, block)
-		if err != nil {
-			log.Error(ctx, "could not create block", zap.Uint64("block_number", processedBlock), zap.Error(err))
-			return nil, map[string]string{"msg": "could not create block", "error": err.Error()}, err
+		// The run still ends at headBlock, the refreshed head only serves the lag.
+		if time.Since(heightAt) >= headRefreshInterval {
+			newHeight, err := bf.Client.GetHeight(ctx)
+			if err != nil {
+				log.Warn(ctx, "could not refresh block count", zap.Uint64("block_number", processedBlock), zap.Error(err))
+			} else {
+				height = newHeight
+			}
+			heightAt = time.Now()
 		}
 
 		// Update metric.
-		bf.MetricsBlocksFetched.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(1)
-		bf.MetricsTransactionsInserted.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(float64(len(transactions)))
+		metric := &indexerStatus{
+			BlockNumber:    processedBlock,
+			BlockTimestamp: block.Timestamp,
+			HeadBlock:      height.Height,
+			Lag:            lag(height.Height, processedBlock+1),
+			IndexedAt:      time.Now().UTC(),
+		}
+		if block.Timestamp != nil {
+			metric.DelaySeconds = metric.IndexedAt.Sub(*block.Timestamp).Seconds()
+			bf.MetricsIndexingDelay.With(helper.MakePrometheusLabels("coin", "XTZ")).Observe(metric.DelaySeconds)
+			bf.MetricsLastIndexingDelay.With(helper.MakePrometheusLabels("coin", "XTZ")).Set(metric.DelaySeconds)
+		}
 		bf.MetricsBlockIndexed.With(helper.MakePrometheusLabels("coin", "XTZ")).Set(float64(processedBlock))
+		bf.MetricsIndexerLag.With(helper.MakePrometheusLabels("coin", "XTZ")).Set(float64(metric.Lag))
 
-		jsonMetric := json.RawMessage(fmt.Sprintf(`{"block_number":%d}`, processedBlock))
+		jsonMetric, err := json.Marshal(metric)
+		if err != nil {
+			log.Error(ctx, "could not marshal metric", zap.Uint64("block_number", processedBlock), zap.Error(err))
+			return nil, map[string]string{"msg": "could not marshal metric", "error": err.Error()}, err
+		}
 		err = bf.MetricStore.Put(ctx, "indexer", "XTZ", jsonMetric)
 		if err != nil {
 			log.Error(ctx, "could not store metric", zap.Uint64("block_number", processedBlock), zap.Error(err))
@@ -142,116 +318,260 @@ func (bf *BlockFetcher) Do(ctx context.Context, meta job.JobMeta, arg interface{
 	return nil, map[string]string{"msg": fmt.Sprintf("finished with blocks up to %d", processedBlock)}, nil
 }
 
-func (bf *BlockFetcher) storeTransactions(ctx context.Context, batch []*xtz_model.Transaction, log *logger.ContextLogger) error {
-	errc := make(chan error)
-
-	f := func(txs []*xtz_model.Transaction, idx int) {
-		log.Debug(ctx, "inserting transactions batch", zap.Int("from", idx), zap.Int("to", idx+len(txs)))
-		var err = bf.TransactionStore.CreateTransactions(ctx, txs)
-		if err == nil {
-			log.Debug(ctx, "successfully inserted batch", zap.Int("from", idx), zap.Int("to", idx+len(txs)))
+// reorgs checks that the block fetched from the blockchain follows the last stored block.
+// If it does not, the stored blocks are rolled back until a common ancestor is found, and the reorg is journaled.
+// The headers of the older blocks are fetched only when walking back.
+// If the reorg is deeper than maxDepth, nothing is rolled back and an *errReorgTooDeep is returned.
+// It returns whether a reorg happened and the block to resume from.
+func (bf *BlockFetcher) reorgs(ctx context.Context, block *common_model.Block, maxDepth uint64, log *logger.ContextLogger) (bool, uint64, error) {
+	processedBlock := block.Number
+	blockNumber := processedBlock
+	// Hashes of the diverging blocks, from the most recent one.
+	var oldHashes, newHashes []string
+	for blockNumber > 0 {
+		previousBlock := blockNumber - 1
+		previousStoredBlock, err := bf.BlockStore.GetBlock(ctx, previousBlock)
+		if err == cockroach.ErrNoBlock {
+			// Nothing is stored before, e.g. when starting from StartBlock.
+			log.Debug(ctx, "no block in blockstore", zap.Uint64("block_number", previousBlock))
+			break
 		}
-		errc <- err
-	}
-
-	for i := 0; i < len(batch); i += bf.BatchSize * bf.ParallelBatch {
-		min, max := i, i+bf.BatchSize*bf.ParallelBatch
-		if max > len(batch) {
-			max = len(batch)
+		if err != nil {
+			return false, 0, errors.Wrapf(err, "could not get block %d from store", previousBlock)
 		}
+		log.Debug(ctx, "got block from blockstore", zap.Uint64("block_number", previousStoredBlock.Number), zap.Stringp("block_hash", previousStoredBlock.Hash))
 
-		var n = 0
-		for j := 0; j < bf.ParallelBatch; j++ {
-			minBatch := min + bf.BatchSize*j
-			maxBatch := min + bf.BatchSize*(j+1)
-			if maxBatch > max {
-				maxBatch = max
-			}
-			if minBatch > max {
-				break
+		if blockNumber != processedBlock {
+			block, err = bf.Client.GetBlock(ctx, blockNumber)
+			if err != nil {
+				return false, 0, errors.Wrapf(err, "could not get block %d", blockNumber)
 			}
+		}
+		log.Debug(ctx, "got block from blockchain", zap.Uint64("block_number", block.Number), zap.Stringp("block_hash", block.Hash), zap.Stringp("previous_hash", block.PreviousHash))
 
-			go f(batch[minBatch:maxBatch], minBatch)
-			n++
+		if previousStoredBlock.Hash == nil || block.PreviousHash == nil {
+			log.Warn(ctx, "missing block hash, cannot check for reorg", zap.Uint64("block_number", previousBlock))
+			break
 		}
 
-		var hasErr bool
-		var aggrErr error
-		for i := 0; i < n; i++ {
-			var err = <-errc
-			if err != nil {
-				hasErr = true
-				if aggrErr != nil {
-					aggrErr = errors.Wrapf(aggrErr, err.Error())
-				} else {
-					aggrErr = err
-				}
-			}
+		if *previousStoredBlock.Hash == *block.PreviousHash {
+			break
 		}
 
-		if hasErr {
-			return aggrErr
+		oldHashes = append(oldHashes, *previousStoredBlock.Hash)
+		newHashes = append(newHashes, *block.PreviousHash)
+		blockNumber--
+
+		if maxDepth > 0 && uint64(len(oldHashes)) > maxDepth {
+			return false, 0, &errReorgTooDeep{halt: &xtz_model.IndexerHalt{
+				BlockNumber: processedBlock,
+				Depth:       uint64(len(oldHashes)),
+				MaxDepth:    maxDepth,
+				StoredHash:  &oldHashes[0],
+				ChainHash:   &newHashes[0],
+			}}
 		}
 	}
 
-	return nil
-}
+	// There was no reorgs.
+	if blockNumber == processedBlock {
+		return false, 0, nil
+	}
 
-func (bf *BlockFetcher) reorgs(ctx context.Context, processedBlock uint64, log *logger.ContextLogger) (bool, uint64) {
-	blockNumber := processedBlock
-	previousBlock := blockNumber - 1
+	forkBlock := blockNumber - 1
 	var blocksToDelete []uint64
-	for {
-		previousStoredBlock, err := bf.BlockStore.GetBlock(ctx, previousBlock)
-		if err != nil {
-			log.Error(ctx, "could not get block from store", zap.Error(err))
-			return false, 0
-		}
-		log.Debug(ctx, "got block from blockstore", zap.Uint64("block_number", previousStoredBlock.Number), zap.Stringp("block_hash", previousStoredBlock.Hash))
+	for n := blockNumber; n < processedBlock; n++ {
+		blocksToDelete = append(blocksToDelete, n)
+	}
+	reverse(oldHashes)
+	reverse(newHashes)
 
-		block, err := bf.Client.GetBlock(ctx, blockNumber)
+	transactionHashes, err := bf.TransactionStore.GetBlocksTransactionHashes(ctx, blocksToDelete)
+	if err != nil {
+		return false, 0, errors.Wrapf(err, "could not get transactions of blocks %v", blocksToDelete)
+	}
+
+	// Delete transactions and blocks, and journal the reorg.
+	log.Debug(ctx, "rolling back blocks", zap.Uint64s("blocks", blocksToDelete), zap.Int("num_transactions", len(transactionHashes)))
+	err = bf.TransactionStore.RollbackBlocks(ctx, &xtz_model.Reorg{
+		DetectedAtBlock:   processedBlock,
+		ForkBlock:         forkBlock,
+		Depth:             uint64(len(blocksToDelete)),
+		OldHashes:         oldHashes,
+		NewHashes:         newHashes,
+		TransactionHashes: transactionHashes,
+	})
+	if err != nil {
+		return false, 0, errors.Wrapf(err, "could not roll back blocks %v", blocksToDelete)
+	}
+	log.Debug(ctx, "successfully rolled back blocks", zap.Uint64s("blocks", blocksToDelete))
+
+	bf.MetricsReorgs.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(1)
+	bf.MetricsReorgDepth.With(helper.MakePrometheusLabels("coin", "XTZ")).Observe(float64(len(blocksToDelete)))
+	bf.MetricsTransactionsRolledBack.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(float64(len(transactionHashes)))
+
+	return true, forkBlock, nil
+}
+
+// storeBlock stores a block with its transfers, filtered on the watched addresses in WatchedOnly mode, and the
+// other contents of the broadcasted operations. When repair is true, the stored block is replaced instead.
+func (bf *BlockFetcher) storeBlock(ctx context.Context, block *common_model.Block, transactions []*xtz_model.Transaction, repair bool) error {
+	transactions, contents := splitTransfers(transactions)
+	txCount := uint64(len(transactions))
+	if bf.WatchedOnly {
+		var (
+			skipped int
+			err     error
+		)
+		begin := time.Now()
+		transactions, skipped, err = bf.filterWatched(ctx, transactions)
 		if err != nil {
-			log.Error(ctx, "could not get block", zap.Error(err))
-			return false, 0
+			return err
 		}
-		log.Debug(ctx, "got block from blockchain", zap.Uint64("block_number", block.Number), zap.Stringp("block_hash", block.Hash), zap.Stringp("previous_hash", block.PreviousHash))
+		bf.observeStage(stageFilter, time.Since(begin))
+		bf.MetricsTransactionsSkipped.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(float64(skipped))
+	}
 
-		if previousStoredBlock.Hash == nil {
-			return false, 0
+	contents, err := bf.broadcastedContents(ctx, contents)
+	if err != nil {
+		return err
+	}
+	transactions = append(transactions, contents...)
+
+	var insertDuration time.Duration
+	begin := time.Now()
+	if repair {
+		insertDuration, err = bf.TransactionStore.RepairBlock(ctx, block, transactions, txCount)
+	} else {
+		insertDuration, err = bf.TransactionStore.CommitBlock(ctx, block, transactions, txCount)
+	}
+	if err != nil {
+		return err
+	}
+	bf.observeStage(stageInsert, insertDuration)
+	bf.observeStage(stageCommit, time.Since(begin)-insertDuration)
+
+	bf.MetricsBlocksFetched.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(1)
+	bf.MetricsTransactionsInserted.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(float64(len(transactions)))
+	return nil
+}
+
+// filterWatched keeps the transactions from or to a watched address, and the tracked broadcasts.
+// It returns the kept transactions and the number of skipped ones.
+func (bf *BlockFetcher) filterWatched(ctx context.Context, transactions []*xtz_model.Transaction) ([]*xtz_model.Transaction, int, error) {
+	if bf.watchlist == nil {
+		bf.watchlist = newWatchlist(bf.TransactionStore)
+	}
+	err := bf.watchlist.refresh(ctx)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "could not refresh watched addresses")
+	}
+
+	var (
+		kept      []*xtz_model.Transaction
+		unwatched = map[string][]*xtz_model.Transaction{}
+		hashes    []string
+	)
+	for _, tx := range transactions {
+		if bf.watchlist.watches(tx) {
+			kept = append(kept, tx)
+			continue
 		}
-		if block.PreviousHash == nil {
-			return false, 0
+		if _, ok := unwatched[tx.Hash]; !ok {
+			hashes = append(hashes, tx.Hash)
 		}
+		unwatched[tx.Hash] = append(unwatched[tx.Hash], tx)
+	}
 
-		if *previousStoredBlock.Hash == *block.PreviousHash {
-			break
-		}
+	broadcasted, err := bf.TransactionStore.GetBroadcastedHashes(ctx, hashes)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "could not get broadcasted transactions")
+	}
+	for _, hash := range broadcasted {
+		kept = append(kept, unwatched[hash]...)
+	}
 
-		// Delete transactions
-		log.Debug(ctx, "deleting block transactions", zap.Uint64("block_number", previousBlock))
-		err = bf.TransactionStore.DeleteBlockTransactions(ctx, previousBlock)
-		if err != nil {
-			return false, 0
+	return kept, len(transactions) - len(kept), nil
+}
+
+// broadcastedContents keeps the contents of the broadcasted operations.
+func (bf *BlockFetcher) broadcastedContents(ctx context.Context, contents []*xtz_model.Transaction) ([]*xtz_model.Transaction, error) {
+	var (
+		byHash = map[string][]*xtz_model.Transaction{}
+		hashes []string
+		kept   []*xtz_model.Transaction
+	)
+	for _, tx := range contents {
+		if _, ok := byHash[tx.Hash]; !ok {
+			hashes = append(hashes, tx.Hash)
 		}
-		log.Debug(ctx, "successfully deleted block transactions", zap.Uint64("block_number", previousBlock))
+		byHash[tx.Hash] = append(byHash[tx.Hash], tx)
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
 
-		blocksToDelete = append(blocksToDelete, previousBlock)
-		blockNumber--
-		previousBlock--
+	broadcasted, err := bf.TransactionStore.GetBroadcastedHashes(ctx, hashes)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get broadcasted operations")
 	}
+	for _, hash := range broadcasted {
+		kept = append(kept, byHash[hash]...)
+	}
+	return kept, nil
+}
 
-	// There was no reorgs.
-	if blockNumber == processedBlock {
-		return false, 0
+// splitTransfers splits the contents of a block into its transfers and its other contents.
+func splitTransfers(transactions []*xtz_model.Transaction) ([]*xtz_model.Transaction, []*xtz_model.Transaction) {
+	var transfers, contents []*xtz_model.Transaction
+	for _, tx := range transactions {
+		if tx.Kind == nil {
+			transfers = append(transfers, tx)
+		} else {
+			contents = append(contents, tx)
+		}
 	}
+	return transfers, contents
+}
 
-	// Delete blocks in blocktable
-	log.Debug(ctx, "deleting blocks", zap.Uint64s("blocks", blocksToDelete))
-	err := bf.BlockStore.DeleteBlocks(ctx, blocksToDelete)
-	if err != nil {
-		return false, 0
+// stopReason returns why a run should stop before its next block, if it should.
+func stopReason(ctx context.Context, deadline time.Time) string {
+	select {
+	case <-ctx.Done():
+		return "cancelled"
+	default:
+	}
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return "max run duration reached"
+	}
+	return ""
+}
+
+// observeStage records the duration of an indexing stage of a block.
+func (bf *BlockFetcher) observeStage(stage string, duration time.Duration) {
+	bf.MetricsStageDuration.With(helper.MakePrometheusLabels("coin", "XTZ", "stage", stage)).Observe(duration.Seconds())
+}
+
+// lag returns the number of blocks of the blockchain up to height that are not indexed, nextBlock being the next
+// block to index.
+func lag(height, nextBlock uint64) uint64 {
+	if nextBlock > height {
+		return 0
 	}
-	log.Debug(ctx, "successfully deleted blocks", zap.Uint64s("blocks", blocksToDelete))
+	return height - nextBlock + 1
+}
+
+// errReorgTooDeep is returned when a reorg is deeper than the maximum depth. It holds the halt to record.
+type errReorgTooDeep struct {
+	halt *xtz_model.IndexerHalt
+}
+
+func (e *errReorgTooDeep) Error() string {
+	return fmt.Sprintf("reorg detected at block %d is deeper than %d blocks", e.halt.BlockNumber, e.halt.MaxDepth)
+}
 
-	return true, previousBlock
+func reverse(s []string) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
 }
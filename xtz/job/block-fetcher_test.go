@@ -0,0 +1,27 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StopReason(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	require.Equal(t, "", stopReason(ctx, time.Time{}))
+	require.Equal(t, "", stopReason(ctx, time.Now().Add(time.Minute)))
+	require.Equal(t, "max run duration reached", stopReason(ctx, time.Now().Add(-time.Second)))
+
+	cancel()
+	require.Equal(t, "cancelled", stopReason(ctx, time.Time{}))
+}
+
+func Test_Lag(t *testing.T) {
+	require.Equal(t, uint64(0), lag(100, 101))
+	require.Equal(t, uint64(0), lag(100, 150))
+	require.Equal(t, uint64(1), lag(100, 100))
+	require.Equal(t, uint64(11), lag(100, 90))
+}
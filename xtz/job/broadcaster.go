@@ -3,6 +3,8 @@ package job
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/rand"
 	"time"
 
 	job "github.com/t-dx/go-jobs/v4"
@@ -10,6 +12,7 @@ import (
 	pool "github.com/t-dx/tg-blocksd/internal/worker"
 	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	common_service "github.com/t-dx/tg-blocksd/pkg/common/service"
+	"github.com/t-dx/tg-blocksd/pkg/common/store/cockroach"
 	"github.com/t-dx/tg-blocksd/pkg/helper"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/client"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
@@ -22,13 +25,33 @@ import (
 
 const currency = "XTZ"
 
+// Broadcaster broadcasts the pending transactions, and broadcasts them again until they are mined.
+// A transaction is broadcasted again BroadcastBlockInterval blocks after its first attempt, the interval doubling on
+// each attempt up to MaxBroadcastBlockInterval, minus a random jitter of up to half of it. A transaction attempted
+// MaxBroadcastAttempts times is not broadcasted anymore, it is left to the garbage collection.
+// The transactions whose branch has expired are timed out, once the last block at which they could be included is
+// indexed without them.
+// A node refuses a second operation of a source in the same block, so the transactions of a source are broadcasted
+// one at a time in the order of their counter, the next one once its predecessor is included, expired or timed out by
+// the garbage collection.
+// The scheduled transactions are broadcasted from their level or time, and the conditional ones once the transaction
+// they depend on, or a replacement of it, is included. A conditional transaction fails when the one it depends on
+// fails and no replacement of it can be included anymore.
 type Broadcaster struct {
+	// BlockStore gives the last indexed block the expiry is checked against. Without it, the expired transactions
+	// are not timed out, they are left to the garbage collection.
+	BlockStore       common_service.BlockStore
 	TransactionStore xtz_service.TransactionStore
 	Client           xtz_service.Client
 
 	BroadcastBlockInterval uint64
-	BatchSize              uint64
-	WorkersAmount          int
+	// MaxBroadcastBlockInterval bounds the interval between two attempts, 0 means no bound.
+	MaxBroadcastBlockInterval uint64
+	// MaxBroadcastAttempts is the number of attempts after which a transaction is not broadcasted anymore,
+	// 0 means no maximum.
+	MaxBroadcastAttempts uint64
+	BatchSize            uint64
+	WorkersAmount        int
 
 	BroadcastTrailsStore common_service.BroadcastTrailsStore
 
@@ -57,14 +80,26 @@ func (j *Broadcaster) Do(ctx context.Context, meta job.JobMeta, arg interface{})
 	}
 	blockNumber := height.Height
 
-	if j.BroadcastBlockInterval > blockNumber {
-		log.Error(ctx, "broadcast interval is greater than block number", zap.Uint64("block_number", blockNumber), zap.Uint64("broadcast_block_interval", j.BroadcastBlockInterval))
-		return nil, map[string]string{"msg": "broadcast interval is greater than block number"}, err
+	numExpired, err := j.timeoutExpired(ctx)
+	if err != nil {
+		log.Error(ctx, "could not time out expired broadcasts", zap.Error(err))
+		return nil, map[string]string{"msg": "could not time out expired broadcasts", "error": err.Error()}, err
+	}
+	if numExpired > 0 {
+		log.Info(ctx, "timed out expired broadcasts", zap.Int("num_expired", numExpired))
+	}
+
+	numFailed, err := j.failDependents(ctx, blockNumber)
+	if err != nil {
+		log.Error(ctx, "could not fail the dependents of failed broadcasts", zap.Error(err))
+		return nil, map[string]string{"msg": "could not fail the dependents of failed broadcasts", "error": err.Error()}, err
+	}
+	if numFailed > 0 {
+		log.Info(ctx, "failed the dependents of failed broadcasts", zap.Int("num_failed", numFailed))
 	}
-	broadcastedBeforeBlock := blockNumber - j.BroadcastBlockInterval
 
-	// Get 100 top pending broadcasted tx before now - offset
-	pendingTransactions, err := j.TransactionStore.GetPendingBroadcasts(ctx, broadcastedBeforeBlock, j.BatchSize)
+	// Get the pending broadcasts whose next attempt is due.
+	pendingTransactions, err := j.TransactionStore.GetPendingBroadcasts(ctx, blockNumber, j.MaxBroadcastAttempts, j.BatchSize)
 	if err != nil {
 		log.Error(ctx, "could not get pending broadcasts", zap.Error(err))
 		return nil, map[string]string{"msg": "could not get pending broadcasts", "error": err.Error()}, err
@@ -112,20 +147,17 @@ func (j *Broadcaster) Do(ctx context.Context, meta job.JobMeta, arg interface{})
 				log.Info(ctx, "broadcasting success", zap.String("hash", transaction.Hash), zap.String("raw_transaction", *transaction.RawTransaction))
 			}
 
-			err = j.BroadcastTrailsStore.InsertBroadcastTrails(ctx, []*common_model.BroadcastTrail{{
-				Currency:        currency,
-				Action:          "broadcast",
-				TransactionHash: transaction.Hash,
-				BroadcastStatus: status.String(),
-				Date:            time.Now().UTC(),
-			}})
-			if err != nil {
-				log.Error(ctx, "unable to insert trail", zap.Error(err), zap.String("currency", currency), zap.String("action", "broadcast"), zap.String("transaction_hash", transaction.Hash), zap.String("status", status.String()))
-			}
+			j.insertTrail(ctx, "broadcast", transaction.Hash, status, message, blockNumber)
+		}
+
+		attempts := transaction.BroadcastAttempts + 1
+		if j.MaxBroadcastAttempts > 0 && attempts >= j.MaxBroadcastAttempts && status != common_model.INVALID {
+			log.Warn(ctx, "max broadcast attempts reached", zap.String("hash", transaction.Hash), zap.Uint64("attempts", attempts))
 		}
 
 		// Update broadcast in storage.
-		return j.TransactionStore.UpdateBroadcast(ctx, transaction.Hash, common_model.FromStatus(status), message, blockNumber)
+		next := nextAttemptBlock(blockNumber, attempts, j.BroadcastBlockInterval, j.MaxBroadcastBlockInterval, rand.Int63n)
+		return j.TransactionStore.UpdateBroadcast(ctx, transaction.Hash, common_model.FromStatus(status), message, blockNumber, next)
 	}
 
 	var workers []pool.Worker
@@ -151,3 +183,90 @@ func (j *Broadcaster) Do(ctx context.Context, meta job.JobMeta, arg interface{})
 	log.Info(ctx, "successfully finished")
 	return nil, map[string]string{"msg": fmt.Sprintf("finished broadcasting %d transactions", len(pendingTransactions))}, nil
 }
+
+// timeoutExpired times out the broadcasts that could not be included up to the last indexed block, and returns
+// their number. The indexed blocks are used rather than the head, so that a transaction included in a block not yet
+// indexed is not timed out.
+func (j *Broadcaster) timeoutExpired(ctx context.Context) (int, error) {
+	if j.BlockStore == nil {
+		return 0, nil
+	}
+
+	lastBlock, err := j.BlockStore.GetLastBlock(ctx)
+	switch {
+	case err == cockroach.ErrNoBlock:
+		return 0, nil
+	case err != nil:
+		return 0, errors.Wrap(err, "could not get last block")
+	}
+
+	hashes, err := j.TransactionStore.GetExpiredBroadcasts(ctx, lastBlock.Number)
+	if err != nil {
+		return 0, err
+	}
+	if len(hashes) == 0 {
+		return 0, nil
+	}
+
+	err = j.TransactionStore.GarbageCollectBroadcasts(ctx, hashes)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, hash := range hashes {
+		j.insertTrail(ctx, "expire", hash, common_model.TIMEOUT, "", lastBlock.Number)
+	}
+	return len(hashes), nil
+}
+
+// failDependents fails the conditional broadcasts whose predecessor failed, then the ones depending on them in turn,
+// and returns their number.
+func (j *Broadcaster) failDependents(ctx context.Context, blockNumber uint64) (int, error) {
+	var numFailed int
+	for {
+		failed, err := j.TransactionStore.FailDependentBroadcasts(ctx)
+		if err != nil {
+			return numFailed, err
+		}
+		if len(failed) == 0 {
+			return numFailed, nil
+		}
+
+		for _, tx := range failed {
+			var message string
+			if tx.Message != nil {
+				message = *tx.Message
+			}
+			j.insertTrail(ctx, "fail", tx.Hash, common_model.INVALID, message, blockNumber)
+		}
+		numFailed += len(failed)
+	}
+}
+
+// insertTrail records an action on a broadcast at blockNumber, message being the error returned by the node if any.
+func (j *Broadcaster) insertTrail(ctx context.Context, action, hash string, status common_model.Status, message string, blockNumber uint64) {
+	entry := &model.BroadcastHistoryEntry{Hash: hash, Action: action, Status: status.String(), BlockNumber: blockNumber}
+	if message != "" {
+		entry.Message = &message
+	}
+	xtz_service.InsertBroadcastTrail(ctx, j.BroadcastTrailsStore, j.TransactionStore, entry)
+}
+
+// nextAttemptBlock returns the block from which a transaction attempted attempts times at blockNumber is broadcasted
+// again. The delay is interval doubled on each attempt after the first one, bounded by maxInterval if not 0, minus
+// a random jitter of up to half of it so that the transactions broadcasted together are spread.
+// jitter returns a random number in [0, n).
+func nextAttemptBlock(blockNumber, attempts, interval, maxInterval uint64, jitter func(n int64) int64) uint64 {
+	delay := interval
+	for i := uint64(1); i < attempts && (maxInterval == 0 || delay < maxInterval) && delay <= math.MaxUint32; i++ {
+		delay *= 2
+	}
+	if maxInterval > 0 && delay > maxInterval {
+		delay = maxInterval
+	}
+
+	if half := delay / 2; half > 0 {
+		delay -= uint64(jitter(int64(half) + 1))
+	}
+	return blockNumber + delay
+}
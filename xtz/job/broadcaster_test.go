@@ -0,0 +1,33 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NextAttemptBlock(t *testing.T) {
+	noJitter := func(n int64) int64 { return 0 }
+	maxJitter := func(n int64) int64 { return n - 1 }
+
+	tests := []struct {
+		attempts, maxInterval uint64
+		jitter                func(n int64) int64
+		expected              uint64
+	}{
+		{attempts: 1, jitter: noJitter, expected: 1010},
+		{attempts: 2, jitter: noJitter, expected: 1020},
+		{attempts: 4, jitter: noJitter, expected: 1080},
+		{attempts: 4, jitter: maxJitter, expected: 1040},
+		// Bounded.
+		{attempts: 4, maxInterval: 50, jitter: noJitter, expected: 1050},
+		{attempts: 100, maxInterval: 50, jitter: maxJitter, expected: 1025},
+		// Unbounded, the interval stops doubling instead of overflowing.
+		{attempts: 100, jitter: noJitter, expected: 1000 + 10<<29},
+	}
+
+	for i, test := range tests {
+		// Interval of 10 blocks, attempted at block 1000.
+		require.Equal(t, test.expected, nextAttemptBlock(1000, test.attempts, 10, test.maxInterval, test.jitter), i)
+	}
+}
@@ -0,0 +1,470 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	job "github.com/t-dx/go-jobs/v4"
+	"github.com/t-dx/tg-blocksd/internal/logger"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
+	"github.com/t-dx/tg-blocksd/pkg/common/service"
+	"github.com/t-dx/tg-blocksd/pkg/common/store/cockroach"
+	"github.com/t-dx/tg-blocksd/pkg/helper"
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+	"github.com/t-dx/tg-blocksd/pkg/xtz/webhook"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// notifierReorgsLimit is the maximum number of reorgs read on each run.
+const notifierReorgsLimit = 100
+
+// broadcastsOverlap is how far before the checkpoint of a webhook the broadcast status changes are read again,
+// so that the changes committed late with an earlier date are not missed.
+const broadcastsOverlap = time.Minute
+
+// WebhookNotifier emits the events of the webhooks and delivers them.
+// The transfer events of a webhook are emitted for the blocks indexed since its checkpoint, the reorg events
+// for the reorgs of the last ReorgLookback that rolled back transfers notified to it, and the broadcast events
+// for each status change of the broadcasts of its customer since its creation. Each event is logged once as a delivery, which is
+// posted to the webhook until it succeeds or MaxAttempts is reached, with an exponential backoff between attempts.
+type WebhookNotifier struct {
+	BlockStore       service.BlockStore
+	TransactionStore xtz_service.TransactionStore
+	Sender           *webhook.Sender
+
+	// MaxBlocksPerRun bounds the number of blocks scanned for each webhook and event type on each run, 0 means no bound.
+	MaxBlocksPerRun uint64
+	ReorgLookback   time.Duration
+	// BatchSize is the number of due deliveries attempted on each run, and the page size of the transfers and
+	// broadcasts read to emit the events.
+	BatchSize   uint64
+	MaxAttempts uint64
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+
+	MetricsEventsEmitted    *prometheus.CounterVec
+	MetricsWebhookDelivered *prometheus.CounterVec
+	MetricsJobDuration      *prometheus.SummaryVec
+}
+
+func (j *WebhookNotifier) Do(ctx context.Context, meta job.JobMeta, arg interface{}) (_ interface{}, _ map[string]string, err error) {
+	log := logger.With(logger.TechLog, zap.String("job_name", meta.JobName), zap.String("job_id", meta.JobID))
+
+	// Duration metrics
+	defer func(begin time.Time) {
+		status := "success"
+		if err != nil {
+			status = "failed"
+		}
+		j.MetricsJobDuration.With(helper.MakePrometheusLabels("name", meta.JobName, "status", status)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	log.Info(ctx, "job started", zap.Time("now", time.Now().UTC()))
+
+	webhooks, err := j.TransactionStore.GetActiveWebhooks(ctx)
+	if err != nil {
+		log.Error(ctx, "could not get webhooks", zap.Error(err))
+		return nil, map[string]string{"msg": "could not get webhooks", "error": err.Error()}, err
+	}
+
+	// No work to do.
+	if len(webhooks) == 0 {
+		log.Info(ctx, "no work to do")
+		return nil, map[string]string{"msg": "no work to do"}, nil
+	}
+
+	emitted, err := j.emit(ctx, webhooks)
+	if err != nil {
+		log.Error(ctx, "could not emit events", zap.Error(err))
+		return nil, map[string]string{"msg": "could not emit events", "error": err.Error()}, err
+	}
+
+	delivered, attempted, err := j.deliver(ctx, webhooks, log)
+	if err != nil {
+		log.Error(ctx, "could not deliver events", zap.Error(err))
+		return nil, map[string]string{"msg": "could not deliver events", "error": err.Error()}, err
+	}
+
+	log.Info(ctx, "successfully finished", zap.Int("num_emitted", emitted), zap.Int("num_delivered", delivered), zap.Int("num_attempted", attempted))
+
+	return nil, map[string]string{"msg": fmt.Sprintf("emitted %d events, delivered %d of %d attempts", emitted, delivered, attempted)}, nil
+}
+
+// emit logs the deliveries of the new events of the webhooks, and returns the number of events.
+func (j *WebhookNotifier) emit(ctx context.Context, webhooks []*xtz_model.Webhook) (int, error) {
+	var (
+		emitted   int
+		lastBlock *common_model.Block
+	)
+	block, err := j.BlockStore.GetLastBlock(ctx)
+	switch {
+	case err == cockroach.ErrNoBlock:
+		// Nothing is indexed yet, there are only broadcast events.
+	case err != nil:
+		return 0, errors.Wrap(err, "could not get last block")
+	default:
+		lastBlock = block
+	}
+
+	now := time.Now().UTC()
+	reorgs, _, err := j.TransactionStore.GetReorgs(ctx, now.Add(-j.ReorgLookback), now, notifierReorgsLimit, 0)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not get reorgs")
+	}
+
+	for _, w := range webhooks {
+		if len(w.Addresses) == 0 || lastBlock == nil {
+			continue
+		}
+
+		n, err := j.emitTransfers(ctx, w, xtz_model.EventTransferIndexed, lastBlock.Number, 0)
+		if err != nil {
+			return emitted, errors.Wrapf(err, "could not emit indexed transfers of webhook %s", w.ID)
+		}
+		emitted += n
+
+		if w.Confirmations > 0 {
+			n, err = j.emitTransfers(ctx, w, xtz_model.EventTransferConfirmed, lastBlock.Number, w.Confirmations)
+			if err != nil {
+				return emitted, errors.Wrapf(err, "could not emit confirmed transfers of webhook %s", w.ID)
+			}
+			emitted += n
+		}
+
+		n, err = j.emitReorged(ctx, w, reorgs)
+		if err != nil {
+			return emitted, errors.Wrapf(err, "could not emit reorged transfers of webhook %s", w.ID)
+		}
+		emitted += n
+	}
+
+	n, err := j.emitBroadcasts(ctx, webhooks)
+	if err != nil {
+		return emitted, errors.Wrap(err, "could not emit broadcast statuses")
+	}
+	return emitted + n, nil
+}
+
+// emitTransfers emits the events of the transfers of the webhook addresses with at least the given confirmations,
+// from the checkpoint of the webhook for the event type. The checkpoint is moved back to the fork by the rollback of a
+// reorg, and the events are keyed on the block hash, so that the transfers included again in the new blocks are
+// notified again.
+func (j *WebhookNotifier) emitTransfers(ctx context.Context, w *xtz_model.Webhook, eventType string, lastBlock, confirmations uint64) (int, error) {
+	progress := fmt.Sprintf("webhook:%s:%s", eventType, w.ID)
+	fromBlock, err := j.TransactionStore.GetIndexerProgress(ctx, progress)
+	if err != nil {
+		return 0, err
+	}
+	if fromBlock < w.FromBlock {
+		fromBlock = w.FromBlock
+	}
+
+	// A transaction of the last block has one confirmation.
+	if lastBlock+1 < confirmations {
+		return 0, nil
+	}
+	toBlock := lastBlock + 1 - confirmations
+	if confirmations == 0 {
+		toBlock = lastBlock
+	}
+	if j.MaxBlocksPerRun > 0 && toBlock >= fromBlock+j.MaxBlocksPerRun {
+		toBlock = fromBlock + j.MaxBlocksPerRun - 1
+	}
+	if fromBlock > toBlock {
+		return 0, nil
+	}
+
+	last, err := j.BlockStore.GetBlock(ctx, toBlock)
+	switch {
+	case err == cockroach.ErrNoBlock:
+		// Rolled back since the last block was read.
+		return 0, nil
+	case err != nil:
+		return 0, errors.Wrapf(err, "could not get block %d", toBlock)
+	}
+	lastHash := last.Hash
+
+	// The range is read by pages of BatchSize transactions, ordered by block. The total counts the operations, not
+	// their contents, so the last page is the first short one.
+	var (
+		created     uint64
+		blockHashes = map[uint64]string{}
+	)
+	for offset := uint64(0); ; offset += j.BatchSize {
+		transactions, _, err := j.TransactionStore.GetTransactionsBetweenBlocks(ctx, w.Addresses, fromBlock, toBlock, j.BatchSize, offset, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		var deliveries []*xtz_model.WebhookDelivery
+		for _, tx := range transactions {
+			if tx.BlockNumber == nil {
+				continue
+			}
+			blockHash, ok := blockHashes[*tx.BlockNumber]
+			if !ok {
+				block, err := j.BlockStore.GetBlock(ctx, *tx.BlockNumber)
+				if err != nil {
+					return 0, errors.Wrapf(err, "could not get block %d", *tx.BlockNumber)
+				}
+				if block.Hash != nil {
+					blockHash = *block.Hash
+				}
+				blockHashes[*tx.BlockNumber] = blockHash
+			}
+			delivery, err := newDelivery(w, fmt.Sprintf("%s:%s:%d:%d:%s", eventType, tx.Hash, tx.Index, *tx.BlockNumber, blockHash), &xtz_model.WebhookEvent{
+				Type:               eventType,
+				TransactionHash:    tx.Hash,
+				Index:              &tx.Index,
+				BlockNumber:        tx.BlockNumber,
+				SourceAddress:      tx.SourceAddress,
+				DestinationAddress: tx.DestinationAddress,
+				Amount:             tx.Amount,
+				Fee:                tx.Fee,
+				Status:             tx.Status,
+				Confirmations:      lastBlock - *tx.BlockNumber + 1,
+			})
+			if err != nil {
+				return 0, err
+			}
+			deliveries = append(deliveries, delivery)
+		}
+
+		n, err := j.TransactionStore.CreateWebhookDeliveries(ctx, deliveries)
+		if err != nil {
+			return 0, err
+		}
+		created += n
+
+		if len(transactions) == 0 || uint64(len(transactions)) < j.BatchSize {
+			break
+		}
+	}
+	j.MetricsEventsEmitted.With(helper.MakePrometheusLabels("coin", "XTZ", "type", eventType)).Add(float64(created))
+
+	// A reorg rolled back during the run deleted the last block of the range, the checkpoint is not moved so that
+	// the range is read again once the new blocks are indexed.
+	last, err = j.BlockStore.GetBlock(ctx, toBlock)
+	if err == cockroach.ErrNoBlock || (err == nil && !sameHash(last, lastHash)) {
+		return int(created), nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not get block %d", toBlock)
+	}
+	return int(created), j.TransactionStore.SetIndexerProgress(ctx, progress, toBlock+1)
+}
+
+// sameHash tells whether the block has the given hash.
+func sameHash(block *common_model.Block, hash *string) bool {
+	if block.Hash == nil || hash == nil {
+		return block.Hash == hash
+	}
+	return *block.Hash == *hash
+}
+
+// emitReorged emits the events of the transfers notified to the webhook as indexed, then rolled back by a reorg.
+func (j *WebhookNotifier) emitReorged(ctx context.Context, w *xtz_model.Webhook, reorgs []*xtz_model.Reorg) (int, error) {
+	var deliveries []*xtz_model.WebhookDelivery
+	for _, reorg := range reorgs {
+		hashes, err := j.TransactionStore.GetWebhookTransactionHashes(ctx, w.ID, xtz_model.EventTransferIndexed, reorg.TransactionHashes)
+		if err != nil {
+			return 0, err
+		}
+		for _, hash := range hashes {
+			delivery, err := newDelivery(w, fmt.Sprintf("%s:%s:%s", xtz_model.EventTransferReorged, reorg.ID, hash), &xtz_model.WebhookEvent{
+				Type:            xtz_model.EventTransferReorged,
+				TransactionHash: hash,
+			})
+			if err != nil {
+				return 0, err
+			}
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	created, err := j.TransactionStore.CreateWebhookDeliveries(ctx, deliveries)
+	if err != nil {
+		return 0, err
+	}
+	j.MetricsEventsEmitted.With(helper.MakePrometheusLabels("coin", "XTZ", "type", xtz_model.EventTransferReorged)).Add(float64(created))
+	return int(created), nil
+}
+
+// emitBroadcasts emits an event for each status of the broadcasts of the customers of the webhooks.
+// The broadcasts of a webhook are read by pages of BatchSize from its checkpoint, the date of the last status change
+// read, which is set at the creation of the webhook at first. The changes of the last broadcastsOverlap are read
+// again, so that the ones committed late are not missed, and the statuses already notified are ignored by the event keys.
+func (j *WebhookNotifier) emitBroadcasts(ctx context.Context, webhooks []*xtz_model.Webhook) (int, error) {
+	var emitted int
+	for _, w := range webhooks {
+		n, err := j.emitWebhookBroadcasts(ctx, w)
+		if err != nil {
+			return emitted, errors.Wrapf(err, "could not emit broadcast statuses of webhook %s", w.ID)
+		}
+		emitted += n
+	}
+	j.MetricsEventsEmitted.With(helper.MakePrometheusLabels("coin", "XTZ", "type", xtz_model.EventBroadcastStatus)).Add(float64(emitted))
+	return emitted, nil
+}
+
+// emitWebhookBroadcasts emits the events of the broadcast status changes of the customer of the webhook since its checkpoint.
+func (j *WebhookNotifier) emitWebhookBroadcasts(ctx context.Context, w *xtz_model.Webhook) (int, error) {
+	if j.BatchSize == 0 {
+		return 0, nil
+	}
+
+	// The checkpoint is stored as unix microseconds.
+	progress := fmt.Sprintf("webhook:%s:%s", xtz_model.EventBroadcastStatus, w.ID)
+	micros, err := j.TransactionStore.GetIndexerProgress(ctx, progress)
+	if err != nil {
+		return 0, err
+	}
+	var checkpoint time.Time
+	switch {
+	case micros > 0:
+		checkpoint = time.Unix(0, int64(micros)*int64(time.Microsecond)).UTC()
+	case w.CreatedAt != nil:
+		checkpoint = *w.CreatedAt
+	default:
+		checkpoint = time.Now().UTC()
+	}
+
+	var (
+		created   uint64
+		since     = checkpoint.Add(-broadcastsOverlap)
+		sinceHash string
+	)
+	for {
+		broadcasts, err := j.TransactionStore.GetCustomerBroadcasts(ctx, w.CustomerID, since, sinceHash, j.BatchSize)
+		if err != nil {
+			return 0, err
+		}
+
+		var deliveries []*xtz_model.WebhookDelivery
+		for _, tx := range broadcasts {
+			var blockNumber *uint64
+			if tx.BlockNumber != nil && int64(*tx.BlockNumber) >= 0 {
+				blockNumber = tx.BlockNumber
+			}
+			delivery, err := newDelivery(w, fmt.Sprintf("%s:%s:%s", xtz_model.EventBroadcastStatus, tx.Hash, tx.Status), &xtz_model.WebhookEvent{
+				Type:            xtz_model.EventBroadcastStatus,
+				TransactionHash: tx.Hash,
+				BlockNumber:     blockNumber,
+				Status:          tx.Status,
+			})
+			if err != nil {
+				return 0, err
+			}
+			deliveries = append(deliveries, delivery)
+
+			if tx.StatusUpdatedAt != nil {
+				since, sinceHash = *tx.StatusUpdatedAt, tx.Hash
+			}
+		}
+
+		n, err := j.TransactionStore.CreateWebhookDeliveries(ctx, deliveries)
+		if err != nil {
+			return 0, err
+		}
+		created += n
+
+		if uint64(len(broadcasts)) < j.BatchSize {
+			break
+		}
+	}
+
+	if since.After(checkpoint) {
+		checkpoint = since
+	}
+	return int(created), j.TransactionStore.SetIndexerProgress(ctx, progress, uint64(checkpoint.UnixNano()/int64(time.Microsecond)))
+}
+
+// deliver attempts the due deliveries. It returns the number of successful and attempted deliveries.
+// A failed attempt is not an error of the job, it is recorded on the delivery.
+func (j *WebhookNotifier) deliver(ctx context.Context, webhooks []*xtz_model.Webhook, log *logger.ContextLogger) (int, int, error) {
+	var byID = make(map[string]*xtz_model.Webhook, len(webhooks))
+	for _, w := range webhooks {
+		byID[w.ID] = w
+	}
+
+	deliveries, err := j.TransactionStore.GetDueWebhookDeliveries(ctx, j.BatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var delivered, attempted int
+	for _, delivery := range deliveries {
+		w, ok := byID[delivery.WebhookID]
+		if !ok {
+			// Webhook created after this run started.
+			continue
+		}
+
+		statusCode, sendErr := j.Sender.Send(ctx, w, delivery)
+		j.recordAttempt(delivery, statusCode, sendErr, time.Now().UTC())
+		if sendErr != nil {
+			log.Warn(ctx, "could not deliver event", zap.String("webhook_id", w.ID), zap.String("delivery_id", delivery.ID), zap.Uint64("attempts", delivery.Attempts), zap.String("status", delivery.Status), zap.Error(sendErr))
+		} else {
+			delivered++
+		}
+		attempted++
+
+		err = j.TransactionStore.UpdateWebhookDelivery(ctx, delivery)
+		if err != nil {
+			return delivered, attempted, err
+		}
+		j.MetricsWebhookDelivered.With(helper.MakePrometheusLabels("coin", "XTZ", "status", delivery.Status)).Add(1)
+	}
+	return delivered, attempted, nil
+}
+
+// recordAttempt updates the delivery with the outcome of an attempt. A failed delivery is scheduled again
+// after a backoff, until MaxAttempts is reached.
+func (j *WebhookNotifier) recordAttempt(delivery *xtz_model.WebhookDelivery, statusCode int, sendErr error, now time.Time) {
+	delivery.Attempts++
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+
+	if sendErr == nil {
+		delivery.Status = xtz_model.DeliveryDelivered
+		delivery.LastError = nil
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	msg := sendErr.Error()
+	delivery.LastError = &msg
+	if j.MaxAttempts > 0 && delivery.Attempts >= j.MaxAttempts {
+		delivery.Status = xtz_model.DeliveryFailed
+		return
+	}
+	next := now.Add(webhook.Backoff(delivery.Attempts, j.BackoffBase, j.BackoffMax))
+	delivery.NextAttemptAt = &next
+}
+
+// newDelivery returns the pending delivery of an event to the webhook.
+func newDelivery(w *xtz_model.Webhook, eventKey string, event *xtz_model.WebhookEvent) (*xtz_model.WebhookDelivery, error) {
+	event.WebhookID = w.ID
+	event.CreatedAt = time.Now().UTC()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xtz_model.WebhookDelivery{
+		WebhookID:       w.ID,
+		EventType:       event.Type,
+		EventKey:        eventKey,
+		TransactionHash: event.TransactionHash,
+		Payload:         string(payload),
+		Status:          xtz_model.DeliveryPending,
+	}, nil
+}
@@ -0,0 +1,197 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
+	"github.com/t-dx/tg-blocksd/pkg/common/service"
+	"github.com/t-dx/tg-blocksd/pkg/common/store/cockroach"
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RecordAttempt(t *testing.T) {
+	var (
+		j   = &WebhookNotifier{MaxAttempts: 3, BackoffBase: time.Minute, BackoffMax: time.Hour}
+		now = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	delivery := &xtz_model.WebhookDelivery{Status: xtz_model.DeliveryPending}
+
+	// Failed attempts are retried with a backoff.
+	j.recordAttempt(delivery, 500, errors.New("webhook responded with status 500"), now)
+	require.Equal(t, xtz_model.DeliveryPending, delivery.Status)
+	require.Equal(t, uint64(1), delivery.Attempts)
+	require.Equal(t, 500, *delivery.LastStatusCode)
+	require.Equal(t, now.Add(time.Minute), *delivery.NextAttemptAt)
+
+	j.recordAttempt(delivery, 0, errors.New("connection refused"), now)
+	require.Equal(t, xtz_model.DeliveryPending, delivery.Status)
+	require.Equal(t, now.Add(2*time.Minute), *delivery.NextAttemptAt)
+	require.Equal(t, "connection refused", *delivery.LastError)
+
+	// The last attempt fails the delivery.
+	j.recordAttempt(delivery, 500, errors.New("webhook responded with status 500"), now)
+	require.Equal(t, xtz_model.DeliveryFailed, delivery.Status)
+	require.Equal(t, uint64(3), delivery.Attempts)
+
+	// A successful attempt delivers it.
+	delivery = &xtz_model.WebhookDelivery{Status: xtz_model.DeliveryPending, Attempts: 1}
+	j.recordAttempt(delivery, 204, nil, now)
+	require.Equal(t, xtz_model.DeliveryDelivered, delivery.Status)
+	require.Equal(t, now, *delivery.DeliveredAt)
+	require.Nil(t, delivery.LastError)
+}
+
+func Test_EmitWebhookBroadcasts(t *testing.T) {
+	var (
+		created = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		store   = &mockNotifierStore{progress: map[string]uint64{}}
+		j       = &WebhookNotifier{TransactionStore: store, BatchSize: 2}
+		w       = &xtz_model.Webhook{ID: "webhook", CustomerID: "customer", CreatedAt: &created}
+	)
+	for i, hash := range []string{"op1", "op2", "op3"} {
+		updatedAt := created.Add(time.Duration(i) * time.Second)
+		store.broadcasts = append(store.broadcasts, &xtz_model.Transaction{Hash: hash, Status: "pending", StatusUpdatedAt: &updatedAt})
+	}
+
+	// A new webhook starts from its creation, the broadcasts are read by pages.
+	n, err := j.emitWebhookBroadcasts(context.Background(), w)
+	require.Nil(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, []time.Time{created.Add(-broadcastsOverlap), created.Add(time.Second)}, store.since)
+	require.Equal(t, uint64(created.Add(2*time.Second).UnixNano()/1000), store.progress["webhook:broadcast.status:webhook"])
+
+	// The next run starts from the checkpoint, the statuses already notified are not emitted again.
+	store.since = nil
+	n, err = j.emitWebhookBroadcasts(context.Background(), w)
+	require.Nil(t, err)
+	require.Equal(t, 0, n)
+	require.Equal(t, created.Add(2*time.Second-broadcastsOverlap), store.since[0])
+}
+
+type mockNotifierStore struct {
+	xtz_service.TransactionStore
+
+	progress   map[string]uint64
+	broadcasts []*xtz_model.Transaction
+	transfers  []*xtz_model.Transaction
+	keys       map[string]bool
+	since      []time.Time
+}
+
+func (m *mockNotifierStore) GetIndexerProgress(ctx context.Context, name string) (uint64, error) {
+	return m.progress[name], nil
+}
+
+func (m *mockNotifierStore) SetIndexerProgress(ctx context.Context, name string, next uint64) error {
+	m.progress[name] = next
+	return nil
+}
+
+func (m *mockNotifierStore) GetTransactionsBetweenBlocks(ctx context.Context, addresses []string, fromBlock, toBlock uint64, limit, offset uint64, confirmed *xtz_model.ConfirmedFilter) ([]*xtz_model.Transaction, uint64, error) {
+	var (
+		res    []*xtz_model.Transaction
+		hashes = map[string]bool{}
+	)
+	for _, tx := range m.transfers {
+		if *tx.BlockNumber >= fromBlock && *tx.BlockNumber <= toBlock {
+			res = append(res, tx)
+			hashes[tx.Hash] = true
+		}
+	}
+	// The total counts the operations.
+	total := uint64(len(hashes))
+	if offset >= uint64(len(res)) {
+		return nil, total, nil
+	}
+	res = res[offset:]
+	if uint64(len(res)) > limit {
+		res = res[:limit]
+	}
+	return res, total, nil
+}
+
+func (m *mockNotifierStore) GetCustomerBroadcasts(ctx context.Context, customerID string, since time.Time, sinceHash string, limit uint64) ([]*xtz_model.Transaction, error) {
+	m.since = append(m.since, since)
+
+	var res []*xtz_model.Transaction
+	for _, tx := range m.broadcasts {
+		if tx.StatusUpdatedAt.After(since) || (tx.StatusUpdatedAt.Equal(since) && tx.Hash > sinceHash) {
+			res = append(res, tx)
+		}
+		if uint64(len(res)) == limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+func (m *mockNotifierStore) CreateWebhookDeliveries(ctx context.Context, deliveries []*xtz_model.WebhookDelivery) (uint64, error) {
+	if m.keys == nil {
+		m.keys = map[string]bool{}
+	}
+	var created uint64
+	for _, delivery := range deliveries {
+		if !m.keys[delivery.EventKey] {
+			m.keys[delivery.EventKey] = true
+			created++
+		}
+	}
+	return created, nil
+}
+
+func Test_EmitTransfers(t *testing.T) {
+	var (
+		store  = &mockNotifierStore{progress: map[string]uint64{}}
+		blocks = &mockNotifierBlockStore{hashes: map[uint64]string{10: "BLa10", 11: "BLa11"}}
+		j      = &WebhookNotifier{BlockStore: blocks, TransactionStore: store, BatchSize: 2, MetricsEventsEmitted: newEventsEmitted()}
+		w      = &xtz_model.Webhook{ID: "webhook", Addresses: []string{"tz1a"}, FromBlock: 10}
+		ten    = uint64(10)
+		eleven = uint64(11)
+	)
+	// A batched operation has two contents, the pages are not bounded by the number of operations.
+	store.transfers = []*xtz_model.Transaction{
+		{Hash: "op1", BlockNumber: &ten},
+		{Hash: "op2", BlockNumber: &ten},
+		{Hash: "op2", Index: 1, BlockNumber: &ten},
+		{Hash: "op3", BlockNumber: &eleven},
+	}
+
+	n, err := j.emitTransfers(context.Background(), w, xtz_model.EventTransferIndexed, 11, 0)
+	require.Nil(t, err)
+	require.Equal(t, 4, n)
+	require.Equal(t, uint64(12), store.progress["webhook:transfer.indexed:webhook"])
+
+	// Block 11 is reorged with op3 included again at the same level, the rollback moves the checkpoint back.
+	blocks.hashes[11] = "BLb11"
+	store.progress["webhook:transfer.indexed:webhook"] = 11
+	n, err = j.emitTransfers(context.Background(), w, xtz_model.EventTransferIndexed, 11, 0)
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, uint64(12), store.progress["webhook:transfer.indexed:webhook"])
+}
+
+func newEventsEmitted() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "events_emitted"}, []string{"coin", "type"})
+}
+
+type mockNotifierBlockStore struct {
+	service.BlockStore
+
+	hashes map[uint64]string
+}
+
+func (m *mockNotifierBlockStore) GetBlock(ctx context.Context, n uint64) (*common_model.Block, error) {
+	hash, ok := m.hashes[n]
+	if !ok {
+		return nil, cockroach.ErrNoBlock
+	}
+	return &common_model.Block{Number: n, Hash: &hash}, nil
+}
@@ -0,0 +1,124 @@
+package job
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+
+	"github.com/pkg/errors"
+)
+
+// prefetchedBlock is a block downloaded and parsed ahead of its processing.
+type prefetchedBlock struct {
+	block        *common_model.Block
+	transactions []*xtz_model.Transaction
+	err          error
+	// fetchDuration is the time taken to download the block, and parseDuration the time taken to parse it.
+	fetchDuration time.Duration
+	parseDuration time.Duration
+}
+
+// prefetcher downloads and parses the blocks of a range concurrently, and hands them
+// over in increasing level order.
+// At most window blocks are fetched at the same time, and no new fetch is started while
+// more than maxTransactions transactions are waiting to be processed.
+type prefetcher struct {
+	client          xtz_service.Client
+	maxTransactions int64
+
+	results  chan chan *prefetchedBlock
+	released chan struct{}
+	buffered int64
+	cancel   context.CancelFunc
+
+	// fetchDuration and parseDuration are the times taken to download and to parse the last block handed over by next.
+	fetchDuration time.Duration
+	parseDuration time.Duration
+}
+
+func newPrefetcher(ctx context.Context, client xtz_service.Client, from, to uint64, window, maxTransactions int) *prefetcher {
+	if window < 1 {
+		window = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &prefetcher{
+		client:          client,
+		maxTransactions: int64(maxTransactions),
+		// The block being waited for by the consumer is in flight as well, hence window - 1.
+		results:  make(chan chan *prefetchedBlock, window-1),
+		released: make(chan struct{}, 1),
+		cancel:   cancel,
+	}
+
+	go p.run(ctx, from, to)
+
+	return p
+}
+
+func (p *prefetcher) run(ctx context.Context, from, to uint64) {
+	defer close(p.results)
+
+	for blockNumber := from; blockNumber <= to; blockNumber++ {
+		// Wait for the consumer to process buffered blocks before fetching further ahead.
+		for p.maxTransactions > 0 && atomic.LoadInt64(&p.buffered) >= p.maxTransactions {
+			select {
+			case <-p.released:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		resc := make(chan *prefetchedBlock, 1)
+		select {
+		case p.results <- resc:
+		case <-ctx.Done():
+			return
+		}
+
+		go func(blockNumber uint64) {
+			begin := time.Now()
+			block, transactions, parseDuration, err := p.client.GetBlockWithTransactions(ctx, blockNumber)
+			atomic.AddInt64(&p.buffered, int64(len(transactions)))
+			resc <- &prefetchedBlock{block: block, transactions: transactions, err: err, fetchDuration: time.Since(begin) - parseDuration, parseDuration: parseDuration}
+		}(blockNumber)
+	}
+}
+
+// next returns the next block of the range. It must not be called more often than there are blocks in the range.
+func (p *prefetcher) next(ctx context.Context) (*common_model.Block, []*xtz_model.Transaction, error) {
+	var resc chan *prefetchedBlock
+	select {
+	case resc = <-p.results:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	if resc == nil {
+		return nil, nil, errors.New("prefetcher is stopped")
+	}
+
+	var res *prefetchedBlock
+	select {
+	case res = <-resc:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+
+	atomic.AddInt64(&p.buffered, -int64(len(res.transactions)))
+	p.fetchDuration, p.parseDuration = res.fetchDuration, res.parseDuration
+	select {
+	case p.released <- struct{}{}:
+	default:
+	}
+
+	return res.block, res.transactions, res.err
+}
+
+// stop cancels the fetches in flight. The blocks not yet handed over are discarded.
+func (p *prefetcher) stop() {
+	p.cancel()
+}
@@ -0,0 +1,86 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PrefetcherOrder(t *testing.T) {
+	client := &mockPrefetchClient{delay: func(blockNumber uint64) time.Duration {
+		// Later blocks are faster to fetch, so they complete out of order.
+		return time.Duration(20-blockNumber%20) * time.Millisecond
+	}}
+
+	ctx := context.Background()
+	pf := newPrefetcher(ctx, client, 100, 139, 8, 0)
+	defer pf.stop()
+
+	for blockNumber := uint64(100); blockNumber <= 139; blockNumber++ {
+		block, transactions, err := pf.next(ctx)
+		require.Nil(t, err)
+		require.Equal(t, blockNumber, block.Number)
+		require.Len(t, transactions, 1)
+		require.Equal(t, blockNumber, *transactions[0].BlockNumber)
+	}
+	require.LessOrEqual(t, client.maxInFlight, int64(8))
+}
+
+func Test_PrefetcherMaxTransactions(t *testing.T) {
+	client := &mockPrefetchClient{}
+
+	ctx := context.Background()
+	pf := newPrefetcher(ctx, client, 0, 99, 4, 1)
+	defer pf.stop()
+
+	// Nothing is consumed: the prefetcher stops once a transaction is buffered, with at most one window in flight.
+	time.Sleep(50 * time.Millisecond)
+	require.LessOrEqual(t, atomic.LoadInt64(&client.calls), int64(4))
+
+	for blockNumber := uint64(0); blockNumber <= 99; blockNumber++ {
+		block, _, err := pf.next(ctx)
+		require.Nil(t, err)
+		require.Equal(t, blockNumber, block.Number)
+	}
+}
+
+type mockPrefetchClient struct {
+	xtz_service.Client
+
+	delay func(blockNumber uint64) time.Duration
+
+	mu          sync.Mutex
+	inFlight    int64
+	maxInFlight int64
+	calls       int64
+}
+
+func (m *mockPrefetchClient) GetBlockWithTransactions(ctx context.Context, blockNumber uint64) (*common_model.Block, []*xtz_model.Transaction, time.Duration, error) {
+	atomic.AddInt64(&m.calls, 1)
+
+	m.mu.Lock()
+	m.inFlight++
+	if m.inFlight > m.maxInFlight {
+		m.maxInFlight = m.inFlight
+	}
+	m.mu.Unlock()
+
+	if m.delay != nil {
+		time.Sleep(m.delay(blockNumber))
+	}
+
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+
+	number := blockNumber
+	return &common_model.Block{Number: blockNumber}, []*xtz_model.Transaction{{BlockNumber: &number}}, 0, nil
+}
@@ -0,0 +1,207 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	job "github.com/t-dx/go-jobs/v4"
+	"github.com/t-dx/tg-blocksd/internal/logger"
+	"github.com/t-dx/tg-blocksd/pkg/common/service"
+	"github.com/t-dx/tg-blocksd/pkg/common/store/cockroach"
+	"github.com/t-dx/tg-blocksd/pkg/helper"
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Reconciler compares the balances of the indexed addresses on the node with the balances derived from their indexed
+// history, both at the last stored block, and stores the discrepancies.
+// The indexed history of an address may not go back to its creation, so the node balance of an address at its first
+// reconciliation is recorded as its baseline, and the following reconciliations only check the indexed transactions
+// after it.
+// The addresses are split in NumChunks chunks of 'xtz_chunk'. Each run leases up to MaxChunks chunks not processed
+// since Interval, so several reconcilers can share the load. The lease is renewed after each batch of BatchSize
+// addresses. A chunk whose lease expires, e.g. because its reconciler died, can be leased again by another one.
+type Reconciler struct {
+	BlockStore       service.BlockStore
+	TransactionStore xtz_service.TransactionStore
+	Client           xtz_service.Client
+
+	NumChunks uint64
+	// MaxChunks bounds the number of chunks processed on each run, 0 means no bound.
+	MaxChunks uint64
+	// BatchSize is the number of addresses whose node balances are fetched between two renewals of the lease,
+	// 0 means the whole chunk at once. The lease should be long enough to reconcile a batch.
+	BatchSize uint64
+	Lease     time.Duration
+	Interval  time.Duration
+
+	MetricsAddressesReconciled *prometheus.CounterVec
+	MetricsBalanceDiscrepancy  *prometheus.CounterVec
+	MetricsJobDuration         *prometheus.SummaryVec
+}
+
+func (j *Reconciler) Do(ctx context.Context, meta job.JobMeta, arg interface{}) (_ interface{}, _ map[string]string, err error) {
+	log := logger.With(logger.TechLog, zap.String("job_name", meta.JobName), zap.String("job_id", meta.JobID))
+
+	// Duration metrics
+	defer func(begin time.Time) {
+		status := "success"
+		if err != nil {
+			status = "failed"
+		}
+		j.MetricsJobDuration.With(helper.MakePrometheusLabels("name", meta.JobName, "status", status)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	log.Info(ctx, "job started", zap.Time("now", time.Now().UTC()))
+
+	lastBlock, err := j.BlockStore.GetLastBlock(ctx)
+	switch {
+	case err == cockroach.ErrNoBlock:
+		log.Info(ctx, "no work to do")
+		return nil, map[string]string{"msg": "no work to do"}, nil
+	case err != nil:
+		log.Error(ctx, "could not get last block", zap.Error(err))
+		return nil, map[string]string{"msg": "could not get last block", "error": err.Error()}, err
+	}
+
+	err = j.TransactionStore.AssignAddressChunks(ctx, j.NumChunks)
+	if err != nil {
+		log.Error(ctx, "could not assign address chunks", zap.Error(err))
+		return nil, map[string]string{"msg": "could not assign address chunks", "error": err.Error()}, err
+	}
+
+	// The job ID is unique, it identifies the leases of this run.
+	var lockID = meta.JobID
+
+	var numChunks, numDiscrepancies int
+	for j.MaxChunks == 0 || uint64(numChunks) < j.MaxChunks {
+		chunkID, ok, err := j.TransactionStore.LeaseChunk(ctx, lockID, j.Lease, time.Now().Add(-j.Interval))
+		if err != nil {
+			log.Error(ctx, "could not lease chunk", zap.Error(err))
+			return nil, map[string]string{"msg": "could not lease chunk", "error": err.Error()}, err
+		}
+		if !ok {
+			break
+		}
+
+		discrepancies, err := j.reconcileChunk(ctx, chunkID, lockID, lastBlock.Number, log)
+		if releaseErr := j.TransactionStore.ReleaseChunk(ctx, chunkID, lockID, err == nil); releaseErr != nil {
+			log.Error(ctx, "could not release chunk", zap.Uint64("chunk_id", chunkID), zap.Error(releaseErr))
+		}
+		if err != nil {
+			log.Error(ctx, "could not reconcile chunk", zap.Uint64("chunk_id", chunkID), zap.Error(err))
+			return nil, map[string]string{"msg": "could not reconcile chunk", "error": err.Error()}, err
+		}
+
+		numChunks++
+		numDiscrepancies += discrepancies
+	}
+
+	log.Info(ctx, "successfully finished", zap.Int("num_chunks", numChunks), zap.Int("num_discrepancies", numDiscrepancies))
+
+	return nil, map[string]string{"msg": fmt.Sprintf("reconciled %d chunks at block %d, %d discrepancies", numChunks, lastBlock.Number, numDiscrepancies)}, nil
+}
+
+// reconcileChunk reconciles the addresses of the chunk by batches of BatchSize at blockNumber, renewing the lease
+// between batches, and returns the number of discrepancies.
+func (j *Reconciler) reconcileChunk(ctx context.Context, chunkID uint64, lockID string, blockNumber uint64, log *logger.ContextLogger) (int, error) {
+	addresses, err := j.TransactionStore.GetChunkAddresses(ctx, chunkID)
+	if err != nil {
+		return 0, err
+	}
+
+	var batchSize = uint64(len(addresses))
+	if j.BatchSize > 0 && j.BatchSize < batchSize {
+		batchSize = j.BatchSize
+	}
+
+	var numDiscrepancies int
+	for from := uint64(0); from < uint64(len(addresses)); from += batchSize {
+		if from > 0 {
+			ok, err := j.TransactionStore.RenewChunk(ctx, chunkID, lockID, j.Lease)
+			if err != nil {
+				return numDiscrepancies, err
+			}
+			if !ok {
+				return numDiscrepancies, errors.Errorf("lease of chunk %d expired", chunkID)
+			}
+		}
+
+		to := from + batchSize
+		if to > uint64(len(addresses)) {
+			to = uint64(len(addresses))
+		}
+		discrepancies, err := j.reconcile(ctx, chunkID, addresses[from:to], blockNumber, log)
+		if err != nil {
+			return numDiscrepancies, err
+		}
+		numDiscrepancies += discrepancies
+	}
+	return numDiscrepancies, nil
+}
+
+// reconcile compares the balances of the addresses at blockNumber, and returns the number of discrepancies.
+// The addresses without baseline get their node balance as baseline, the addresses whose node balance could not be
+// fetched are skipped.
+func (j *Reconciler) reconcile(ctx context.Context, chunkID uint64, addresses []string, blockNumber uint64, log *logger.ContextLogger) (int, error) {
+	nodeBalances, err := j.Client.GetBalances(ctx, addresses, blockNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	indexedBalances, err := j.TransactionStore.GetIndexedBalances(ctx, addresses, blockNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		baselines     = []*xtz_model.BalanceBaseline{}
+		discrepancies = []*xtz_model.BalanceDiscrepancy{}
+	)
+	for _, balance := range nodeBalances {
+		if balance.Error != nil || balance.BalanceAtBlock == nil {
+			log.Warn(ctx, "could not get node balance", zap.String("address", balance.Address), zap.Uint64("block_number", blockNumber), zap.Error(balance.Error))
+			j.MetricsAddressesReconciled.With(helper.MakePrometheusLabels("coin", "XTZ", "status", "failed")).Add(1)
+			continue
+		}
+
+		indexedBalance, ok := indexedBalances[balance.Address]
+		if !ok {
+			baselines = append(baselines, &xtz_model.BalanceBaseline{Address: balance.Address, BlockNumber: blockNumber, Balance: balance.BalanceAtBlock})
+			j.MetricsAddressesReconciled.With(helper.MakePrometheusLabels("coin", "XTZ", "status", "baseline")).Add(1)
+			continue
+		}
+		j.MetricsAddressesReconciled.With(helper.MakePrometheusLabels("coin", "XTZ", "status", "success")).Add(1)
+
+		if indexedBalance.Cmp(balance.BalanceAtBlock) == 0 {
+			continue
+		}
+
+		discrepancy := &xtz_model.BalanceDiscrepancy{
+			Address:        balance.Address,
+			ChunkID:        chunkID,
+			BlockNumber:    blockNumber,
+			NodeBalance:    balance.BalanceAtBlock,
+			IndexedBalance: indexedBalance,
+		}
+		log.Warn(ctx, "balance discrepancy", zap.String("address", balance.Address), zap.Uint64("block_number", blockNumber), zap.String("node_balance", discrepancy.NodeBalance.String()), zap.String("indexed_balance", discrepancy.IndexedBalance.String()))
+		j.MetricsBalanceDiscrepancy.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(1)
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	err = j.TransactionStore.CreateBalanceBaselines(ctx, baselines)
+	if err != nil {
+		return 0, err
+	}
+
+	err = j.TransactionStore.CreateBalanceDiscrepancies(ctx, discrepancies)
+	if err != nil {
+		return 0, err
+	}
+	return len(discrepancies), nil
+}
@@ -0,0 +1,96 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	job "github.com/t-dx/go-jobs/v4"
+	"github.com/t-dx/tg-blocksd/internal/logger"
+	"github.com/t-dx/tg-blocksd/pkg/helper"
+	"github.com/t-dx/tg-blocksd/pkg/xtz/outbox"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// OutboxRelay publishes the events of the 'xtz_outbox' table to a sink, in the order of their IDs, by batches of
+// BatchSize. See GetOutboxEvents for the guarantees of this order.
+// A batch is marked as published once the sink accepted it. If marking it fails, the batch is published again on
+// the next run, so consumers should deduplicate the events by ID. The relay must not run concurrently with itself.
+// The published events older than Retention are deleted, unless Retention is 0.
+type OutboxRelay struct {
+	TransactionStore xtz_service.TransactionStore
+	Sink             outbox.Sink
+
+	BatchSize uint64
+	// MaxBatches bounds the number of batches published on each run, 0 means no bound.
+	MaxBatches uint64
+	Retention  time.Duration
+
+	MetricsEventsPublished *prometheus.CounterVec
+	MetricsJobDuration     *prometheus.SummaryVec
+}
+
+func (j *OutboxRelay) Do(ctx context.Context, meta job.JobMeta, arg interface{}) (_ interface{}, _ map[string]string, err error) {
+	log := logger.With(logger.TechLog, zap.String("job_name", meta.JobName), zap.String("job_id", meta.JobID))
+
+	// Duration metrics
+	defer func(begin time.Time) {
+		status := "success"
+		if err != nil {
+			status = "failed"
+		}
+		j.MetricsJobDuration.With(helper.MakePrometheusLabels("name", meta.JobName, "status", status)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	log.Info(ctx, "job started", zap.Time("now", time.Now().UTC()))
+
+	var published int
+	for batch := uint64(0); j.MaxBatches == 0 || batch < j.MaxBatches; batch++ {
+		events, err := j.TransactionStore.GetOutboxEvents(ctx, j.BatchSize)
+		if err != nil {
+			log.Error(ctx, "could not get outbox events", zap.Error(err))
+			return nil, map[string]string{"msg": "could not get outbox events", "error": err.Error()}, err
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		err = j.Sink.Publish(ctx, events)
+		if err != nil {
+			log.Error(ctx, "could not publish outbox events", zap.Uint64("from_id", events[0].ID), zap.Int("num_events", len(events)), zap.Error(err))
+			return nil, map[string]string{"msg": "could not publish outbox events", "error": err.Error()}, err
+		}
+
+		var ids = make([]uint64, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		err = j.TransactionStore.MarkOutboxPublished(ctx, ids)
+		if err != nil {
+			log.Error(ctx, "could not mark outbox events as published", zap.Uint64("from_id", events[0].ID), zap.Int("num_events", len(events)), zap.Error(err))
+			return nil, map[string]string{"msg": "could not mark outbox events as published", "error": err.Error()}, err
+		}
+
+		published += len(events)
+		j.MetricsEventsPublished.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(float64(len(events)))
+
+		if uint64(len(events)) < j.BatchSize {
+			break
+		}
+	}
+
+	if j.Retention > 0 {
+		err = j.TransactionStore.DeletePublishedOutboxEvents(ctx, time.Now().Add(-j.Retention))
+		if err != nil {
+			log.Error(ctx, "could not delete published outbox events", zap.Error(err))
+			return nil, map[string]string{"msg": "could not delete published outbox events", "error": err.Error()}, err
+		}
+	}
+
+	log.Info(ctx, "successfully finished", zap.Int("num_published", published))
+
+	return nil, map[string]string{"msg": fmt.Sprintf("published %d events", published)}, nil
+}
@@ -0,0 +1,218 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	job "github.com/t-dx/go-jobs/v4"
+	"github.com/t-dx/tg-blocksd/internal/logger"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
+	"github.com/t-dx/tg-blocksd/pkg/common/service"
+	"github.com/t-dx/tg-blocksd/pkg/common/store/cockroach"
+	"github.com/t-dx/tg-blocksd/pkg/helper"
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// BalanceSnapshotter records the balances of all the indexed addresses at the last block of each UTC day or of each
+// cycle, depending on Boundary, from StartBlock. The day boundaries are found from the timestamps of 'xtz_block',
+// the cycle boundaries from BlocksPerCycle and CycleStartBlock, the first block of any cycle with that length.
+// Each run snapshots up to MaxSnapshots boundaries, the balances being fetched from the node by batches of BatchSize
+// addresses. Snapshotting old boundaries requires an archive node. An address whose balance could not be fetched gets
+// a snapshot recording the error, so that it does not hold back the other addresses.
+type BalanceSnapshotter struct {
+	BlockStore       service.BlockStore
+	TransactionStore xtz_service.TransactionStore
+	Client           xtz_service.Client
+
+	Boundary        string
+	BlocksPerCycle  uint64
+	CycleStartBlock uint64
+	StartBlock      uint64
+	BatchSize       uint64
+	// MaxSnapshots bounds the number of boundaries snapshotted on each run, 0 means no bound.
+	MaxSnapshots uint64
+
+	MetricsSnapshotsCreated *prometheus.CounterVec
+	MetricsJobDuration      *prometheus.SummaryVec
+}
+
+func (j *BalanceSnapshotter) Do(ctx context.Context, meta job.JobMeta, arg interface{}) (_ interface{}, _ map[string]string, err error) {
+	log := logger.With(logger.TechLog, zap.String("job_name", meta.JobName), zap.String("job_id", meta.JobID))
+
+	// Duration metrics
+	defer func(begin time.Time) {
+		status := "success"
+		if err != nil {
+			status = "failed"
+		}
+		j.MetricsJobDuration.With(helper.MakePrometheusLabels("name", meta.JobName, "status", status)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	log.Info(ctx, "job started", zap.Time("now", time.Now().UTC()))
+
+	lastBlock, err := j.BlockStore.GetLastBlock(ctx)
+	switch {
+	case err == cockroach.ErrNoBlock:
+		log.Info(ctx, "no work to do")
+		return nil, map[string]string{"msg": "no work to do"}, nil
+	case err != nil:
+		log.Error(ctx, "could not get last block", zap.Error(err))
+		return nil, map[string]string{"msg": "could not get last block", "error": err.Error()}, err
+	}
+
+	var progress = "snapshot:" + j.Boundary
+	fromBlock, err := j.TransactionStore.GetIndexerProgress(ctx, progress)
+	if err != nil {
+		log.Error(ctx, "could not get snapshot progress", zap.Error(err))
+		return nil, map[string]string{"msg": "could not get snapshot progress", "error": err.Error()}, err
+	}
+	if fromBlock < j.StartBlock {
+		fromBlock = j.StartBlock
+	}
+	if fromBlock > lastBlock.Number {
+		log.Info(ctx, "no work to do")
+		return nil, map[string]string{"msg": "no work to do"}, nil
+	}
+
+	boundaries, err := j.boundaries(ctx, fromBlock, lastBlock.Number)
+	if err != nil {
+		log.Error(ctx, "could not get snapshot boundaries", zap.Uint64("from_block", fromBlock), zap.Uint64("to_block", lastBlock.Number), zap.Error(err))
+		return nil, map[string]string{"msg": "could not get snapshot boundaries", "error": err.Error()}, err
+	}
+
+	for _, boundary := range boundaries {
+		numSnapshots, err := j.snapshot(ctx, boundary, log)
+		if err != nil {
+			log.Error(ctx, "could not snapshot balances", zap.Uint64("block_number", boundary.Number), zap.Error(err))
+			return nil, map[string]string{"msg": "could not snapshot balances", "error": err.Error()}, err
+		}
+
+		err = j.TransactionStore.SetIndexerProgress(ctx, progress, boundary.Number+1)
+		if err != nil {
+			log.Error(ctx, "could not store snapshot progress", zap.Error(err))
+			return nil, map[string]string{"msg": "could not store snapshot progress", "error": err.Error()}, err
+		}
+
+		log.Info(ctx, "snapshotted balances", zap.Uint64("block_number", boundary.Number), zap.Timep("block_timestamp", boundary.Timestamp), zap.Int("num_snapshots", numSnapshots))
+	}
+
+	log.Info(ctx, "successfully finished", zap.Int("num_boundaries", len(boundaries)))
+
+	return nil, map[string]string{"msg": fmt.Sprintf("snapshotted balances at %d boundaries", len(boundaries))}, nil
+}
+
+// boundaries returns up to MaxSnapshots blocks of the range at which the balances are snapshotted.
+func (j *BalanceSnapshotter) boundaries(ctx context.Context, fromBlock, toBlock uint64) ([]*common_model.Block, error) {
+	switch j.Boundary {
+	case xtz_model.SnapshotDaily:
+		blocks, err := j.TransactionStore.GetDayEndBlocks(ctx, fromBlock, toBlock)
+		if err != nil {
+			return nil, err
+		}
+		if j.MaxSnapshots > 0 && uint64(len(blocks)) > j.MaxSnapshots {
+			blocks = blocks[:j.MaxSnapshots]
+		}
+		return blocks, nil
+
+	case xtz_model.SnapshotCycle:
+		var blocks = []*common_model.Block{}
+		for _, blockNumber := range cycleEndBlocks(fromBlock, toBlock, j.CycleStartBlock, j.BlocksPerCycle, j.MaxSnapshots) {
+			block, err := j.BlockStore.GetBlock(ctx, blockNumber)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not get block %d", blockNumber)
+			}
+			blocks = append(blocks, block)
+		}
+		return blocks, nil
+
+	default:
+		return nil, errors.Errorf("unknown snapshot boundary %q", j.Boundary)
+	}
+}
+
+// cycleEndBlocks returns up to max last blocks of the cycles in the [fromBlock, toBlock] range, max 0 meaning no bound.
+// The cycles have blocksPerCycle blocks, and one of them starts at cycleStartBlock.
+func cycleEndBlocks(fromBlock, toBlock, cycleStartBlock, blocksPerCycle, max uint64) []uint64 {
+	var blocks = []uint64{}
+	if blocksPerCycle == 0 || toBlock < cycleStartBlock+blocksPerCycle-1 {
+		return blocks
+	}
+
+	var end = cycleStartBlock + blocksPerCycle - 1
+	if fromBlock > end {
+		end += (fromBlock - end + blocksPerCycle - 1) / blocksPerCycle * blocksPerCycle
+	}
+	for ; end <= toBlock && (max == 0 || uint64(len(blocks)) < max); end += blocksPerCycle {
+		blocks = append(blocks, end)
+	}
+	return blocks
+}
+
+// snapshot records the balances of all the indexed addresses at the block, and returns the number of snapshots.
+func (j *BalanceSnapshotter) snapshot(ctx context.Context, block *common_model.Block, log *logger.ContextLogger) (int, error) {
+	if block.Timestamp == nil {
+		return 0, errors.Errorf("block %d has no timestamp", block.Number)
+	}
+
+	var (
+		numSnapshots int
+		lastAddress  string
+	)
+	for {
+		addresses, err := j.TransactionStore.GetAddresses(ctx, lastAddress, j.BatchSize)
+		if err != nil {
+			return 0, err
+		}
+		if len(addresses) == 0 {
+			return numSnapshots, nil
+		}
+		lastAddress = addresses[len(addresses)-1]
+
+		balances, err := j.Client.GetBalances(ctx, addresses, block.Number)
+		if err != nil {
+			return 0, err
+		}
+
+		var (
+			snapshots = make([]*xtz_model.BalanceSnapshot, 0, len(balances))
+			numFailed int
+		)
+		for _, balance := range balances {
+			snapshot := &xtz_model.BalanceSnapshot{
+				Address:        balance.Address,
+				BlockNumber:    block.Number,
+				Boundary:       j.Boundary,
+				BlockTimestamp: block.Timestamp,
+				Balance:        balance.BalanceAtBlock,
+			}
+			if balance.Error != nil || balance.BalanceAtBlock == nil {
+				msg := "no balance returned"
+				if balance.Error != nil {
+					msg = balance.Error.Error()
+				}
+				log.Warn(ctx, "could not get balance", zap.String("address", balance.Address), zap.Uint64("block_number", block.Number), zap.String("error", msg))
+				snapshot.Balance = nil
+				snapshot.Error = &msg
+				numFailed++
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+
+		err = j.TransactionStore.CreateBalanceSnapshots(ctx, snapshots)
+		if err != nil {
+			return 0, err
+		}
+		numSnapshots += len(snapshots)
+		j.MetricsSnapshotsCreated.With(helper.MakePrometheusLabels("coin", "XTZ", "boundary", j.Boundary)).Add(float64(len(snapshots) - numFailed))
+
+		if uint64(len(addresses)) < j.BatchSize {
+			return numSnapshots, nil
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CycleEndBlocks(t *testing.T) {
+	tests := []struct {
+		fromBlock, toBlock, max uint64
+		expected                []uint64
+	}{
+		// Before the end of the first cycle.
+		{fromBlock: 0, toBlock: 1009, expected: []uint64{}},
+		{fromBlock: 0, toBlock: 1010, expected: []uint64{1010}},
+		{fromBlock: 1010, toBlock: 1040, expected: []uint64{1010, 1020, 1030, 1040}},
+		// Inside a cycle.
+		{fromBlock: 1011, toBlock: 1035, expected: []uint64{1020, 1030}},
+		{fromBlock: 1020, toBlock: 1029, expected: []uint64{1020}},
+		{fromBlock: 1021, toBlock: 1029, expected: []uint64{}},
+		// Bounded.
+		{fromBlock: 1000, toBlock: 2000, max: 3, expected: []uint64{1010, 1020, 1030}},
+	}
+
+	for i, test := range tests {
+		// Cycles of 10 blocks, one of them starting at block 1001.
+		require.Equal(t, test.expected, cycleEndBlocks(test.fromBlock, test.toBlock, 1001, 10, test.max), i)
+	}
+}
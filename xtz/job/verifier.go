@@ -0,0 +1,199 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	job "github.com/t-dx/go-jobs/v4"
+	"github.com/t-dx/tg-blocksd/internal/logger"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
+	"github.com/t-dx/tg-blocksd/pkg/common/service"
+	"github.com/t-dx/tg-blocksd/pkg/common/store/cockroach"
+	"github.com/t-dx/tg-blocksd/pkg/helper"
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// verifierProgress is the name under which the verifier checkpoints the next block to verify.
+const verifierProgress = "verifier"
+
+// Verifier checks the indexed blocks against the canonical chain: missing levels in 'xtz_block', hash mismatches
+// and transaction count mismatches between the transactions stored in 'xtz_tx' and the chain ones. Each run verifies up to BatchSize blocks from its checkpoint, and starts over
+// from StartBlock once TipMargin blocks before the last stored block are reached.
+// The missing blocks and the count mismatches are indexed again through the BlockFetcher, unless ReportOnly is set.
+// The hash mismatches are only reported: fixing one means rolling back every following block, which is left to the
+// reorg handling of the BlockFetcher, journaled and bounded by its MaxReorgDepth. The findings are stored either way.
+type Verifier struct {
+	BlockStore       service.BlockStore
+	TransactionStore xtz_service.TransactionStore
+	Client           xtz_service.Client
+	// Fetcher is used to index the bad blocks again, it is required unless ReportOnly is set.
+	Fetcher *BlockFetcher
+
+	StartBlock uint64
+	BatchSize  uint64
+	// TipMargin is the number of blocks before the last stored block that are not verified, so that the verifier
+	// does not race the BlockFetcher on the blocks it may still roll back.
+	TipMargin      uint64
+	PrefetchWindow int
+	ReportOnly     bool
+	// RetainedBlocks is the number of blocks before the last stored one whose transactions are not garbage collected.
+	// The transactions of older blocks are not counted. Zero means that the transactions are never garbage collected.
+	RetainedBlocks uint64
+
+	MetricsBlocksVerified   *prometheus.CounterVec
+	MetricsIntegrityFinding *prometheus.CounterVec
+	MetricsJobDuration      *prometheus.SummaryVec
+}
+
+func (j *Verifier) Do(ctx context.Context, meta job.JobMeta, arg interface{}) (_ interface{}, _ map[string]string, err error) {
+	log := logger.With(logger.TechLog, zap.String("job_name", meta.JobName), zap.String("job_id", meta.JobID))
+
+	// Duration metrics
+	defer func(begin time.Time) {
+		status := "success"
+		if err != nil {
+			status = "failed"
+		}
+		j.MetricsJobDuration.With(helper.MakePrometheusLabels("name", meta.JobName, "status", status)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	log.Info(ctx, "job started", zap.Time("now", time.Now().UTC()))
+
+	if !j.ReportOnly && j.Fetcher == nil {
+		err = errors.New("a fetcher is required to repair blocks")
+		log.Error(ctx, "could not verify blocks", zap.Error(err))
+		return nil, map[string]string{"msg": "could not verify blocks", "error": err.Error()}, err
+	}
+
+	lastBlock, err := j.BlockStore.GetLastBlock(ctx)
+	switch {
+	case err == cockroach.ErrNoBlock:
+		log.Info(ctx, "no work to do")
+		return nil, map[string]string{"msg": "no work to do"}, nil
+	case err != nil:
+		log.Error(ctx, "could not get last block", zap.Error(err))
+		return nil, map[string]string{"msg": "could not get last block", "error": err.Error()}, err
+	}
+
+	fromBlock, err := j.TransactionStore.GetIndexerProgress(ctx, verifierProgress)
+	if err != nil {
+		log.Error(ctx, "could not get verifier progress", zap.Error(err))
+		return nil, map[string]string{"msg": "could not get verifier progress", "error": err.Error()}, err
+	}
+	if lastBlock.Number < j.StartBlock+j.TipMargin {
+		log.Info(ctx, "no work to do")
+		return nil, map[string]string{"msg": "no work to do"}, nil
+	}
+	toBlock := lastBlock.Number - j.TipMargin
+	if fromBlock < j.StartBlock || fromBlock > toBlock {
+		fromBlock = j.StartBlock
+	}
+	if j.BatchSize > 0 && fromBlock+j.BatchSize-1 < toBlock {
+		toBlock = fromBlock + j.BatchSize - 1
+	}
+
+	log.Info(ctx, "verifying blocks", zap.Uint64("from_block", fromBlock), zap.Uint64("to_block", toBlock), zap.Bool("report_only", j.ReportOnly))
+
+	// The transactions are not counted when some of them may have been deleted by the garbage collection, or were
+	// skipped in WatchedOnly mode.
+	var countFrom uint64
+	if j.RetainedBlocks > 0 && lastBlock.Number > j.RetainedBlocks {
+		countFrom = lastBlock.Number - j.RetainedBlocks + 1
+	}
+	if j.Fetcher != nil && j.Fetcher.WatchedOnly {
+		countFrom = toBlock + 1
+	}
+
+	findings, err := j.verify(ctx, fromBlock, toBlock, countFrom, log)
+	if err != nil {
+		log.Error(ctx, "could not verify blocks", zap.Uint64("from_block", fromBlock), zap.Uint64("to_block", toBlock), zap.Error(err))
+		return nil, map[string]string{"msg": "could not verify blocks", "error": err.Error()}, err
+	}
+
+	err = j.TransactionStore.CreateIntegrityFindings(ctx, findings)
+	if err != nil {
+		log.Error(ctx, "could not store findings", zap.Error(err))
+		return nil, map[string]string{"msg": "could not store findings", "error": err.Error()}, err
+	}
+
+	err = j.TransactionStore.SetIndexerProgress(ctx, verifierProgress, toBlock+1)
+	if err != nil {
+		log.Error(ctx, "could not store verifier progress", zap.Error(err))
+		return nil, map[string]string{"msg": "could not store verifier progress", "error": err.Error()}, err
+	}
+
+	log.Info(ctx, "successfully finished", zap.Int("num_findings", len(findings)))
+
+	return nil, map[string]string{"msg": fmt.Sprintf("verified blocks %d to %d, %d findings", fromBlock, toBlock, len(findings))}, nil
+}
+
+// verify compares the stored blocks of the range with the chain, and repairs the missing blocks and the count
+// mismatches unless ReportOnly is set.
+// The stored transactions are counted from countFrom on.
+func (j *Verifier) verify(ctx context.Context, fromBlock, toBlock, countFrom uint64, log *logger.ContextLogger) ([]*xtz_model.IntegrityFinding, error) {
+	storedBlocks, err := j.TransactionStore.GetStoredBlocks(ctx, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	var stored = make(map[uint64]*xtz_model.StoredBlock, len(storedBlocks))
+	for _, b := range storedBlocks {
+		stored[b.Number] = b
+	}
+
+	pf := newPrefetcher(ctx, j.Client, fromBlock, toBlock, j.PrefetchWindow, 0)
+	defer func() {
+		pf.stop()
+	}()
+
+	var findings = []*xtz_model.IntegrityFinding{}
+	for blockNumber := fromBlock; blockNumber <= toBlock; blockNumber++ {
+		block, transactions, err := pf.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		finding := checkBlock(stored[blockNumber], block, transactions, blockNumber >= countFrom)
+		j.MetricsBlocksVerified.With(helper.MakePrometheusLabels("coin", "XTZ")).Add(1)
+		if finding == nil {
+			continue
+		}
+
+		log.Warn(ctx, "integrity finding", zap.Uint64("block_number", blockNumber), zap.String("kind", finding.Kind), zap.Stringp("stored_value", finding.StoredValue), zap.Stringp("chain_value", finding.ChainValue))
+		j.MetricsIntegrityFinding.With(helper.MakePrometheusLabels("coin", "XTZ", "kind", finding.Kind)).Add(1)
+
+		if !j.ReportOnly && finding.Kind != xtz_model.FindingHashMismatch {
+			err = j.Fetcher.storeBlock(ctx, block, transactions, true)
+			if err != nil {
+				return nil, err
+			}
+			finding.Repaired = true
+			log.Info(ctx, "repaired block", zap.Uint64("block_number", blockNumber))
+		}
+		findings = append(findings, finding)
+	}
+
+	return findings, nil
+}
+
+// checkBlock compares a stored block with the chain one, and the stored transactions with the chain ones if count
+// is true. Only the transfers are counted. It returns nil if they match.
+func checkBlock(stored *xtz_model.StoredBlock, block *common_model.Block, transactions []*xtz_model.Transaction, count bool) *xtz_model.IntegrityFinding {
+	transfers, _ := splitTransfers(transactions)
+	switch {
+	case stored == nil:
+		return &xtz_model.IntegrityFinding{BlockNumber: block.Number, Kind: xtz_model.FindingMissingBlock, ChainValue: block.Hash}
+	case stored.Hash != nil && block.Hash != nil && *stored.Hash != *block.Hash:
+		return &xtz_model.IntegrityFinding{BlockNumber: block.Number, Kind: xtz_model.FindingHashMismatch, StoredValue: stored.Hash, ChainValue: block.Hash}
+	case count && stored.StoredTxCount != uint64(len(transfers)):
+		storedCount, chainCount := strconv.FormatUint(stored.StoredTxCount, 10), strconv.Itoa(len(transfers))
+		return &xtz_model.IntegrityFinding{BlockNumber: block.Number, Kind: xtz_model.FindingTransactionCountMismatch, StoredValue: &storedCount, ChainValue: &chainCount}
+	}
+	return nil
+}
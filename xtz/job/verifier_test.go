@@ -0,0 +1,140 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/t-dx/tg-blocksd/internal/logger"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CheckBlock(t *testing.T) {
+	var (
+		two          = uint64(2)
+		transactions = []*xtz_model.Transaction{{Hash: "op1"}, {Hash: "op2"}, {Hash: "op2", Index: 1, Kind: strPtr("delegation")}}
+	)
+
+	tests := []struct {
+		name   string
+		stored *xtz_model.StoredBlock
+		count  bool
+		kind   string
+	}{
+		{name: "match", stored: &xtz_model.StoredBlock{Number: 10, Hash: strPtr("BLa"), TxCount: &two, StoredTxCount: 2}, count: true},
+		{name: "match without count", stored: &xtz_model.StoredBlock{Number: 10, Hash: strPtr("BLa"), TxCount: &two, StoredTxCount: 1}},
+		{name: "missing", stored: nil, kind: xtz_model.FindingMissingBlock},
+		{name: "hash mismatch", stored: &xtz_model.StoredBlock{Number: 10, Hash: strPtr("BLb"), TxCount: &two, StoredTxCount: 2}, count: true, kind: xtz_model.FindingHashMismatch},
+		{name: "count mismatch", stored: &xtz_model.StoredBlock{Number: 10, Hash: strPtr("BLa"), TxCount: &two, StoredTxCount: 1}, count: true, kind: xtz_model.FindingTransactionCountMismatch},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			finding := checkBlock(tst.stored, &common_model.Block{Number: 10, Hash: strPtr("BLa")}, transactions, tst.count)
+			if tst.kind == "" {
+				require.Nil(t, finding)
+				return
+			}
+			require.NotNil(t, finding)
+			require.Equal(t, tst.kind, finding.Kind)
+			require.Equal(t, uint64(10), finding.BlockNumber)
+		})
+	}
+}
+
+func Test_VerifierDeletedTransaction(t *testing.T) {
+	// The mock client returns one transaction per block, all of them are stored.
+	store := &mockVerifierStore{stored: map[uint64]uint64{100: 1, 101: 1, 102: 1}}
+	j := &Verifier{
+		TransactionStore:        store,
+		Client:                  &mockPrefetchClient{},
+		PrefetchWindow:          2,
+		ReportOnly:              true,
+		MetricsBlocksVerified:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "blocks_verified"}, []string{"coin"}),
+		MetricsIntegrityFinding: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "integrity_finding"}, []string{"coin", "kind"}),
+	}
+	log := logger.With(logger.TechLog)
+
+	ctx := context.Background()
+	findings, err := j.verify(ctx, 100, 102, 100, log)
+	require.Nil(t, err)
+	require.Empty(t, findings)
+
+	// A stored transaction is deleted.
+	store.stored[101] = 0
+	findings, err = j.verify(ctx, 100, 102, 100, log)
+	require.Nil(t, err)
+	require.Len(t, findings, 1)
+	require.Equal(t, uint64(101), findings[0].BlockNumber)
+	require.Equal(t, xtz_model.FindingTransactionCountMismatch, findings[0].Kind)
+	require.Equal(t, "0", *findings[0].StoredValue)
+	require.Equal(t, "1", *findings[0].ChainValue)
+	require.False(t, findings[0].Repaired)
+
+	// Blocks before countFrom are not counted.
+	findings, err = j.verify(ctx, 100, 102, 102, log)
+	require.Nil(t, err)
+	require.Empty(t, findings)
+}
+
+type mockVerifierStore struct {
+	xtz_service.TransactionStore
+
+	// stored is the number of transactions stored by block.
+	stored map[uint64]uint64
+	// hashes is the stored hash by block.
+	hashes map[uint64]string
+}
+
+func (m *mockVerifierStore) GetStoredBlocks(ctx context.Context, fromBlock, toBlock uint64) ([]*xtz_model.StoredBlock, error) {
+	var blocks []*xtz_model.StoredBlock
+	for number := fromBlock; number <= toBlock; number++ {
+		if count, ok := m.stored[number]; ok {
+			one := uint64(1)
+			block := &xtz_model.StoredBlock{Number: number, TxCount: &one, StoredTxCount: count}
+			if hash, ok := m.hashes[number]; ok {
+				block.Hash = &hash
+			}
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+func Test_VerifierHashMismatchNotRepaired(t *testing.T) {
+	store := &mockVerifierStore{stored: map[uint64]uint64{100: 1}, hashes: map[uint64]string{100: "BLb"}}
+	j := &Verifier{
+		TransactionStore:        store,
+		Client:                  &mockHashClient{hash: "BLa"},
+		Fetcher:                 &BlockFetcher{},
+		MetricsBlocksVerified:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "blocks_verified"}, []string{"coin"}),
+		MetricsIntegrityFinding: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "integrity_finding"}, []string{"coin", "kind"}),
+	}
+
+	findings, err := j.verify(context.Background(), 100, 100, 100, logger.With(logger.TechLog))
+	require.Nil(t, err)
+	require.Len(t, findings, 1)
+	require.Equal(t, xtz_model.FindingHashMismatch, findings[0].Kind)
+	require.False(t, findings[0].Repaired)
+}
+
+// mockHashClient returns blocks with the given hash.
+type mockHashClient struct {
+	mockPrefetchClient
+
+	hash string
+}
+
+func (m *mockHashClient) GetBlockWithTransactions(ctx context.Context, blockNumber uint64) (*common_model.Block, []*xtz_model.Transaction, time.Duration, error) {
+	block, transactions, parseDuration, err := m.mockPrefetchClient.GetBlockWithTransactions(ctx, blockNumber)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	block.Hash = &m.hash
+	return block, transactions, parseDuration, nil
+}
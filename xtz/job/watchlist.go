@@ -0,0 +1,76 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+)
+
+// watchlistOverlap is how far before the last seen creation date the addresses are read again on refresh,
+// so that addresses committed late with an earlier creation date are not missed.
+const watchlistOverlap = time.Minute
+
+// watchlist is the in-memory set of the watched addresses of the 'xtz_addresses' table.
+// It is loaded once, then refreshed incrementally with the addresses created since the last refresh, before each
+// block so that a new address does not miss any block indexed after its creation.
+type watchlist struct {
+	store xtz_service.TransactionStore
+
+	mu          sync.RWMutex
+	addresses   map[string]struct{}
+	lastCreated time.Time
+}
+
+func newWatchlist(store xtz_service.TransactionStore) *watchlist {
+	return &watchlist{store: store, addresses: map[string]struct{}{}}
+}
+
+// refresh adds the addresses created since the last refresh.
+func (w *watchlist) refresh(ctx context.Context) error {
+	w.mu.RLock()
+	since := w.lastCreated.Add(-watchlistOverlap)
+	if w.lastCreated.IsZero() {
+		since = time.Time{}
+	}
+	w.mu.RUnlock()
+
+	addresses, lastCreated, err := w.store.GetAddressesCreatedSince(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, address := range addresses {
+		w.addresses[address] = struct{}{}
+	}
+	if lastCreated.After(w.lastCreated) {
+		w.lastCreated = lastCreated
+	}
+	return nil
+}
+
+// watches returns whether the transaction is from or to a watched address.
+func (w *watchlist) watches(tx *xtz_model.Transaction) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return touchesAddresses(tx, w.addresses)
+}
+
+// touchesAddresses returns whether the transaction is from or to one of the addresses.
+func touchesAddresses(tx *xtz_model.Transaction, addresses map[string]struct{}) bool {
+	if tx.SourceAddress != nil {
+		if _, ok := addresses[*tx.SourceAddress]; ok {
+			return true
+		}
+	}
+	if tx.DestinationAddress != nil {
+		if _, ok := addresses[*tx.DestinationAddress]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package job
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	xtz_model "github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	xtz_service "github.com/t-dx/tg-blocksd/pkg/xtz/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WatchlistRefresh(t *testing.T) {
+	now := time.Now().UTC()
+	store := &mockWatchlistStore{addresses: map[string]time.Time{"tz1watched": now.Add(-time.Hour)}}
+	w := newWatchlist(store)
+
+	ctx := context.Background()
+	require.Nil(t, w.refresh(ctx))
+	require.True(t, w.watches(&xtz_model.Transaction{SourceAddress: strPtr("tz1watched")}))
+	require.False(t, w.watches(&xtz_model.Transaction{SourceAddress: strPtr("tz1new")}))
+
+	// Only the addresses created since the last refresh are read.
+	store.addresses["tz1new"] = now
+	require.Nil(t, w.refresh(ctx))
+	require.True(t, w.watches(&xtz_model.Transaction{DestinationAddress: strPtr("tz1new")}))
+	require.Equal(t, now.Add(-time.Hour-watchlistOverlap), store.since)
+}
+
+func Test_BlockFetcherFilterWatched(t *testing.T) {
+	store := &mockWatchlistStore{
+		addresses:   map[string]time.Time{"tz1watched": time.Now()},
+		broadcasted: map[string]bool{"opbroadcast": true},
+	}
+	bf := &BlockFetcher{TransactionStore: store, WatchedOnly: true}
+
+	transactions := []*xtz_model.Transaction{
+		{Hash: "opwatched", SourceAddress: strPtr("tz1other"), DestinationAddress: strPtr("tz1watched")},
+		{Hash: "opbroadcast", Index: 0, SourceAddress: strPtr("tz1other")},
+		{Hash: "opbroadcast", Index: 1, SourceAddress: strPtr("tz1other")},
+		{Hash: "opother", SourceAddress: strPtr("tz1other"), DestinationAddress: strPtr("tz1other")},
+	}
+
+	kept, skipped, err := bf.filterWatched(context.Background(), transactions)
+	require.Nil(t, err)
+	require.Equal(t, 1, skipped)
+	require.ElementsMatch(t, transactions[:3], kept)
+}
+
+func Test_BlockFetcherBroadcastedContents(t *testing.T) {
+	store := &mockWatchlistStore{broadcasted: map[string]bool{"opbroadcast": true}}
+	bf := &BlockFetcher{TransactionStore: store}
+
+	transactions := []*xtz_model.Transaction{
+		{Hash: "opbroadcast", Index: 0, Kind: strPtr("reveal")},
+		{Hash: "opbroadcast", Index: 1},
+		{Hash: "opbroadcast", Index: 2, Kind: strPtr("delegation")},
+		{Hash: "opother", Index: 0, Kind: strPtr("delegation")},
+	}
+
+	transfers, contents := splitTransfers(transactions)
+	require.Equal(t, transactions[1:2], transfers)
+
+	kept, err := bf.broadcastedContents(context.Background(), contents)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []*xtz_model.Transaction{transactions[0], transactions[2]}, kept)
+}
+
+type mockWatchlistStore struct {
+	xtz_service.TransactionStore
+
+	addresses   map[string]time.Time
+	broadcasted map[string]bool
+	since       time.Time
+}
+
+func (m *mockWatchlistStore) GetAddressesCreatedSince(ctx context.Context, since time.Time) ([]string, time.Time, error) {
+	m.since = since
+
+	var (
+		addresses []string
+		last      time.Time
+	)
+	for address, createdAt := range m.addresses {
+		if createdAt.Before(since) {
+			continue
+		}
+		addresses = append(addresses, address)
+		if createdAt.After(last) {
+			last = createdAt
+		}
+	}
+	return addresses, last, nil
+}
+
+func (m *mockWatchlistStore) GetBroadcastedHashes(ctx context.Context, hashes []string) ([]string, error) {
+	var res []string
+	for _, hash := range hashes {
+		if m.broadcasted[hash] {
+			res = append(res, hash)
+		}
+	}
+	return res, nil
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -6,27 +6,353 @@ import (
 )
 
 // Transaction maps an entry in the 'xtz_tx' database table.
+// Confirmations is the number of blocks from the block of the transaction to the head, 0 if it is not in a block.
+// Confirmed is set when the transaction succeeded and has the confirmations required by the policy of the customer.
+// Index is the position of the content in its operation. Kind is the kind of a content other than a transaction,
+// stored for the broadcasted operations only, it is nil for the transfers.
 // Nullable fields have pointer types.
 type Transaction struct {
-	ID                   string            `db:"id"`
-	Hash                 string            `db:"hash"`
-	Index                uint64            `db:"idx"`
-	BlockNumber          *uint64           `db:"block_number"`
-	SourceAddress        *string           `db:"addr_from"`
-	DestinationAddress   *string           `db:"addr_to"`
-	Amount               *big.Int          `db:"amount"`
-	Fee                  *big.Int          `db:"fee"`
-	Counter              *big.Int          `db:"counter"`
-	Status               string            `db:"status"`
-	RawTransaction       *string           `db:"rawtx"`
-	Pinned               bool              `db:"pinned"`
-	Broadcasted          bool              `db:"broadcasted"`
-	Message              *string           `db:"error_message"`
-	Timestamp            *time.Time        `db:"timestamp"`
-	CreatedAt            *time.Time        `db:"created_at"`
-	CreatedAtBlockNumber *uint64           `db:"created_at_block"`
-	BroadcastedAtBlock   *uint64           `db:"broadcasted_at_block"`
-	Attributes           map[string]string `db:"_"`
+	ID                   string     `db:"id"`
+	Hash                 string     `db:"hash"`
+	Index                uint64     `db:"idx"`
+	Kind                 *string    `db:"kind"`
+	BlockNumber          *uint64    `db:"block_number"`
+	SourceAddress        *string    `db:"addr_from"`
+	DestinationAddress   *string    `db:"addr_to"`
+	Amount               *big.Int   `db:"amount"`
+	Fee                  *big.Int   `db:"fee"`
+	Counter              *big.Int   `db:"counter"`
+	Status               string     `db:"status"`
+	RawTransaction       *string    `db:"rawtx"`
+	Pinned               bool       `db:"pinned"`
+	Broadcasted          bool       `db:"broadcasted"`
+	Message              *string    `db:"error_message"`
+	Timestamp            *time.Time `db:"timestamp"`
+	CreatedAt            *time.Time `db:"created_at"`
+	CreatedAtBlockNumber *uint64    `db:"created_at_block"`
+	BroadcastedAtBlock   *uint64    `db:"broadcasted_at_block"`
+	CustomerID           *string    `db:"customer_id"`
+	BroadcastAttempts    uint64     `db:"broadcast_attempts"`
+	NextAttemptBlock     uint64     `db:"next_attempt_block"`
+	Branch               *string    `db:"branch"`
+	ExpiryBlock          *uint64    `db:"expiry_block"`
+	Replaces             *string    `db:"replaces"`
+	ReplacedBy           *string    `db:"replaced_by"`
+	BroadcastSource      *string    `db:"broadcast_source"`
+	BroadcastCounter     *uint64    `db:"broadcast_counter"`
+	CancelledBy          *string    `db:"cancelled_by"`
+	CancelReason         *string    `db:"cancel_reason"`
+	CancelledAt          *time.Time `db:"cancelled_at"`
+	NotBeforeBlock       *uint64    `db:"not_before_block"`
+	NotBefore            *time.Time `db:"not_before"`
+	AfterHash            *string    `db:"after_hash"`
+	// StatusUpdatedAt is the last status change of a broadcast.
+	StatusUpdatedAt *time.Time        `db:"status_updated_at"`
+	Confirmations   uint64            `db:"_"`
+	Confirmed       bool              `db:"_"`
+	Attributes      map[string]string `db:"_"`
+}
+
+// Reorg maps an entry in the 'xtz_reorg' database table.
+// It records the blocks rolled back by a chain reorganization.
+type Reorg struct {
+	ID string
+	// DetectedAtBlock is the block being processed when the reorg was detected.
+	DetectedAtBlock uint64
+	// ForkBlock is the last block common to both branches.
+	ForkBlock uint64
+	// Depth is the number of rolled back blocks.
+	Depth uint64
+	// OldHashes and NewHashes are the stored and canonical hashes of the rolled back blocks, from ForkBlock+1 upwards.
+	OldHashes []string
+	NewHashes []string
+	// TransactionHashes are the hashes of the transactions deleted with the rolled back blocks.
+	TransactionHashes []string
+	CreatedAt         *time.Time
+}
+
+// Backfill maps an entry in the 'xtz_backfill' database table.
+// A backfill indexes the transactions of a past range of blocks, split in shards indexed in parallel.
+// Nullable fields have pointer types.
+type Backfill struct {
+	ID        string
+	FromBlock uint64
+	ToBlock   uint64
+	// Addresses restricts the backfill to the transactions of these addresses, empty means all transactions.
+	// The transactions of a backfill restricted to addresses are pinned.
+	Addresses   []string
+	Shards      []*BackfillShard
+	CompletedAt *time.Time
+	CreatedAt   *time.Time
+}
+
+// BackfillShard maps an entry in the 'xtz_backfill_shard' database table.
+// NextBlock is the checkpoint of the shard, the blocks before it are indexed.
+type BackfillShard struct {
+	BackfillID  string
+	Shard       uint64
+	FromBlock   uint64
+	ToBlock     uint64
+	NextBlock   uint64
+	CompletedAt *time.Time
+	UpdatedAt   *time.Time
+}
+
+// Statuses of an address onboarding.
+const (
+	OnboardingPending   = "pending"
+	OnboardingScanning  = "scanning"
+	OnboardingCompleted = "completed"
+)
+
+// AddressOnboarding is the progress of the scan of the history of an address, from SinceBlock to ToBlock.
+// The blocks after ToBlock are indexed by the block fetcher.
+type AddressOnboarding struct {
+	Address       string
+	SinceBlock    uint64
+	ToBlock       uint64
+	BackfillID    *string
+	ScannedBlocks uint64
+	Status        string
+	CreatedAt     *time.Time
+}
+
+// Kinds of integrity findings.
+const (
+	// FindingMissingBlock is a level missing from 'xtz_block' below the last stored block.
+	FindingMissingBlock = "missing_block"
+	// FindingHashMismatch is a stored block whose hash differs from the canonical chain.
+	FindingHashMismatch = "hash_mismatch"
+	// FindingTransactionCountMismatch is a stored block whose number of transactions differs from the canonical chain.
+	FindingTransactionCountMismatch = "tx_count_mismatch"
+)
+
+// StoredBlock is an entry of the 'xtz_block' database table, as checked by the integrity verifier.
+// TxCount is nil for blocks stored before it was recorded.
+type StoredBlock struct {
+	Number  uint64
+	Hash    *string
+	TxCount *uint64
+	// StoredTxCount is the number of transactions actually stored in 'xtz_tx' for the block.
+	StoredTxCount uint64
+}
+
+// IntegrityFinding maps an entry in the 'xtz_integrity_finding' database table.
+// Repaired is false when the verifier runs in report-only mode, and for the hash mismatches.
+type IntegrityFinding struct {
+	ID          string
+	BlockNumber uint64
+	Kind        string
+	StoredValue *string
+	ChainValue  *string
+	Repaired    bool
+	CreatedAt   *time.Time
+}
+
+// BalanceDiscrepancy maps an entry in the 'xtz_balance_discrepancy' database table.
+// It records an address whose balance on the node differs from the balance derived from its indexed history,
+// both taken at BlockNumber.
+type BalanceDiscrepancy struct {
+	ID             string
+	Address        string
+	ChunkID        uint64
+	BlockNumber    uint64
+	NodeBalance    *big.Int
+	IndexedBalance *big.Int
+	CreatedAt      *time.Time
+}
+
+// BalanceBaseline maps an entry in the 'xtz_balance_baseline' database table.
+// It records the balance of an address on the node at its first reconciliation, the indexed history of the address is
+// reconciled from there.
+type BalanceBaseline struct {
+	Address     string
+	BlockNumber uint64
+	Balance     *big.Int
+	CreatedAt   *time.Time
+}
+
+// Boundaries at which the balances of the addresses are snapshotted.
+const (
+	// SnapshotDaily snapshots the balances at the last block of each UTC day.
+	SnapshotDaily = "daily"
+	// SnapshotCycle snapshots the balances at the last block of each cycle.
+	SnapshotCycle = "cycle"
+)
+
+// BalanceSnapshot maps an entry in the 'xtz_balance_snapshot' database table.
+// It records the balance of an address at the last block of a day or a cycle, depending on Boundary.
+// Balance is nil and Error is set if the balance could not be fetched from the node.
+// Nullable fields have pointer types.
+type BalanceSnapshot struct {
+	Address        string
+	BlockNumber    uint64
+	Boundary       string
+	BlockTimestamp *time.Time
+	Balance        *big.Int
+	Error          *string
+	CreatedAt      *time.Time
+}
+
+// Decisions an operator can take on an indexer halt.
+const (
+	// HaltDecisionAccept accepts the reorg, the block fetcher rolls it back whatever its depth.
+	HaltDecisionAccept = "accept"
+	// HaltDecisionSwitchNode tells that the node has been switched, the block fetcher checks for the reorg again.
+	HaltDecisionSwitchNode = "switch_node"
+)
+
+// IndexerHalt maps an entry in the 'xtz_indexer_halt' database table.
+// The block fetcher halts when it detects a reorg deeper than its maximum depth, until an operator decides what to do.
+// Nullable fields have pointer types.
+type IndexerHalt struct {
+	ID string
+	// BlockNumber is the block being processed when the reorg was detected.
+	BlockNumber uint64
+	// Depth is the depth reached when the check stopped, the reorg is at least that deep.
+	Depth    uint64
+	MaxDepth uint64
+	// StoredHash and ChainHash are the stored and canonical hashes of the block preceding BlockNumber.
+	StoredHash *string
+	ChainHash  *string
+	Decision   *string
+	DecidedBy  *string
+	DecidedAt  *time.Time
+	ClosedAt   *time.Time
+	CreatedAt  *time.Time
+}
+
+// Types of the events delivered to the webhooks.
+const (
+	// EventTransferIndexed is emitted when a transaction from or to a watched address is indexed.
+	EventTransferIndexed = "transfer.indexed"
+	// EventTransferConfirmed is emitted when a transaction from or to a watched address reaches the confirmations of the webhook.
+	EventTransferConfirmed = "transfer.confirmed"
+	// EventTransferReorged is emitted when a transaction notified as indexed is rolled back by a reorg.
+	EventTransferReorged = "transfer.reorged"
+	// EventBroadcastStatus is emitted when a broadcast of the customer changes status.
+	EventBroadcastStatus = "broadcast.status"
+)
+
+// StatusCancelled is the terminal status of a broadcast cancelled through CancelBroadcast, distinct from INVALID which
+// is the status of a broadcast rejected by the node. The common statuses have no such one.
+const StatusCancelled = "CANCELLED"
+
+// Statuses of a webhook delivery.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook maps an entry in the 'xtz_webhook' database table.
+// The transfer events are emitted for the transactions of Addresses indexed from FromBlock onwards,
+// the broadcast events for the broadcasts of the customer.
+// Nullable fields have pointer types.
+type Webhook struct {
+	ID         string
+	CustomerID string
+	URL        string
+	// Secret is the key of the HMAC signature of the deliveries. It is not returned by the API.
+	Secret    string
+	Addresses []string
+	// Confirmations is the number of confirmations of the transfer.confirmed event, 0 disables it.
+	Confirmations uint64
+	FromBlock     uint64
+	CreatedAt     *time.Time
+}
+
+// WebhookDelivery maps an entry in the 'xtz_webhook_delivery' database table.
+// It is the delivery log of an event to a webhook. EventKey identifies the event, so that it is delivered once.
+// Nullable fields have pointer types.
+type WebhookDelivery struct {
+	ID              string
+	WebhookID       string
+	EventType       string
+	EventKey        string
+	TransactionHash string
+	Payload         string
+	Status          string
+	Attempts        uint64
+	NextAttemptAt   *time.Time
+	LastStatusCode  *int
+	LastError       *string
+	DeliveredAt     *time.Time
+	CreatedAt       *time.Time
+}
+
+// WebhookEvent is the JSON payload of a webhook delivery.
+type WebhookEvent struct {
+	Type               string    `json:"type"`
+	WebhookID          string    `json:"webhook_id"`
+	TransactionHash    string    `json:"transaction_hash"`
+	Index              *uint64   `json:"index,omitempty"`
+	BlockNumber        *uint64   `json:"block_number,omitempty"`
+	SourceAddress      *string   `json:"source_address,omitempty"`
+	DestinationAddress *string   `json:"destination_address,omitempty"`
+	Amount             *big.Int  `json:"amount,omitempty"`
+	Fee                *big.Int  `json:"fee,omitempty"`
+	Status             string    `json:"status,omitempty"`
+	Confirmations      uint64    `json:"confirmations,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// OutboxEvent maps an entry in the 'xtz_outbox' database table. It is a change of the 'xtz_tx' table, written in the
+// same database transaction as the change. The type is one of transfer.indexed, transfer.reorged and broadcast.status.
+// ID increases with the changes, consumers can use it to deduplicate the events published again after a failure.
+// Nullable fields have pointer types.
+type OutboxEvent struct {
+	ID                 uint64     `json:"id"`
+	Type               string     `json:"type"`
+	TransactionHash    string     `json:"transaction_hash"`
+	Index              uint64     `json:"index"`
+	BlockNumber        *uint64    `json:"block_number,omitempty"`
+	SourceAddress      *string    `json:"source_address,omitempty"`
+	DestinationAddress *string    `json:"destination_address,omitempty"`
+	Amount             *big.Int   `json:"amount,omitempty"`
+	Fee                *big.Int   `json:"fee,omitempty"`
+	Status             string     `json:"status"`
+	PreviousStatus     *string    `json:"previous_status,omitempty"`
+	CreatedAt          *time.Time `json:"created_at"`
+}
+
+// ConfirmationPolicy maps an entry in the 'xtz_confirmation_policy' database table. It defines the number of
+// confirmations after which the transactions of a customer are confirmed. The policy with an empty CustomerID
+// is the default one.
+type ConfirmationPolicy struct {
+	CustomerID string
+	// Confirmations is the number of confirmations required for amounts below the first threshold.
+	Confirmations uint64
+	// Thresholds raise the required confirmations from an amount, by increasing MinAmount.
+	Thresholds []*ConfirmationThreshold
+	UpdatedAt  *time.Time
+}
+
+// ConfirmationThreshold is the number of confirmations required for amounts of at least MinAmount.
+type ConfirmationThreshold struct {
+	MinAmount     *big.Int
+	Confirmations uint64
+}
+
+// RequiredConfirmations returns the number of confirmations required for the given amount.
+func (p *ConfirmationPolicy) RequiredConfirmations(amount *big.Int) uint64 {
+	var confirmations = p.Confirmations
+	if amount == nil {
+		return confirmations
+	}
+	for _, threshold := range p.Thresholds {
+		if amount.Cmp(threshold.MinAmount) < 0 {
+			break
+		}
+		confirmations = threshold.Confirmations
+	}
+	return confirmations
+}
+
+// ConfirmedFilter restricts a query to the transactions confirmed under Policy when the chain is at Height.
+type ConfirmedFilter struct {
+	Policy *ConfirmationPolicy
+	Height uint64
 }
 
 type BlockchainInfo struct {
@@ -34,10 +360,98 @@ type BlockchainInfo struct {
 	ConfirmationBlockHash string
 }
 
+// OperationExpiry gives the lifetime of a forged operation. The operation can be included in the blocks following its
+// Branch up to ExpiryLevel, i.e. BranchLevel plus the max_operations_ttl of the chain. ExpiryTime is the time of
+// ExpiryLevel, estimated from the mean block time over the last max_operations_ttl blocks.
+type OperationExpiry struct {
+	Branch           string
+	BranchLevel      uint64
+	MaxOperationsTTL uint64
+	ExpiryLevel      uint64
+	ExpiryTime       time.Time
+}
+
+// ManagerOperation is a forged manager operation, as needed to replace it: the kind, source and counter of its first
+// content, and the fee of all its contents.
+type ManagerOperation struct {
+	Kind    string
+	Source  string
+	Counter *big.Int
+	Fee     *big.Int
+}
+
+// BroadcastReplacements are the broadcasts of an operation replaced by fee, from the first one to the last
+// replacement. Landed is the one included in a block, if any.
+type BroadcastReplacements struct {
+	Transactions []*Transaction
+	Landed       *Transaction
+}
+
+// OperationGroup is a broadcasted operation with its contents. Status and Message are the ones of the broadcast until
+// it is included in BlockNumber, then the ones of the contents: the group succeeds if all its contents are applied.
+// Contents are the included contents of the operation of every kind, ordered by index.
+type OperationGroup struct {
+	Hash        string
+	Status      string
+	Message     *string
+	BlockNumber *uint64
+	Contents    []*Transaction
+}
+
+// BroadcastHistory is what happened to a broadcast: its current Status, the block it is included in if any, and
+// the actions on it in chronological order. The history of a broadcast stored before it was recorded is rebuilt from
+// the broadcast: its storage and its last attempt.
+type BroadcastHistory struct {
+	Hash        string
+	Status      string
+	Message     *string
+	BlockNumber *uint64
+	Entries     []*BroadcastHistoryEntry
+}
+
+// BroadcastHistoryEntry is an action on a broadcast: store, replace, broadcast or expire. Status is the status of the
+// broadcast after the action, Message the error returned by the node on a failed attempt, and BlockNumber the level
+// at which it happened.
+type BroadcastHistoryEntry struct {
+	Hash        string
+	Action      string
+	Status      string
+	Message     *string
+	BlockNumber uint64
+	CreatedAt   time.Time
+}
+
+// BroadcastCancellation is a cancelled broadcast, which the library does not inject anymore. An operation already
+// injected can still be included from a node mempool up to ExpiryBlock, MayBeIncluded is set then. Its inclusion
+// would still be indexed.
+// Dependents are the pending broadcasts of the customer that could not be included without the cancelled one,
+// cancelled with it: the ones of the same source with a higher counter, and the ones to be broadcasted after it.
+type BroadcastCancellation struct {
+	Hash          string
+	Status        string
+	CancelledBy   string
+	Reason        string
+	CancelledAt   time.Time
+	ExpiryBlock   *uint64
+	MayBeIncluded bool
+	Warning       *string
+	Dependents    []string
+}
+
+// BroadcastQueue is the queue of the pending broadcasts of a source address. They are injected one at a time,
+// in the order of their counter. NextCounter is the counter of the next one to be injected.
+type BroadcastQueue struct {
+	Source      string
+	Depth       uint64
+	NextCounter uint64
+}
+
 // Balance represents the balance of a tezos address.
+// BlockNumber is the block of BalanceAtBlock, 0 meaning the tip.
 // Nullable fields have pointer types.
 type Balance struct {
 	Address        string
+	BlockNumber    uint64
 	BalanceAtBlock *big.Int
 	BalanceAtTip   *big.Int
 	Error          error
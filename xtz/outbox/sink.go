@@ -0,0 +1,132 @@
+// Package outbox publishes the changes of the tezos transactions written to the outbox table.
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
+
+	"github.com/pkg/errors"
+)
+
+// Sink is where the relay publishes the events of the outbox. Publish is called with the events in order,
+// and must either publish all of them or return an error, in which case they are published again later.
+type Sink interface {
+	Publish(ctx context.Context, events []*model.OutboxEvent) error
+}
+
+// FileSink appends the events to a file, one JSON object per line.
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Verify FileSink satisfies the Sink interface.
+var _ Sink = (*FileSink)(nil)
+
+// NewFileSink returns a sink appending to the file at the given path, created if needed.
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Publish(ctx context.Context, events []*model.OutboxEvent) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	// Sync so that the events are not lost once marked as published.
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// HTTPSink posts the events to an URL, as a JSON array.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// Verify HTTPSink satisfies the Sink interface.
+var _ Sink = (*HTTPSink)(nil)
+
+// NewHTTPSink returns a sink posting to the given URL, whose requests time out after the given duration.
+func NewHTTPSink(url string, timeout time.Duration) *HTTPSink {
+	return &HTTPSink{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Publish posts the events. They are published if the response status code is 2xx.
+func (s *HTTPSink) Publish(ctx context.Context, events []*model.OutboxEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("sink responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// MemorySink keeps the events in memory. It is meant for tests and embedding.
+type MemorySink struct {
+	mu     sync.Mutex
+	events []*model.OutboxEvent
+}
+
+// Verify MemorySink satisfies the Sink interface.
+var _ Sink = (*MemorySink)(nil)
+
+// NewMemorySink returns an empty in-memory sink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Publish(ctx context.Context, events []*model.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+// Events returns the published events, in order.
+func (s *MemorySink) Events() []*model.OutboxEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*model.OutboxEvent(nil), s.events...)
+}
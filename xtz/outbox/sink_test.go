@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+var events = []*model.OutboxEvent{
+	{ID: 1, Type: model.EventTransferIndexed, TransactionHash: "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW", Status: "SUCCESS"},
+	{ID: 2, Type: model.EventBroadcastStatus, TransactionHash: "ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ", Status: "TIMEOUT"},
+}
+
+func Test_FileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "events.jsonl")
+	sink := NewFileSink(path)
+
+	ctx := context.Background()
+	require.Nil(t, sink.Publish(ctx, events[:1]))
+	require.Nil(t, sink.Publish(ctx, events[1:]))
+
+	f, err := os.Open(path)
+	require.Nil(t, err)
+	defer f.Close()
+
+	var ids []uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event model.OutboxEvent
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &event))
+		ids = append(ids, event.ID)
+	}
+	require.Equal(t, []uint64{1, 2}, ids)
+}
+
+func Test_HTTPSink(t *testing.T) {
+	var (
+		received []*model.OutboxEvent
+		status   = http.StatusOK
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink(server.URL, time.Second)
+
+	ctx := context.Background()
+	require.Nil(t, sink.Publish(ctx, events))
+	require.Len(t, received, 2)
+	require.Equal(t, events[1].TransactionHash, received[1].TransactionHash)
+
+	status = http.StatusServiceUnavailable
+	require.NotNil(t, sink.Publish(ctx, events))
+}
+
+func Test_MemorySink(t *testing.T) {
+	sink := NewMemorySink()
+
+	ctx := context.Background()
+	require.Nil(t, sink.Publish(ctx, events[:1]))
+	require.Nil(t, sink.Publish(ctx, events[1:]))
+	require.Equal(t, events, sink.Events())
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/t-dx/tg-blocksd/internal/logger"
 	"github.com/t-dx/tg-blocksd/internal/utils/cache"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/service"
 
@@ -243,7 +244,12 @@ type cachedTransactions struct {
 
 func (mw *cachingFront) GetTransactionsByHashes(ctx context.Context, req *service.GetTransactionsByHashesByCustomerReq) ([]*model.Transaction, uint64, error) {
 	sort.Strings(req.Hashes)
-	key, err := cache.GenKey("GetTransactionsByHashes", req)
+	// The confirmed state of the transactions depends on the confirmation policy of the customer, part of the key.
+	policy, err := mw.next.GetConfirmationPolicy(ctx, &service.GetConfirmationPolicyReq{Network: req.Network, CustomerID: req.CustomerID})
+	if err != nil {
+		return nil, 0, err
+	}
+	key, err := cache.GenKey("GetTransactionsByHashes", req, policy)
 	if err != nil {
 		logger.TechLog.Error(ctx, "cache key generation error", zap.Error(err))
 		return mw.next.GetTransactionsByHashes(ctx, req)
@@ -278,7 +284,12 @@ func (mw *cachingFront) GetTransactionsByHashes(ctx context.Context, req *servic
 
 func (mw *cachingFront) GetTransactionsByBlocks(ctx context.Context, req *service.GetTransactionsByBlocksByCustomerReq) ([]*model.Transaction, uint64, uint64, error) {
 	sort.Strings(req.Addresses)
-	key, err := cache.GenKey("GetTransactionsByBlocks", req)
+	// The confirmed state of the transactions depends on the confirmation policy of the customer, part of the key.
+	policy, err := mw.next.GetConfirmationPolicy(ctx, &service.GetConfirmationPolicyReq{Network: req.Network, CustomerID: req.CustomerID})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	key, err := cache.GenKey("GetTransactionsByBlocks", req, policy)
 	if err != nil {
 		logger.TechLog.Error(ctx, "cache key generation error", zap.Error(err))
 		return mw.next.GetTransactionsByBlocks(ctx, req)
@@ -315,7 +326,12 @@ func (mw *cachingFront) GetTransactionsByDates(ctx context.Context, req *service
 	sort.Strings(req.Addresses)
 	req.FromDate = req.FromDate.Truncate(time.Minute)
 	req.ToDate = req.ToDate.Truncate(time.Minute)
-	key, err := cache.GenKey("GetTransactionsByDates", req)
+	// The confirmed state of the transactions depends on the confirmation policy of the customer, part of the key.
+	policy, err := mw.next.GetConfirmationPolicy(ctx, &service.GetConfirmationPolicyReq{Network: req.Network, CustomerID: req.CustomerID})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	key, err := cache.GenKey("GetTransactionsByDates", req, policy)
 	if err != nil {
 		logger.TechLog.Error(ctx, "cache key generation error", zap.Error(err))
 		return mw.next.GetTransactionsByDates(ctx, req)
@@ -349,7 +365,12 @@ func (mw *cachingFront) GetTransactionsByDates(ctx context.Context, req *service
 }
 
 func (mw *cachingFront) GetTransactionsByAttributes(ctx context.Context, req *service.GetTransactionsByAttributesByCustomerReq) ([]*model.Transaction, uint64, error) {
-	key, err := cache.GenKey("GetTransactionsByAttributes", req)
+	// The confirmed state of the transactions depends on the confirmation policy of the customer, part of the key.
+	policy, err := mw.next.GetConfirmationPolicy(ctx, &service.GetConfirmationPolicyReq{Network: req.Network, CustomerID: req.CustomerID})
+	if err != nil {
+		return nil, 0, err
+	}
+	key, err := cache.GenKey("GetTransactionsByAttributes", req, policy)
 	if err != nil {
 		logger.TechLog.Error(ctx, "cache key generation error", zap.Error(err))
 		return mw.next.GetTransactionsByAttributes(ctx, req)
@@ -381,3 +402,63 @@ func (mw *cachingFront) GetTransactionsByAttributes(ctx context.Context, req *se
 
 	return transactions, height, nil
 }
+
+func (mw *cachingFront) GetAddressOnboarding(ctx context.Context, req *service.GetAddressOnboardingReq) ([]*model.AddressOnboarding, error) {
+	return mw.next.GetAddressOnboarding(ctx, req)
+}
+
+func (mw *cachingFront) CreateWebhook(ctx context.Context, req *service.CreateWebhookReq) (string, error) {
+	return mw.next.CreateWebhook(ctx, req)
+}
+
+func (mw *cachingFront) GetWebhooks(ctx context.Context, req *service.GetWebhooksReq) ([]*model.Webhook, error) {
+	return mw.next.GetWebhooks(ctx, req)
+}
+
+func (mw *cachingFront) DeleteWebhook(ctx context.Context, req *service.DeleteWebhookReq) error {
+	return mw.next.DeleteWebhook(ctx, req)
+}
+
+func (mw *cachingFront) GetWebhookDeliveries(ctx context.Context, req *service.GetWebhookDeliveriesReq) ([]*model.WebhookDelivery, uint64, error) {
+	return mw.next.GetWebhookDeliveries(ctx, req)
+}
+
+func (mw *cachingFront) GetConfirmationPolicy(ctx context.Context, req *service.GetConfirmationPolicyReq) (*model.ConfirmationPolicy, error) {
+	return mw.next.GetConfirmationPolicy(ctx, req)
+}
+
+func (mw *cachingFront) SetConfirmationPolicy(ctx context.Context, req *service.SetConfirmationPolicyReq) error {
+	return mw.next.SetConfirmationPolicy(ctx, req)
+}
+
+func (mw *cachingFront) GetBalanceSeries(ctx context.Context, req *service.GetBalanceSeriesReq) ([]*model.BalanceSnapshot, error) {
+	return mw.next.GetBalanceSeries(ctx, req)
+}
+
+func (mw *cachingFront) GetBlockAtTime(ctx context.Context, req *service.GetBlockAtTimeReq) (*common_model.Block, error) {
+	return mw.next.GetBlockAtTime(ctx, req)
+}
+
+func (mw *cachingFront) ReplaceBroadcast(ctx context.Context, req *service.ReplaceBroadcastReq) (string, error) {
+	return mw.next.ReplaceBroadcast(ctx, req)
+}
+
+func (mw *cachingFront) GetBroadcastReplacements(ctx context.Context, req *service.GetBroadcastReplacementsReq) (*model.BroadcastReplacements, error) {
+	return mw.next.GetBroadcastReplacements(ctx, req)
+}
+
+func (mw *cachingFront) GetBroadcastQueues(ctx context.Context, req *service.GetBroadcastQueuesReq) ([]*model.BroadcastQueue, error) {
+	return mw.next.GetBroadcastQueues(ctx, req)
+}
+
+func (mw *cachingFront) GetOperationGroup(ctx context.Context, req *service.GetOperationGroupReq) (*model.OperationGroup, error) {
+	return mw.next.GetOperationGroup(ctx, req)
+}
+
+func (mw *cachingFront) GetBroadcastHistory(ctx context.Context, req *service.GetBroadcastHistoryReq) (*model.BroadcastHistory, error) {
+	return mw.next.GetBroadcastHistory(ctx, req)
+}
+
+func (mw *cachingFront) CancelBroadcast(ctx context.Context, req *service.CancelBroadcastReq) (*model.BroadcastCancellation, error) {
+	return mw.next.CancelBroadcast(ctx, req)
+}
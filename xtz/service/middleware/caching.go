@@ -3,10 +3,12 @@ package middleware
 import (
 	"context"
 	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/t-dx/tg-blocksd/internal/logger"
 	"github.com/t-dx/tg-blocksd/internal/utils/cache"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/service"
 
@@ -29,6 +31,7 @@ const (
 	getTransactionsByDatesCacheExpiration      = 60
 	getTransactionsByAttributesCacheExpiration = 60
 	CallContractMethodCacheExpiration          = 60
+	getConfirmationPolicyCacheExpiration       = 15
 )
 
 func Caching() func(service.XTZer) service.XTZer {
@@ -43,6 +46,9 @@ func Caching() func(service.XTZer) service.XTZer {
 type caching struct {
 	cache *freecache.Cache
 	next  service.XTZer
+	// policyGeneration is part of the keys of the confirmation policies, and incremented when one is set: a default
+	// policy applies to several customers. The policies set by other instances are read after the cache expiration.
+	policyGeneration uint64
 }
 
 func (mw *caching) AddAddresses(ctx context.Context, req *service.AddAddressesReq) error {
@@ -209,3 +215,124 @@ func (mw *caching) GetTransactionsByDates(ctx context.Context, req *service.GetT
 func (mw *caching) GetRawTransactionHash(ctx context.Context, rawTransaction string) (string, error) {
 	return mw.next.GetRawTransactionHash(ctx, rawTransaction)
 }
+
+func (mw *caching) GetIndexerHalt(ctx context.Context, req *service.GetIndexerHaltReq) (*model.IndexerHalt, error) {
+	return mw.next.GetIndexerHalt(ctx, req)
+}
+
+func (mw *caching) ResolveIndexerHalt(ctx context.Context, req *service.ResolveIndexerHaltReq) error {
+	return mw.next.ResolveIndexerHalt(ctx, req)
+}
+
+func (mw *caching) CreateBackfill(ctx context.Context, req *service.CreateBackfillReq) (string, error) {
+	return mw.next.CreateBackfill(ctx, req)
+}
+
+func (mw *caching) GetBackfill(ctx context.Context, req *service.GetBackfillReq) (*model.Backfill, error) {
+	return mw.next.GetBackfill(ctx, req)
+}
+
+func (mw *caching) GetAddressOnboarding(ctx context.Context, req *service.GetAddressOnboardingReq) ([]*model.AddressOnboarding, error) {
+	return mw.next.GetAddressOnboarding(ctx, req)
+}
+
+func (mw *caching) GetIntegrityFindings(ctx context.Context, req *service.GetIntegrityFindingsReq) ([]*model.IntegrityFinding, uint64, error) {
+	return mw.next.GetIntegrityFindings(ctx, req)
+}
+
+func (mw *caching) CreateWebhook(ctx context.Context, req *service.CreateWebhookReq) (string, error) {
+	return mw.next.CreateWebhook(ctx, req)
+}
+
+func (mw *caching) GetWebhooks(ctx context.Context, req *service.GetWebhooksReq) ([]*model.Webhook, error) {
+	return mw.next.GetWebhooks(ctx, req)
+}
+
+func (mw *caching) DeleteWebhook(ctx context.Context, req *service.DeleteWebhookReq) error {
+	return mw.next.DeleteWebhook(ctx, req)
+}
+
+func (mw *caching) GetWebhookDeliveries(ctx context.Context, req *service.GetWebhookDeliveriesReq) ([]*model.WebhookDelivery, uint64, error) {
+	return mw.next.GetWebhookDeliveries(ctx, req)
+}
+
+func (mw *caching) GetConfirmationPolicy(ctx context.Context, req *service.GetConfirmationPolicyReq) (*model.ConfirmationPolicy, error) {
+	key, err := cache.GenKey("GetConfirmationPolicy", req, atomic.LoadUint64(&mw.policyGeneration))
+	if err != nil {
+		logger.TechLog.Error(ctx, "cache key generation error", zap.Error(err))
+		return mw.next.GetConfirmationPolicy(ctx, req)
+	}
+
+	// Try to get result from cache.
+	if cached, err := mw.cache.Get(key); err == nil {
+		var policy model.ConfirmationPolicy
+		if err := cache.Decode(cached, &policy); err == nil {
+			logger.TechLog.Debug(ctx, "cache hit")
+			return &policy, nil
+		}
+	}
+
+	// Cache miss: use client to get result.
+	policy, err := mw.next.GetConfirmationPolicy(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store result in cache.
+	if toCache, err := cache.Encode(policy); err == nil {
+		err = mw.cache.Set(key, toCache, getConfirmationPolicyCacheExpiration)
+		if err != nil {
+			logger.TechLog.Error(ctx, "cache error", zap.Error(err))
+		}
+	}
+	logger.TechLog.Debug(ctx, "cache miss")
+
+	return policy, nil
+}
+
+func (mw *caching) SetConfirmationPolicy(ctx context.Context, req *service.SetConfirmationPolicyReq) error {
+	err := mw.next.SetConfirmationPolicy(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// The cached policies are not read anymore.
+	atomic.AddUint64(&mw.policyGeneration, 1)
+	return nil
+}
+
+func (mw *caching) GetBalanceDiscrepancies(ctx context.Context, req *service.GetBalanceDiscrepanciesReq) ([]*model.BalanceDiscrepancy, uint64, error) {
+	return mw.next.GetBalanceDiscrepancies(ctx, req)
+}
+
+func (mw *caching) GetBalanceSeries(ctx context.Context, req *service.GetBalanceSeriesReq) ([]*model.BalanceSnapshot, error) {
+	return mw.next.GetBalanceSeries(ctx, req)
+}
+
+func (mw *caching) GetBlockAtTime(ctx context.Context, req *service.GetBlockAtTimeReq) (*common_model.Block, error) {
+	return mw.next.GetBlockAtTime(ctx, req)
+}
+
+func (mw *caching) ReplaceBroadcast(ctx context.Context, req *service.ReplaceBroadcastReq) (string, error) {
+	return mw.next.ReplaceBroadcast(ctx, req)
+}
+
+func (mw *caching) GetBroadcastReplacements(ctx context.Context, req *service.GetBroadcastReplacementsReq) (*model.BroadcastReplacements, error) {
+	return mw.next.GetBroadcastReplacements(ctx, req)
+}
+
+func (mw *caching) GetBroadcastQueues(ctx context.Context, req *service.GetBroadcastQueuesReq) ([]*model.BroadcastQueue, error) {
+	return mw.next.GetBroadcastQueues(ctx, req)
+}
+
+func (mw *caching) GetOperationGroup(ctx context.Context, req *service.GetOperationGroupReq) (*model.OperationGroup, error) {
+	return mw.next.GetOperationGroup(ctx, req)
+}
+
+func (mw *caching) GetBroadcastHistory(ctx context.Context, req *service.GetBroadcastHistoryReq) (*model.BroadcastHistory, error) {
+	return mw.next.GetBroadcastHistory(ctx, req)
+}
+
+func (mw *caching) CancelBroadcast(ctx context.Context, req *service.CancelBroadcastReq) (*model.BroadcastCancellation, error) {
+	return mw.next.CancelBroadcast(ctx, req)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/t-dx/tg-blocksd/internal/logger"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/service"
 
@@ -34,6 +35,8 @@ func (mw *logging) AddAddresses(ctx context.Context, req *service.AddAddressesRe
 			zap.String("method", "AddAddresses"),
 			zap.Error(err),
 			zap.Int("num_addresses", len(req.Addresses)),
+			zap.Uint64("since_block", req.SinceBlock),
+			zap.Time("since_date", req.SinceDate),
 			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
 		)
 		return err
@@ -42,6 +45,8 @@ func (mw *logging) AddAddresses(ctx context.Context, req *service.AddAddressesRe
 	mw.logger.Info(ctx, "request completed",
 		zap.String("method", "AddAddresses"),
 		zap.Int("num_addresses", len(req.Addresses)),
+		zap.Uint64("since_block", req.SinceBlock),
+		zap.Time("since_date", req.SinceDate),
 		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
 	)
 	return nil
@@ -234,3 +239,541 @@ func (mw *logging) GetTransactionsByDates(ctx context.Context, req *service.GetT
 func (mw *logging) GetRawTransactionHash(ctx context.Context, rawTransaction string) (string, error) {
 	return mw.next.GetRawTransactionHash(ctx, rawTransaction)
 }
+
+func (mw *logging) GetIndexerHalt(ctx context.Context, req *service.GetIndexerHaltReq) (*model.IndexerHalt, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetIndexerHalt(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetIndexerHalt"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetIndexerHalt"),
+		zap.Bool("halted", res != nil),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) ResolveIndexerHalt(ctx context.Context, req *service.ResolveIndexerHaltReq) error {
+	now := time.Now()
+
+	err := mw.next.ResolveIndexerHalt(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "ResolveIndexerHalt"),
+			zap.Error(err),
+			zap.String("id", req.ID),
+			zap.String("decision", req.Decision),
+			zap.String("decided_by", req.DecidedBy),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "ResolveIndexerHalt"),
+		zap.String("id", req.ID),
+		zap.String("decision", req.Decision),
+		zap.String("decided_by", req.DecidedBy),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *logging) CreateBackfill(ctx context.Context, req *service.CreateBackfillReq) (string, error) {
+	now := time.Now()
+
+	res, err := mw.next.CreateBackfill(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateBackfill"),
+			zap.Error(err),
+			zap.Uint64("from_block", req.FromBlock),
+			zap.Uint64("to_block", req.ToBlock),
+			zap.Uint64("shards", req.Shards),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "CreateBackfill"),
+		zap.String("id", res),
+		zap.Uint64("from_block", req.FromBlock),
+		zap.Uint64("to_block", req.ToBlock),
+		zap.Uint64("shards", req.Shards),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) GetBackfill(ctx context.Context, req *service.GetBackfillReq) (*model.Backfill, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetBackfill(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBackfill"),
+			zap.Error(err),
+			zap.String("id", req.ID),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetBackfill"),
+		zap.String("id", req.ID),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) GetAddressOnboarding(ctx context.Context, req *service.GetAddressOnboardingReq) ([]*model.AddressOnboarding, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetAddressOnboarding(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetAddressOnboarding"),
+			zap.Error(err),
+			zap.Int("num_addresses", len(req.Addresses)),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetAddressOnboarding"),
+		zap.Int("num_addresses", len(req.Addresses)),
+		zap.Int("num_onboardings", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) GetIntegrityFindings(ctx context.Context, req *service.GetIntegrityFindingsReq) ([]*model.IntegrityFinding, uint64, error) {
+	now := time.Now()
+
+	res, totalItems, err := mw.next.GetIntegrityFindings(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetIntegrityFindings"),
+			zap.Error(err),
+			zap.Time("from_date", req.FromDate),
+			zap.Time("to_date", req.ToDate),
+			zap.Uint64("limit", req.Limit),
+			zap.Uint64("offset", req.Offset),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, totalItems, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetIntegrityFindings"),
+		zap.Int("num_findings", len(res)),
+		zap.Uint64("total_items", totalItems),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, totalItems, nil
+}
+
+func (mw *logging) CreateWebhook(ctx context.Context, req *service.CreateWebhookReq) (string, error) {
+	now := time.Now()
+
+	res, err := mw.next.CreateWebhook(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateWebhook"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.String("url", req.URL),
+			zap.Int("num_addresses", len(req.Addresses)),
+			zap.Uint64("confirmations", req.Confirmations),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "CreateWebhook"),
+		zap.String("customer_id", req.CustomerID),
+		zap.String("url", req.URL),
+		zap.Int("num_addresses", len(req.Addresses)),
+		zap.Uint64("confirmations", req.Confirmations),
+		zap.String("webhook_id", res),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) GetWebhooks(ctx context.Context, req *service.GetWebhooksReq) ([]*model.Webhook, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetWebhooks(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetWebhooks"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetWebhooks"),
+		zap.String("customer_id", req.CustomerID),
+		zap.Int("num_webhooks", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) DeleteWebhook(ctx context.Context, req *service.DeleteWebhookReq) error {
+	now := time.Now()
+
+	err := mw.next.DeleteWebhook(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "DeleteWebhook"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.String("webhook_id", req.ID),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "DeleteWebhook"),
+		zap.String("customer_id", req.CustomerID),
+		zap.String("webhook_id", req.ID),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *logging) GetWebhookDeliveries(ctx context.Context, req *service.GetWebhookDeliveriesReq) ([]*model.WebhookDelivery, uint64, error) {
+	now := time.Now()
+
+	res, totalItems, err := mw.next.GetWebhookDeliveries(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetWebhookDeliveries"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.String("webhook_id", req.WebhookID),
+			zap.Uint64("limit", req.Limit),
+			zap.Uint64("offset", req.Offset),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, totalItems, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetWebhookDeliveries"),
+		zap.String("customer_id", req.CustomerID),
+		zap.String("webhook_id", req.WebhookID),
+		zap.Uint64("limit", req.Limit),
+		zap.Uint64("offset", req.Offset),
+		zap.Int("num_deliveries", len(res)),
+		zap.Uint64("total_items", totalItems),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, totalItems, nil
+}
+
+func (mw *logging) GetConfirmationPolicy(ctx context.Context, req *service.GetConfirmationPolicyReq) (*model.ConfirmationPolicy, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetConfirmationPolicy(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetConfirmationPolicy"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetConfirmationPolicy"),
+		zap.String("customer_id", req.CustomerID),
+		zap.String("policy_customer_id", res.CustomerID),
+		zap.Uint64("confirmations", res.Confirmations),
+		zap.Int("num_thresholds", len(res.Thresholds)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) SetConfirmationPolicy(ctx context.Context, req *service.SetConfirmationPolicyReq) error {
+	now := time.Now()
+
+	err := mw.next.SetConfirmationPolicy(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "SetConfirmationPolicy"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.Uint64("confirmations", req.Confirmations),
+			zap.Int("num_thresholds", len(req.Thresholds)),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "SetConfirmationPolicy"),
+		zap.String("customer_id", req.CustomerID),
+		zap.Uint64("confirmations", req.Confirmations),
+		zap.Int("num_thresholds", len(req.Thresholds)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *logging) GetBalanceDiscrepancies(ctx context.Context, req *service.GetBalanceDiscrepanciesReq) ([]*model.BalanceDiscrepancy, uint64, error) {
+	now := time.Now()
+
+	res, totalItems, err := mw.next.GetBalanceDiscrepancies(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBalanceDiscrepancies"),
+			zap.Error(err),
+			zap.Time("from_date", req.FromDate),
+			zap.Time("to_date", req.ToDate),
+			zap.Uint64("limit", req.Limit),
+			zap.Uint64("offset", req.Offset),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, totalItems, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetBalanceDiscrepancies"),
+		zap.Time("from_date", req.FromDate),
+		zap.Time("to_date", req.ToDate),
+		zap.Uint64("limit", req.Limit),
+		zap.Uint64("offset", req.Offset),
+		zap.Int("num_discrepancies", len(res)),
+		zap.Uint64("total_items", totalItems),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, totalItems, nil
+}
+
+func (mw *logging) GetBalanceSeries(ctx context.Context, req *service.GetBalanceSeriesReq) ([]*model.BalanceSnapshot, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetBalanceSeries(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBalanceSeries"),
+			zap.Error(err),
+			zap.String("address", req.Address),
+			zap.String("boundary", req.Boundary),
+			zap.Time("from_date", req.FromDate),
+			zap.Time("to_date", req.ToDate),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetBalanceSeries"),
+		zap.String("address", req.Address),
+		zap.String("boundary", req.Boundary),
+		zap.Time("from_date", req.FromDate),
+		zap.Time("to_date", req.ToDate),
+		zap.Int("num_snapshots", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) GetBlockAtTime(ctx context.Context, req *service.GetBlockAtTimeReq) (*common_model.Block, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetBlockAtTime(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBlockAtTime"),
+			zap.Error(err),
+			zap.Time("date", req.Date),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetBlockAtTime"),
+		zap.Time("date", req.Date),
+		zap.Uint64("block_number", res.Number),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) ReplaceBroadcast(ctx context.Context, req *service.ReplaceBroadcastReq) (string, error) {
+	now := time.Now()
+
+	res, err := mw.next.ReplaceBroadcast(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "ReplaceBroadcast"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.String("hash", req.Hash),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "ReplaceBroadcast"),
+		zap.String("customer_id", req.CustomerID),
+		zap.String("hash", req.Hash),
+		zap.String("result", res),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) GetBroadcastReplacements(ctx context.Context, req *service.GetBroadcastReplacementsReq) (*model.BroadcastReplacements, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetBroadcastReplacements(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBroadcastReplacements"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.String("hash", req.Hash),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetBroadcastReplacements"),
+		zap.String("customer_id", req.CustomerID),
+		zap.String("hash", req.Hash),
+		zap.Int("num_transactions", len(res.Transactions)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) GetBroadcastQueues(ctx context.Context, req *service.GetBroadcastQueuesReq) ([]*model.BroadcastQueue, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetBroadcastQueues(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBroadcastQueues"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.String("source", req.Source),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetBroadcastQueues"),
+		zap.String("customer_id", req.CustomerID),
+		zap.String("source", req.Source),
+		zap.Int("num_queues", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) GetOperationGroup(ctx context.Context, req *service.GetOperationGroupReq) (*model.OperationGroup, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetOperationGroup(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetOperationGroup"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.String("hash", req.Hash),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetOperationGroup"),
+		zap.String("customer_id", req.CustomerID),
+		zap.String("hash", req.Hash),
+		zap.String("status", res.Status),
+		zap.Int("num_contents", len(res.Contents)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) GetBroadcastHistory(ctx context.Context, req *service.GetBroadcastHistoryReq) (*model.BroadcastHistory, error) {
+	now := time.Now()
+
+	res, err := mw.next.GetBroadcastHistory(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBroadcastHistory"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.String("hash", req.Hash),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "GetBroadcastHistory"),
+		zap.String("customer_id", req.CustomerID),
+		zap.String("hash", req.Hash),
+		zap.String("status", res.Status),
+		zap.Int("num_entries", len(res.Entries)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *logging) CancelBroadcast(ctx context.Context, req *service.CancelBroadcastReq) (*model.BroadcastCancellation, error) {
+	now := time.Now()
+
+	res, err := mw.next.CancelBroadcast(ctx, req)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CancelBroadcast"),
+			zap.Error(err),
+			zap.String("customer_id", req.CustomerID),
+			zap.String("hash", req.Hash),
+			zap.String("cancelled_by", req.CancelledBy),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Info(ctx, "request completed",
+		zap.String("method", "CancelBroadcast"),
+		zap.String("customer_id", req.CustomerID),
+		zap.String("hash", req.Hash),
+		zap.String("cancelled_by", req.CancelledBy),
+		zap.Bool("may_be_included", res.MayBeIncluded),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
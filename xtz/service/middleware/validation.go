@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/service"
 
@@ -100,3 +101,123 @@ func (mw *validation) GetTransactionsByAttributes(ctx context.Context, req *serv
 	}
 	return mw.next.GetTransactionsByAttributes(ctx, req)
 }
+
+func (mw *validation) GetAddressOnboarding(ctx context.Context, req *service.GetAddressOnboardingReq) ([]*model.AddressOnboarding, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.GetAddressOnboarding(ctx, req)
+}
+
+func (mw *validation) CreateWebhook(ctx context.Context, req *service.CreateWebhookReq) (string, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return "", err
+	}
+	return mw.next.CreateWebhook(ctx, req)
+}
+
+func (mw *validation) GetWebhooks(ctx context.Context, req *service.GetWebhooksReq) ([]*model.Webhook, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.GetWebhooks(ctx, req)
+}
+
+func (mw *validation) DeleteWebhook(ctx context.Context, req *service.DeleteWebhookReq) error {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return err
+	}
+	return mw.next.DeleteWebhook(ctx, req)
+}
+
+func (mw *validation) GetWebhookDeliveries(ctx context.Context, req *service.GetWebhookDeliveriesReq) ([]*model.WebhookDelivery, uint64, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	return mw.next.GetWebhookDeliveries(ctx, req)
+}
+
+func (mw *validation) GetConfirmationPolicy(ctx context.Context, req *service.GetConfirmationPolicyReq) (*model.ConfirmationPolicy, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.GetConfirmationPolicy(ctx, req)
+}
+
+func (mw *validation) SetConfirmationPolicy(ctx context.Context, req *service.SetConfirmationPolicyReq) error {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return err
+	}
+	return mw.next.SetConfirmationPolicy(ctx, req)
+}
+
+func (mw *validation) GetBalanceSeries(ctx context.Context, req *service.GetBalanceSeriesReq) ([]*model.BalanceSnapshot, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.GetBalanceSeries(ctx, req)
+}
+
+func (mw *validation) GetBlockAtTime(ctx context.Context, req *service.GetBlockAtTimeReq) (*common_model.Block, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.GetBlockAtTime(ctx, req)
+}
+
+func (mw *validation) ReplaceBroadcast(ctx context.Context, req *service.ReplaceBroadcastReq) (string, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return "", err
+	}
+	return mw.next.ReplaceBroadcast(ctx, req)
+}
+
+func (mw *validation) GetBroadcastReplacements(ctx context.Context, req *service.GetBroadcastReplacementsReq) (*model.BroadcastReplacements, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.GetBroadcastReplacements(ctx, req)
+}
+
+func (mw *validation) GetBroadcastQueues(ctx context.Context, req *service.GetBroadcastQueuesReq) ([]*model.BroadcastQueue, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.GetBroadcastQueues(ctx, req)
+}
+
+func (mw *validation) GetOperationGroup(ctx context.Context, req *service.GetOperationGroupReq) (*model.OperationGroup, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.GetOperationGroup(ctx, req)
+}
+
+func (mw *validation) GetBroadcastHistory(ctx context.Context, req *service.GetBroadcastHistoryReq) (*model.BroadcastHistory, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.GetBroadcastHistory(ctx, req)
+}
+
+func (mw *validation) CancelBroadcast(ctx context.Context, req *service.CancelBroadcastReq) (*model.BroadcastCancellation, error) {
+	err := mw.validate.Struct(req)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.CancelBroadcast(ctx, req)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	val "github.com/t-dx/tg-blocksd/internal/utils/validation"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/service"
 
@@ -79,6 +80,31 @@ func Test_XTZValidationAddAddresses(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			req: &service.AddAddressesReq{
+				Network:    "mainnet",
+				Addresses:  []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"},
+				SinceBlock: 1200000,
+			},
+			valid: true,
+		},
+		{
+			req: &service.AddAddressesReq{
+				Network:   "mainnet",
+				Addresses: []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"},
+				SinceDate: time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+			},
+			valid: true,
+		},
+		{
+			req: &service.AddAddressesReq{
+				Network:    "mainnet",
+				Addresses:  []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"},
+				SinceBlock: 1200000,
+				SinceDate:  time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), // since block and date are exclusive
+			},
+			valid: false,
+		},
 	}
 
 	for i, test := range tests {
@@ -123,6 +149,26 @@ func Test_XTZValidationBroadcast(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			req: &service.BroadcastByCustomerReq{
+				Network:        "mainnet",
+				CustomerID:     "daae03ef-fa60-4b22-9c10-552de333711a",
+				RawTransaction: "85a9ef47f6b1cc1432faaf87a242b08a42ea9e0c552b73ad6751efa5a75440376e00b1c4383a317576851a825b86aa59dc030e2ecb38dc0be0ab1ebc5000ff00a31e81ac3425310e3274a4698a793b2839dc0afa5f5d8672a4ee19cec93d8b7aa354a82dcaf534deeeb6345daa296eab5dba0520a334cebc8ed1b8c1a4d15de399dd0ad6494e3e17fff88b416131ade7d0d79e00",
+				NotBeforeBlock: 500000,
+				NotBefore:      time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+				AfterHash:      "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+			},
+			valid: true,
+		},
+		{
+			req: &service.BroadcastByCustomerReq{
+				Network:        "mainnet",
+				CustomerID:     "daae03ef-fa60-4b22-9c10-552de333711a",
+				RawTransaction: "85a9ef47f6b1cc1432faaf87a242b08a42ea9e0c552b73ad6751efa5a75440376e00b1c4383a317576851a825b86aa59dc030e2ecb38dc0be0ab1ebc5000ff00a31e81ac3425310e3274a4698a793b2839dc0afa5f5d8672a4ee19cec93d8b7aa354a82dcaf534deeeb6345daa296eab5dba0520a334cebc8ed1b8c1a4d15de399dd0ad6494e3e17fff88b416131ade7d0d79e00",
+				AfterHash:      "invalid hash",
+			},
+			valid: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -207,6 +253,23 @@ func Test_XTZValidationGetBalances(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			req: &service.GetBalancesReq{
+				Network:   "mainnet",
+				Addresses: []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"},
+				AtDate:    time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+			},
+			valid: true,
+		},
+		{
+			req: &service.GetBalancesReq{
+				Network:     "mainnet",
+				Addresses:   []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"},
+				BlockNumber: 1200000,
+				AtDate:      time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), // block number and date are exclusive
+			},
+			valid: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -467,6 +530,550 @@ func Test_XTZValidationGetTransactionsByDates(t *testing.T) {
 	}
 }
 
+func Test_XTZValidationGetAddressOnboarding(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.GetAddressOnboardingReq
+		valid bool
+	}{
+		{
+			req: &service.GetAddressOnboardingReq{
+				Network:   "mainnet",
+				Addresses: []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2"},
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{req: &service.GetAddressOnboardingReq{Network: "mainnet"}, valid: false},
+		{
+			req: &service.GetAddressOnboardingReq{
+				Network:   "mainnet",
+				Addresses: []string{"0xdac17f958d2ee523a2206206994597c13d831ec7"}, // wrong format
+			},
+			valid: false,
+		},
+	}
+
+	for i, test := range tests {
+		_, err := svc.GetAddressOnboarding(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err, i)
+		} else {
+			require.NotNil(t, err, i)
+		}
+	}
+}
+
+func Test_XTZValidationCreateWebhook(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.CreateWebhookReq
+		valid bool
+	}{
+		{
+			req: &service.CreateWebhookReq{
+				Network:       "mainnet",
+				CustomerID:    "customer",
+				URL:           "https://example.com/hooks/xtz",
+				Secret:        "0123456789abcdef",
+				Addresses:     []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"},
+				Confirmations: 30,
+			},
+			valid: true,
+		},
+		{
+			// Broadcast events only.
+			req: &service.CreateWebhookReq{
+				Network:    "mainnet",
+				CustomerID: "customer",
+				URL:        "https://example.com/hooks/xtz",
+				Secret:     "0123456789abcdef",
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{
+			req: &service.CreateWebhookReq{
+				Network:    "mainnet",
+				CustomerID: "customer",
+				URL:        "not an url",
+				Secret:     "0123456789abcdef",
+			},
+			valid: false,
+		},
+		{
+			req: &service.CreateWebhookReq{
+				Network:    "mainnet",
+				CustomerID: "customer",
+				URL:        "https://example.com/hooks/xtz",
+				Secret:     "short",
+			},
+			valid: false,
+		},
+		{
+			req: &service.CreateWebhookReq{
+				Network:    "mainnet",
+				CustomerID: "customer",
+				URL:        "https://example.com/hooks/xtz",
+				Secret:     "0123456789abcdef",
+				Addresses:  []string{"0xdac17f958d2ee523a2206206994597c13d831ec7"}, // wrong format
+			},
+			valid: false,
+		},
+	}
+
+	for i, test := range tests {
+		_, err := svc.CreateWebhook(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err, i)
+		} else {
+			require.NotNil(t, err, i)
+		}
+	}
+}
+
+func Test_XTZValidationSetConfirmationPolicy(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.SetConfirmationPolicyReq
+		valid bool
+	}{
+		{
+			req: &service.SetConfirmationPolicyReq{
+				Network:       "mainnet",
+				CustomerID:    "customer",
+				Confirmations: 2,
+				Thresholds: []*service.SetConfirmationThresholdReq{
+					{MinAmount: "1000000000", Confirmations: 10},
+					{MinAmount: "100000000000", Confirmations: 30},
+				},
+			},
+			valid: true,
+		},
+		{
+			// Same confirmations for all amounts.
+			req: &service.SetConfirmationPolicyReq{
+				Network:       "mainnet",
+				CustomerID:    "customer",
+				Confirmations: 2,
+			},
+			valid: true,
+		},
+		{
+			// Default policy.
+			req: &service.SetConfirmationPolicyReq{
+				Network:       "mainnet",
+				Confirmations: 2,
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{
+			req: &service.SetConfirmationPolicyReq{
+				Network:       "mainnet",
+				CustomerID:    "customer",
+				Confirmations: 0,
+			},
+			valid: false,
+		},
+		{
+			req: &service.SetConfirmationPolicyReq{
+				Network:       "mainnet",
+				CustomerID:    "customer",
+				Confirmations: 2,
+				Thresholds:    []*service.SetConfirmationThresholdReq{{MinAmount: "-1000", Confirmations: 10}},
+			},
+			valid: false,
+		},
+		{
+			req: &service.SetConfirmationPolicyReq{
+				Network:       "mainnet",
+				CustomerID:    "customer",
+				Confirmations: 2,
+				Thresholds:    []*service.SetConfirmationThresholdReq{nil},
+			},
+			valid: false,
+		},
+	}
+
+	for i, test := range tests {
+		err := svc.SetConfirmationPolicy(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err, i)
+		} else {
+			require.NotNil(t, err, i)
+		}
+	}
+}
+
+func Test_XTZValidationGetBalanceSeries(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.GetBalanceSeriesReq
+		valid bool
+	}{
+		{
+			req: &service.GetBalanceSeriesReq{
+				Network:  "mainnet",
+				Address:  "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d",
+				Boundary: "daily",
+				FromDate: time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+				ToDate:   time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC),
+			},
+			valid: true,
+		},
+		{
+			// All boundaries.
+			req: &service.GetBalanceSeriesReq{
+				Network:  "mainnet",
+				Address:  "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d",
+				FromDate: time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+				ToDate:   time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC),
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{
+			req: &service.GetBalanceSeriesReq{
+				Network:  "mainnet",
+				Address:  "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d",
+				Boundary: "weekly",
+				FromDate: time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+				ToDate:   time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC),
+			},
+			valid: false,
+		},
+		{
+			req: &service.GetBalanceSeriesReq{
+				Network:  "mainnet",
+				Address:  "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d",
+				FromDate: time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC),
+				ToDate:   time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), // to date before from date
+			},
+			valid: false,
+		},
+	}
+
+	for i, test := range tests {
+		_, err := svc.GetBalanceSeries(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err, i)
+		} else {
+			require.NotNil(t, err, i)
+		}
+	}
+}
+
+func Test_XTZValidationGetBlockAtTime(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.GetBlockAtTimeReq
+		valid bool
+	}{
+		{
+			req: &service.GetBlockAtTimeReq{
+				Network: "mainnet",
+				Date:    time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{req: &service.GetBlockAtTimeReq{Network: "mainnet"}, valid: false},
+		{
+			req: &service.GetBlockAtTimeReq{
+				Network: "wrongnetwork",
+				Date:    time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+			},
+			valid: false,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := svc.GetBlockAtTime(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err)
+		} else {
+			require.NotNil(t, err)
+		}
+	}
+}
+
+func Test_XTZValidationReplaceBroadcast(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.ReplaceBroadcastReq
+		valid bool
+	}{
+		{
+			req: &service.ReplaceBroadcastReq{
+				Network:        "mainnet",
+				CustomerID:     "customer",
+				Hash:           "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+				RawTransaction: "85a9ef47f6b1cc1432faaf87a242b08a42ea9e0c552b73ad6751efa5a75440376e00b1c4383a317576851a825b86aa59dc030e2ecb38dc0be0ab1ebc5000ff00a31e81ac3425310e3274a4698a793b2839dc0afa5f5d8672a4ee19cec93d8b7aa354a82dcaf534deeeb6345daa296eab5dba0520a334cebc8ed1b8c1a4d15de399dd0ad6494e3e17fff88b416131ade7d0d79e00",
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{
+			req: &service.ReplaceBroadcastReq{
+				Network:        "mainnet",
+				CustomerID:     "", // missing customer ID
+				Hash:           "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+				RawTransaction: "85a9ef47f6b1cc1432faaf87a242b08a42ea9e0c552b73ad6751efa5a75440376e00b1c4383a317576851a825b86aa59dc030e2ecb38dc0be0ab1ebc5000ff00a31e81ac3425310e3274a4698a793b2839dc0afa5f5d8672a4ee19cec93d8b7aa354a82dcaf534deeeb6345daa296eab5dba0520a334cebc8ed1b8c1a4d15de399dd0ad6494e3e17fff88b416131ade7d0d79e00",
+			},
+			valid: false,
+		},
+		{
+			req: &service.ReplaceBroadcastReq{
+				Network:        "mainnet",
+				CustomerID:     "customer",
+				Hash:           "invalid hash",
+				RawTransaction: "85a9ef47f6b1cc1432faaf87a242b08a42ea9e0c552b73ad6751efa5a75440376e00b1c4383a317576851a825b86aa59dc030e2ecb38dc0be0ab1ebc5000ff00a31e81ac3425310e3274a4698a793b2839dc0afa5f5d8672a4ee19cec93d8b7aa354a82dcaf534deeeb6345daa296eab5dba0520a334cebc8ed1b8c1a4d15de399dd0ad6494e3e17fff88b416131ade7d0d79e00",
+			},
+			valid: false,
+		},
+		{
+			req: &service.ReplaceBroadcastReq{
+				Network:        "mainnet",
+				CustomerID:     "customer",
+				Hash:           "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+				RawTransaction: "",
+			},
+			valid: false,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := svc.ReplaceBroadcast(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err)
+		} else {
+			require.NotNil(t, err)
+		}
+	}
+}
+
+func Test_XTZValidationGetBroadcastReplacements(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.GetBroadcastReplacementsReq
+		valid bool
+	}{
+		{
+			req: &service.GetBroadcastReplacementsReq{
+				Network:    "mainnet",
+				CustomerID: "customer",
+				Hash:       "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{req: &service.GetBroadcastReplacementsReq{Network: "mainnet", CustomerID: "customer"}, valid: false},
+		{
+			req: &service.GetBroadcastReplacementsReq{
+				Network: "mainnet",
+				Hash:    "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+			},
+			valid: false,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := svc.GetBroadcastReplacements(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err)
+		} else {
+			require.NotNil(t, err)
+		}
+	}
+}
+
+func Test_XTZValidationGetBroadcastQueues(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.GetBroadcastQueuesReq
+		valid bool
+	}{
+		{req: &service.GetBroadcastQueuesReq{Network: "mainnet", CustomerID: "customer"}, valid: true},
+		{
+			req: &service.GetBroadcastQueuesReq{
+				Network:    "mainnet",
+				CustomerID: "customer",
+				Source:     "tz1bqyMeoid3NKnK1bPtnD1ceb2eSSqAd7Qi",
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{req: &service.GetBroadcastQueuesReq{Network: "mainnet"}, valid: false},
+		{
+			req: &service.GetBroadcastQueuesReq{
+				Network:    "mainnet",
+				CustomerID: "customer",
+				Source:     "invalid address",
+			},
+			valid: false,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := svc.GetBroadcastQueues(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err)
+		} else {
+			require.NotNil(t, err)
+		}
+	}
+}
+
+func Test_XTZValidationGetOperationGroup(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.GetOperationGroupReq
+		valid bool
+	}{
+		{
+			req: &service.GetOperationGroupReq{
+				Network:    "mainnet",
+				CustomerID: "customer",
+				Hash:       "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{req: &service.GetOperationGroupReq{Network: "mainnet", CustomerID: "customer"}, valid: false},
+		{
+			req: &service.GetOperationGroupReq{
+				Network: "mainnet",
+				Hash:    "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+			},
+			valid: false,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := svc.GetOperationGroup(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err)
+		} else {
+			require.NotNil(t, err)
+		}
+	}
+}
+
+func Test_XTZValidationGetBroadcastHistory(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.GetBroadcastHistoryReq
+		valid bool
+	}{
+		{
+			req: &service.GetBroadcastHistoryReq{
+				Network:    "mainnet",
+				CustomerID: "customer",
+				Hash:       "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{req: &service.GetBroadcastHistoryReq{Network: "mainnet", CustomerID: "customer"}, valid: false},
+		{
+			req: &service.GetBroadcastHistoryReq{
+				Network: "mainnet",
+				Hash:    "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+			},
+			valid: false,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := svc.GetBroadcastHistory(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err)
+		} else {
+			require.NotNil(t, err)
+		}
+	}
+}
+
+func Test_XTZValidationCancelBroadcast(t *testing.T) {
+	svc := Validation(val.NewValidator())(&mockXTZService{})
+
+	ctx := context.Background()
+	tests := []struct {
+		req   *service.CancelBroadcastReq
+		valid bool
+	}{
+		{
+			req: &service.CancelBroadcastReq{
+				Network:     "mainnet",
+				CustomerID:  "customer",
+				Hash:        "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+				CancelledBy: "compliance",
+				Reason:      "withdrawal cancelled",
+			},
+			valid: true,
+		},
+		{req: nil, valid: false},
+		{
+			req: &service.CancelBroadcastReq{
+				Network:    "mainnet",
+				CustomerID: "customer",
+				Hash:       "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+				Reason:     "withdrawal cancelled",
+			},
+			valid: false,
+		},
+		{
+			req: &service.CancelBroadcastReq{
+				Network:     "mainnet",
+				CustomerID:  "customer",
+				Hash:        "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW",
+				CancelledBy: "compliance",
+			},
+			valid: false,
+		},
+		{
+			req: &service.CancelBroadcastReq{
+				Network:     "mainnet",
+				CustomerID:  "customer",
+				Hash:        "invalid hash",
+				CancelledBy: "compliance",
+				Reason:      "withdrawal cancelled",
+			},
+			valid: false,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := svc.CancelBroadcast(ctx, test.req)
+		if test.valid {
+			require.Nil(t, err)
+		} else {
+			require.NotNil(t, err)
+		}
+	}
+}
+
 type mockXTZService struct{}
 
 func (m *mockXTZService) AddAddresses(ctx context.Context, req *service.AddAddressesReq) error {
@@ -499,3 +1106,48 @@ func (m *mockXTZService) GetTransactionsByDates(ctx context.Context, req *servic
 func (m *mockXTZService) GetTransactionsByAttributes(ctx context.Context, req *service.GetTransactionsByAttributesByCustomerReq) ([]*model.Transaction, uint64, error) {
 	return nil, 0, nil
 }
+func (m *mockXTZService) GetAddressOnboarding(ctx context.Context, req *service.GetAddressOnboardingReq) ([]*model.AddressOnboarding, error) {
+	return nil, nil
+}
+func (m *mockXTZService) CreateWebhook(ctx context.Context, req *service.CreateWebhookReq) (string, error) {
+	return "", nil
+}
+func (m *mockXTZService) GetWebhooks(ctx context.Context, req *service.GetWebhooksReq) ([]*model.Webhook, error) {
+	return nil, nil
+}
+func (m *mockXTZService) DeleteWebhook(ctx context.Context, req *service.DeleteWebhookReq) error {
+	return nil
+}
+func (m *mockXTZService) GetWebhookDeliveries(ctx context.Context, req *service.GetWebhookDeliveriesReq) ([]*model.WebhookDelivery, uint64, error) {
+	return nil, 0, nil
+}
+func (m *mockXTZService) GetConfirmationPolicy(ctx context.Context, req *service.GetConfirmationPolicyReq) (*model.ConfirmationPolicy, error) {
+	return nil, nil
+}
+func (m *mockXTZService) SetConfirmationPolicy(ctx context.Context, req *service.SetConfirmationPolicyReq) error {
+	return nil
+}
+func (m *mockXTZService) GetBalanceSeries(ctx context.Context, req *service.GetBalanceSeriesReq) ([]*model.BalanceSnapshot, error) {
+	return nil, nil
+}
+func (m *mockXTZService) GetBlockAtTime(ctx context.Context, req *service.GetBlockAtTimeReq) (*common_model.Block, error) {
+	return nil, nil
+}
+func (m *mockXTZService) ReplaceBroadcast(ctx context.Context, req *service.ReplaceBroadcastReq) (string, error) {
+	return "", nil
+}
+func (m *mockXTZService) GetBroadcastReplacements(ctx context.Context, req *service.GetBroadcastReplacementsReq) (*model.BroadcastReplacements, error) {
+	return nil, nil
+}
+func (m *mockXTZService) GetBroadcastQueues(ctx context.Context, req *service.GetBroadcastQueuesReq) ([]*model.BroadcastQueue, error) {
+	return nil, nil
+}
+func (m *mockXTZService) GetOperationGroup(ctx context.Context, req *service.GetOperationGroupReq) (*model.OperationGroup, error) {
+	return nil, nil
+}
+func (m *mockXTZService) GetBroadcastHistory(ctx context.Context, req *service.GetBroadcastHistoryReq) (*model.BroadcastHistory, error) {
+	return nil, nil
+}
+func (m *mockXTZService) CancelBroadcast(ctx context.Context, req *service.CancelBroadcastReq) (*model.BroadcastCancellation, error) {
+	return nil, nil
+}
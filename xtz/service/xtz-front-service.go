@@ -4,20 +4,82 @@ import (
 	"context"
 	"time"
 
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	common_service "github.com/t-dx/tg-blocksd/pkg/common/service"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
 )
 
+// AddAddressesReq adds addresses to index. If SinceBlock or SinceDate is set, the history of the addresses
+// since then is scanned and pinned, see GetAddressOnboarding for its progress.
 type AddAddressesReq struct {
+	Network    string   `validate:"required,blockchainnetworkmainnet"`
+	Addresses  []string `validate:"required,lt=100,dive,min=1,max=1000,xtzaddress"`
+	SinceBlock uint64   `validate:"excluded_with=SinceDate"`
+	SinceDate  time.Time
+}
+
+type GetAddressOnboardingReq struct {
 	Network   string   `validate:"required,blockchainnetworkmainnet"`
 	Addresses []string `validate:"required,lt=100,dive,min=1,max=1000,xtzaddress"`
 }
 
+// BroadcastByCustomerReq broadcasts a raw transaction of the customer. It is scheduled after the level NotBeforeBlock
+// and the time NotBefore if set, and conditioned on the inclusion of the transaction AfterHash if set, see BroadcastReq.
 type BroadcastByCustomerReq struct {
 	Network        string            `validate:"required,blockchainnetworkmainnet"`
 	CustomerID     string            `validate:"required,max=100,safestring"`
 	RawTransaction string            `validate:"required,min=1,max=10000,xtzrawtransaction"`
 	Attributes     map[string]string `validate:"max=100,dive,keys,max=254,safestring,endkeys,max=254,generalstring"`
+	NotBeforeBlock uint64
+	NotBefore      time.Time
+	AfterHash      string `validate:"omitempty,xtzhash"`
+}
+
+// ReplaceBroadcastReq replaces the pending broadcast of Hash by RawTransaction, an operation from the same source with
+// the same counter and a higher fee.
+type ReplaceBroadcastReq struct {
+	Network        string `validate:"required,blockchainnetworkmainnet"`
+	CustomerID     string `validate:"required,max=100,safestring"`
+	Hash           string `validate:"required,xtzhash"`
+	RawTransaction string `validate:"required,min=1,max=10000,xtzrawtransaction"`
+}
+
+// GetBroadcastReplacementsReq gets the broadcasts of the operation of Hash replaced by fee.
+type GetBroadcastReplacementsReq struct {
+	Network    string `validate:"required,blockchainnetworkmainnet"`
+	CustomerID string `validate:"required,max=100,safestring"`
+	Hash       string `validate:"required,xtzhash"`
+}
+
+// GetBroadcastQueuesReq gets the queues of the pending broadcasts of the customer, for a single source address if
+// Source is set.
+type GetBroadcastQueuesReq struct {
+	Network    string `validate:"required,blockchainnetworkmainnet"`
+	CustomerID string `validate:"required,max=100,safestring"`
+	Source     string `validate:"omitempty,xtzaddress"`
+}
+
+// GetOperationGroupReq gets the status of the broadcasted operation Hash and of each of its contents.
+type GetOperationGroupReq struct {
+	Network    string `validate:"required,blockchainnetworkmainnet"`
+	CustomerID string `validate:"required,max=100,safestring"`
+	Hash       string `validate:"required,xtzhash"`
+}
+
+// GetBroadcastHistoryReq gets what happened to the broadcast Hash of the customer.
+type GetBroadcastHistoryReq struct {
+	Network    string `validate:"required,blockchainnetworkmainnet"`
+	CustomerID string `validate:"required,max=100,safestring"`
+	Hash       string `validate:"required,xtzhash"`
+}
+
+// CancelBroadcastReq cancels the pending broadcast Hash of the customer. CancelledBy and Reason are recorded.
+type CancelBroadcastReq struct {
+	Network     string `validate:"required,blockchainnetworkmainnet"`
+	CustomerID  string `validate:"required,max=100,safestring"`
+	Hash        string `validate:"required,xtzhash"`
+	CancelledBy string `validate:"required,max=100,safestring"`
+	Reason      string `validate:"required,max=1000,generalstring"`
 }
 
 type GetBlockchainInfoReq struct {
@@ -28,10 +90,19 @@ type GetEstimatedFeeReq struct {
 	Network string `validate:"required,blockchainnetworkmainnet"`
 }
 
+// GetBalancesReq gets the balances of addresses at the tip, and at BlockNumber or at the last block produced at or
+// before AtDate if set.
 type GetBalancesReq struct {
 	Network     string   `validate:"required,blockchainnetworkmainnet"`
 	Addresses   []string `validate:"required,lt=100,dive,min=1,max=1000,xtzaddress"`
-	BlockNumber uint64
+	BlockNumber uint64   `validate:"excluded_with=AtDate"`
+	AtDate      time.Time
+}
+
+// GetBlockAtTimeReq gets the last block produced at or before Date.
+type GetBlockAtTimeReq struct {
+	Network string    `validate:"required,blockchainnetworkmainnet"`
+	Date    time.Time `validate:"required"`
 }
 
 type GetCountersReq struct {
@@ -39,30 +110,35 @@ type GetCountersReq struct {
 	Addresses []string `validate:"required,lt=100,dive,min=1,max=1000,xtzaddress"`
 }
 
+// GetTransactionsByHashesByCustomerReq gets transactions by hash. Their Confirmed state is computed under the
+// confirmation policy of the customer, and only the confirmed ones are returned if ConfirmedOnly is set.
 type GetTransactionsByHashesByCustomerReq struct {
-	Network    string   `validate:"required,blockchainnetworkmainnet"`
-	CustomerID string   `validate:"required,max=100,safestring"`
-	Hashes     []string `validate:"required,lt=100,dive,xtzhash"`
+	Network       string   `validate:"required,blockchainnetworkmainnet"`
+	CustomerID    string   `validate:"required,max=100,safestring"`
+	Hashes        []string `validate:"required,lt=100,dive,xtzhash"`
+	ConfirmedOnly bool
 }
 
 type GetTransactionsByBlocksByCustomerReq struct {
-	Network    string   `validate:"required,blockchainnetworkmainnet"`
-	CustomerID string   `validate:"required,max=100,safestring"`
-	Addresses  []string `validate:"required,lt=100,dive,min=1,max=1000,xtzaddress"`
-	FromBlock  uint64
-	ToBlock    uint64 `validate:"eq=0|gtecsfield=FromBlock"`
-	Limit      uint64 `validate:"lt=200"`
-	Offset     uint64
+	Network       string   `validate:"required,blockchainnetworkmainnet"`
+	CustomerID    string   `validate:"required,max=100,safestring"`
+	Addresses     []string `validate:"required,lt=100,dive,min=1,max=1000,xtzaddress"`
+	FromBlock     uint64
+	ToBlock       uint64 `validate:"eq=0|gtecsfield=FromBlock"`
+	Limit         uint64 `validate:"lt=200"`
+	Offset        uint64
+	ConfirmedOnly bool
 }
 
 type GetTransactionsByDatesByCustomerReq struct {
-	Network    string   `validate:"required,blockchainnetworkmainnet"`
-	CustomerID string   `validate:"required,max=100,safestring"`
-	Addresses  []string `validate:"required,lt=100,dive,min=1,max=1000,xtzaddress"`
-	FromDate   time.Time
-	ToDate     time.Time `validate:"gtecsfield=FromDate"`
-	Limit      uint64    `validate:"lt=200"`
-	Offset     uint64
+	Network       string   `validate:"required,blockchainnetworkmainnet"`
+	CustomerID    string   `validate:"required,max=100,safestring"`
+	Addresses     []string `validate:"required,lt=100,dive,min=1,max=1000,xtzaddress"`
+	FromDate      time.Time
+	ToDate        time.Time `validate:"gtecsfield=FromDate"`
+	Limit         uint64    `validate:"lt=200"`
+	Offset        uint64
+	ConfirmedOnly bool
 }
 
 type GetTransactionsByAttributesByCustomerReq struct {
@@ -70,6 +146,68 @@ type GetTransactionsByAttributesByCustomerReq struct {
 	CustomerID     string `validate:"required,max=100,safestring"`
 	AttributeKey   string `validate:"required,max=254,safestring"`
 	AttributeValue string `validate:"required,max=254,generalstring"`
+	ConfirmedOnly  bool
+}
+
+// CreateWebhookReq registers a webhook of the customer. The transfer events are emitted for the transactions of
+// Addresses indexed after its creation, the transfer.confirmed event only if Confirmations is set. URL should be an
+// http or https URL of a public host, see webhook.CheckURL.
+type CreateWebhookReq struct {
+	Network       string   `validate:"required,blockchainnetworkmainnet"`
+	CustomerID    string   `validate:"required,max=100,safestring"`
+	URL           string   `validate:"required,max=2000,url"`
+	Secret        string   `validate:"required,min=16,max=256"`
+	Addresses     []string `validate:"lt=100,dive,min=1,max=1000,xtzaddress"`
+	Confirmations uint64   `validate:"lte=1000"`
+}
+
+type GetWebhooksReq struct {
+	Network    string `validate:"required,blockchainnetworkmainnet"`
+	CustomerID string `validate:"required,max=100,safestring"`
+}
+
+type DeleteWebhookReq struct {
+	Network    string `validate:"required,blockchainnetworkmainnet"`
+	CustomerID string `validate:"required,max=100,safestring"`
+	ID         string `validate:"required,uuid"`
+}
+
+type GetWebhookDeliveriesReq struct {
+	Network    string `validate:"required,blockchainnetworkmainnet"`
+	CustomerID string `validate:"required,max=100,safestring"`
+	WebhookID  string `validate:"required,uuid"`
+	Limit      uint64 `validate:"lt=200"`
+	Offset     uint64
+}
+
+// GetBalanceSeriesReq gets the balance snapshots of an address between two dates, at the given boundary if set.
+type GetBalanceSeriesReq struct {
+	Network  string `validate:"required,blockchainnetworkmainnet"`
+	Address  string `validate:"required,min=1,max=1000,xtzaddress"`
+	Boundary string `validate:"omitempty,oneof=daily cycle"`
+	FromDate time.Time
+	ToDate   time.Time `validate:"gtecsfield=FromDate"`
+}
+
+// GetConfirmationPolicyReq gets the confirmation policy applying to the customer, the default one if CustomerID is empty.
+type GetConfirmationPolicyReq struct {
+	Network    string `validate:"required,blockchainnetworkmainnet"`
+	CustomerID string `validate:"omitempty,max=100,safestring"`
+}
+
+// SetConfirmationPolicyReq sets the confirmation policy of the customer. Confirmations are required for the amounts
+// below the first threshold, and the confirmations of the last threshold reached for the others.
+// An empty CustomerID sets the default policy, applying to the customers without one.
+type SetConfirmationPolicyReq struct {
+	Network       string                         `validate:"required,blockchainnetworkmainnet"`
+	CustomerID    string                         `validate:"omitempty,max=100,safestring"`
+	Confirmations uint64                         `validate:"gte=1,lte=1000"`
+	Thresholds    []*SetConfirmationThresholdReq `validate:"lt=20,dive,required"`
+}
+
+type SetConfirmationThresholdReq struct {
+	MinAmount     string `validate:"required,max=40,number"`
+	Confirmations uint64 `validate:"gte=1,lte=1000"`
 }
 
 // XTZFronter defines the tezos service API.
@@ -84,6 +222,21 @@ type XTZFronter interface {
 	GetTransactionsByBlocks(ctx context.Context, req *GetTransactionsByBlocksByCustomerReq) ([]*model.Transaction, uint64, uint64, error)
 	GetTransactionsByDates(ctx context.Context, req *GetTransactionsByDatesByCustomerReq) ([]*model.Transaction, uint64, uint64, error)
 	GetTransactionsByAttributes(ctx context.Context, req *GetTransactionsByAttributesByCustomerReq) ([]*model.Transaction, uint64, error)
+	GetAddressOnboarding(ctx context.Context, req *GetAddressOnboardingReq) ([]*model.AddressOnboarding, error)
+	CreateWebhook(ctx context.Context, req *CreateWebhookReq) (string, error)
+	GetWebhooks(ctx context.Context, req *GetWebhooksReq) ([]*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, req *DeleteWebhookReq) error
+	GetWebhookDeliveries(ctx context.Context, req *GetWebhookDeliveriesReq) ([]*model.WebhookDelivery, uint64, error)
+	GetConfirmationPolicy(ctx context.Context, req *GetConfirmationPolicyReq) (*model.ConfirmationPolicy, error)
+	SetConfirmationPolicy(ctx context.Context, req *SetConfirmationPolicyReq) error
+	GetBalanceSeries(ctx context.Context, req *GetBalanceSeriesReq) ([]*model.BalanceSnapshot, error)
+	GetBlockAtTime(ctx context.Context, req *GetBlockAtTimeReq) (*common_model.Block, error)
+	ReplaceBroadcast(ctx context.Context, req *ReplaceBroadcastReq) (string, error)
+	GetBroadcastReplacements(ctx context.Context, req *GetBroadcastReplacementsReq) (*model.BroadcastReplacements, error)
+	GetBroadcastQueues(ctx context.Context, req *GetBroadcastQueuesReq) ([]*model.BroadcastQueue, error)
+	GetOperationGroup(ctx context.Context, req *GetOperationGroupReq) (*model.OperationGroup, error)
+	GetBroadcastHistory(ctx context.Context, req *GetBroadcastHistoryReq) (*model.BroadcastHistory, error)
+	CancelBroadcast(ctx context.Context, req *CancelBroadcastReq) (*model.BroadcastCancellation, error)
 }
 
 // XTZFrontService is the tezos service handler.
@@ -123,7 +276,11 @@ func (s *XTZFrontService) Broadcast(ctx context.Context, req *BroadcastByCustome
 
 	return s.xtzService.Broadcast(ctx, &BroadcastReq{
 		Network:        req.Network,
+		CustomerID:     req.CustomerID,
 		RawTransaction: req.RawTransaction,
+		NotBeforeBlock: req.NotBeforeBlock,
+		NotBefore:      req.NotBefore,
+		AfterHash:      req.AfterHash,
 	})
 }
 
@@ -144,9 +301,16 @@ func (s *XTZFrontService) GetCounters(ctx context.Context, req *GetCountersReq)
 }
 
 func (s *XTZFrontService) GetTransactionsByHashes(ctx context.Context, req *GetTransactionsByHashesByCustomerReq) ([]*model.Transaction, uint64, error) {
+	policy, err := s.xtzService.GetConfirmationPolicy(ctx, &GetConfirmationPolicyReq{Network: req.Network, CustomerID: req.CustomerID})
+	if err != nil {
+		return nil, 0, err
+	}
+
 	transactions, height, err := s.xtzService.GetTransactionsByHashes(ctx, &GetTransactionsByHashesReq{
-		Network: req.Network,
-		Hashes:  req.Hashes,
+		Network:       req.Network,
+		Hashes:        req.Hashes,
+		Policy:        policy,
+		ConfirmedOnly: req.ConfirmedOnly,
 	})
 	if err != nil {
 		return nil, 0, err
@@ -171,13 +335,20 @@ func (s *XTZFrontService) GetTransactionsByHashes(ctx context.Context, req *GetT
 }
 
 func (s *XTZFrontService) GetTransactionsByBlocks(ctx context.Context, req *GetTransactionsByBlocksByCustomerReq) ([]*model.Transaction, uint64, uint64, error) {
+	policy, err := s.xtzService.GetConfirmationPolicy(ctx, &GetConfirmationPolicyReq{Network: req.Network, CustomerID: req.CustomerID})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
 	transactions, totalItems, height, err := s.xtzService.GetTransactionsByBlocks(ctx, &GetTransactionsByBlocksReq{
-		Network:   req.Network,
-		Addresses: req.Addresses,
-		FromBlock: req.FromBlock,
-		ToBlock:   req.ToBlock,
-		Limit:     req.Limit,
-		Offset:    req.Offset,
+		Network:       req.Network,
+		Addresses:     req.Addresses,
+		FromBlock:     req.FromBlock,
+		ToBlock:       req.ToBlock,
+		Limit:         req.Limit,
+		Offset:        req.Offset,
+		Policy:        policy,
+		ConfirmedOnly: req.ConfirmedOnly,
 	})
 	if err != nil {
 		return nil, 0, 0, err
@@ -207,13 +378,20 @@ func (s *XTZFrontService) GetTransactionsByBlocks(ctx context.Context, req *GetT
 }
 
 func (s *XTZFrontService) GetTransactionsByDates(ctx context.Context, req *GetTransactionsByDatesByCustomerReq) ([]*model.Transaction, uint64, uint64, error) {
+	policy, err := s.xtzService.GetConfirmationPolicy(ctx, &GetConfirmationPolicyReq{Network: req.Network, CustomerID: req.CustomerID})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
 	transactions, totalItems, height, err := s.xtzService.GetTransactionsByDates(ctx, &GetTransactionsByDatesReq{
-		Network:   req.Network,
-		Addresses: req.Addresses,
-		FromDate:  req.FromDate,
-		ToDate:    req.ToDate,
-		Limit:     req.Limit,
-		Offset:    req.Offset,
+		Network:       req.Network,
+		Addresses:     req.Addresses,
+		FromDate:      req.FromDate,
+		ToDate:        req.ToDate,
+		Limit:         req.Limit,
+		Offset:        req.Offset,
+		Policy:        policy,
+		ConfirmedOnly: req.ConfirmedOnly,
 	})
 	if err != nil {
 		return nil, 0, 0, err
@@ -258,8 +436,69 @@ func (s *XTZFrontService) GetTransactionsByAttributes(ctx context.Context, req *
 	}
 
 	return s.GetTransactionsByHashes(ctx, &GetTransactionsByHashesByCustomerReq{
-		Network:    req.Network,
-		CustomerID: req.CustomerID,
-		Hashes:     hashes,
+		Network:       req.Network,
+		CustomerID:    req.CustomerID,
+		Hashes:        hashes,
+		ConfirmedOnly: req.ConfirmedOnly,
 	})
 }
+
+func (s *XTZFrontService) GetAddressOnboarding(ctx context.Context, req *GetAddressOnboardingReq) ([]*model.AddressOnboarding, error) {
+	return s.xtzService.GetAddressOnboarding(ctx, req)
+}
+
+func (s *XTZFrontService) CreateWebhook(ctx context.Context, req *CreateWebhookReq) (string, error) {
+	return s.xtzService.CreateWebhook(ctx, req)
+}
+
+func (s *XTZFrontService) GetWebhooks(ctx context.Context, req *GetWebhooksReq) ([]*model.Webhook, error) {
+	return s.xtzService.GetWebhooks(ctx, req)
+}
+
+func (s *XTZFrontService) DeleteWebhook(ctx context.Context, req *DeleteWebhookReq) error {
+	return s.xtzService.DeleteWebhook(ctx, req)
+}
+
+func (s *XTZFrontService) GetWebhookDeliveries(ctx context.Context, req *GetWebhookDeliveriesReq) ([]*model.WebhookDelivery, uint64, error) {
+	return s.xtzService.GetWebhookDeliveries(ctx, req)
+}
+
+func (s *XTZFrontService) GetConfirmationPolicy(ctx context.Context, req *GetConfirmationPolicyReq) (*model.ConfirmationPolicy, error) {
+	return s.xtzService.GetConfirmationPolicy(ctx, req)
+}
+
+func (s *XTZFrontService) SetConfirmationPolicy(ctx context.Context, req *SetConfirmationPolicyReq) error {
+	return s.xtzService.SetConfirmationPolicy(ctx, req)
+}
+
+func (s *XTZFrontService) GetBalanceSeries(ctx context.Context, req *GetBalanceSeriesReq) ([]*model.BalanceSnapshot, error) {
+	return s.xtzService.GetBalanceSeries(ctx, req)
+}
+
+func (s *XTZFrontService) GetBlockAtTime(ctx context.Context, req *GetBlockAtTimeReq) (*common_model.Block, error) {
+	return s.xtzService.GetBlockAtTime(ctx, req)
+}
+
+func (s *XTZFrontService) ReplaceBroadcast(ctx context.Context, req *ReplaceBroadcastReq) (string, error) {
+	return s.xtzService.ReplaceBroadcast(ctx, req)
+}
+
+func (s *XTZFrontService) GetBroadcastReplacements(ctx context.Context, req *GetBroadcastReplacementsReq) (*model.BroadcastReplacements, error) {
+	return s.xtzService.GetBroadcastReplacements(ctx, req)
+}
+
+func (s *XTZFrontService) GetBroadcastQueues(ctx context.Context, req *GetBroadcastQueuesReq) ([]*model.BroadcastQueue, error) {
+	return s.xtzService.GetBroadcastQueues(ctx, req)
+}
+
+func (s *XTZFrontService) GetOperationGroup(ctx context.Context, req *GetOperationGroupReq) (*model.OperationGroup, error) {
+	return s.xtzService.GetOperationGroup(ctx, req)
+}
+
+func (s *XTZFrontService) GetBroadcastHistory(ctx context.Context, req *GetBroadcastHistoryReq) (*model.BroadcastHistory, error) {
+	return s.xtzService.GetBroadcastHistory(ctx, req)
+}
+
+func (s *XTZFrontService) CancelBroadcast(ctx context.Context, req *CancelBroadcastReq) (*model.BroadcastCancellation, error) {
+	return s.xtzService.CancelBroadcast(ctx, req)
+}
@@ -2,43 +2,111 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/t-dx/tg-blocksd/internal/logger"
 	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	common_service "github.com/t-dx/tg-blocksd/pkg/common/service"
+	"github.com/t-dx/tg-blocksd/pkg/common/store/cockroach"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
+	"github.com/t-dx/tg-blocksd/pkg/xtz/webhook"
 
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
+// onboardingBackfillShards is the number of shards of the backfill scanning the history of onboarded addresses.
+const onboardingBackfillShards = 4
+
+// defaultRequiredConfirmations is the number of confirmations required when neither the customer nor the default
+// confirmation policy is set.
+const defaultRequiredConfirmations = 1
+
+// BroadcastReq broadcasts a raw transaction. It is broadcasted once the head reaches NotBeforeBlock and NotBefore is
+// past if set, and once the transaction AfterHash, or a replacement of it, is included successfully if set.
+// A schedule past the expiry of the operation is rejected. AfterHash is a broadcast of the customer, the transaction
+// fails if it fails and is not replaced.
 type BroadcastReq struct {
 	Network        string
 	CustomerID     string
 	RawTransaction string
+	NotBeforeBlock uint64
+	NotBefore      time.Time
+	AfterHash      string
 }
 
+// GetTransactionsByHashesReq gets transactions by hash. If Policy is set, the Confirmed state of the transactions
+// is computed under it, and only the confirmed ones are returned if ConfirmedOnly is set.
 type GetTransactionsByHashesReq struct {
-	Network string
-	Hashes  []string
+	Network       string
+	Hashes        []string
+	Policy        *model.ConfirmationPolicy
+	ConfirmedOnly bool
 }
 
+// GetTransactionsByBlocksReq gets transactions between blocks, see GetTransactionsByHashesReq for Policy and ConfirmedOnly.
 type GetTransactionsByBlocksReq struct {
+	Network       string
+	Addresses     []string
+	FromBlock     uint64
+	ToBlock       uint64
+	Limit         uint64
+	Offset        uint64
+	Policy        *model.ConfirmationPolicy
+	ConfirmedOnly bool
+}
+
+// GetTransactionsByDatesReq gets transactions between dates, see GetTransactionsByHashesReq for Policy and ConfirmedOnly.
+type GetTransactionsByDatesReq struct {
+	Network       string
+	Addresses     []string
+	FromDate      time.Time
+	ToDate        time.Time
+	Limit         uint64
+	Offset        uint64
+	Policy        *model.ConfirmationPolicy
+	ConfirmedOnly bool
+}
+
+type GetIndexerHaltReq struct {
+	Network string
+}
+
+type ResolveIndexerHaltReq struct {
+	Network   string
+	ID        string
+	Decision  string
+	DecidedBy string
+}
+
+type CreateBackfillReq struct {
 	Network   string
-	Addresses []string
 	FromBlock uint64
 	ToBlock   uint64
-	Limit     uint64
-	Offset    uint64
+	Shards    uint64
 }
 
-type GetTransactionsByDatesReq struct {
-	Network   string
-	Addresses []string
-	FromDate  time.Time
-	ToDate    time.Time
-	Limit     uint64
-	Offset    uint64
+type GetBackfillReq struct {
+	Network string
+	ID      string
+}
+
+type GetIntegrityFindingsReq struct {
+	Network  string
+	FromDate time.Time
+	ToDate   time.Time
+	Limit    uint64
+	Offset   uint64
+}
+
+type GetBalanceDiscrepanciesReq struct {
+	Network  string
+	FromDate time.Time
+	ToDate   time.Time
+	Limit    uint64
+	Offset   uint64
 }
 
 // XTZer defines the tezos service API.
@@ -53,6 +121,27 @@ type XTZer interface {
 	GetTransactionsByBlocks(ctx context.Context, req *GetTransactionsByBlocksReq) ([]*model.Transaction, uint64, uint64, error)
 	GetTransactionsByDates(ctx context.Context, req *GetTransactionsByDatesReq) ([]*model.Transaction, uint64, uint64, error)
 	GetRawTransactionHash(ctx context.Context, rawTransaction string) (string, error)
+	GetIndexerHalt(ctx context.Context, req *GetIndexerHaltReq) (*model.IndexerHalt, error)
+	ResolveIndexerHalt(ctx context.Context, req *ResolveIndexerHaltReq) error
+	CreateBackfill(ctx context.Context, req *CreateBackfillReq) (string, error)
+	GetBackfill(ctx context.Context, req *GetBackfillReq) (*model.Backfill, error)
+	GetAddressOnboarding(ctx context.Context, req *GetAddressOnboardingReq) ([]*model.AddressOnboarding, error)
+	GetIntegrityFindings(ctx context.Context, req *GetIntegrityFindingsReq) ([]*model.IntegrityFinding, uint64, error)
+	CreateWebhook(ctx context.Context, req *CreateWebhookReq) (string, error)
+	GetWebhooks(ctx context.Context, req *GetWebhooksReq) ([]*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, req *DeleteWebhookReq) error
+	GetWebhookDeliveries(ctx context.Context, req *GetWebhookDeliveriesReq) ([]*model.WebhookDelivery, uint64, error)
+	GetConfirmationPolicy(ctx context.Context, req *GetConfirmationPolicyReq) (*model.ConfirmationPolicy, error)
+	SetConfirmationPolicy(ctx context.Context, req *SetConfirmationPolicyReq) error
+	GetBalanceDiscrepancies(ctx context.Context, req *GetBalanceDiscrepanciesReq) ([]*model.BalanceDiscrepancy, uint64, error)
+	GetBalanceSeries(ctx context.Context, req *GetBalanceSeriesReq) ([]*model.BalanceSnapshot, error)
+	GetBlockAtTime(ctx context.Context, req *GetBlockAtTimeReq) (*common_model.Block, error)
+	ReplaceBroadcast(ctx context.Context, req *ReplaceBroadcastReq) (string, error)
+	GetBroadcastReplacements(ctx context.Context, req *GetBroadcastReplacementsReq) (*model.BroadcastReplacements, error)
+	GetBroadcastQueues(ctx context.Context, req *GetBroadcastQueuesReq) ([]*model.BroadcastQueue, error)
+	GetOperationGroup(ctx context.Context, req *GetOperationGroupReq) (*model.OperationGroup, error)
+	GetBroadcastHistory(ctx context.Context, req *GetBroadcastHistoryReq) (*model.BroadcastHistory, error)
+	CancelBroadcast(ctx context.Context, req *CancelBroadcastReq) (*model.BroadcastCancellation, error)
 }
 
 type Client interface {
@@ -60,27 +149,92 @@ type Client interface {
 	GetEstimatedFee(ctx context.Context) (*model.Fees, error)
 	GetBalances(ctx context.Context, addresses []string, blockNumber uint64) ([]*model.Balance, error)
 	GetBlock(ctx context.Context, blockNumber uint64) (*common_model.Block, error)
+	GetBlockWithTransactions(ctx context.Context, blockNumber uint64) (*common_model.Block, []*model.Transaction, time.Duration, error)
 	GetHeight(ctx context.Context) (*model.Height, error)
 	GetCounters(ctx context.Context, addresses []string) ([]*model.Counter, error)
 	GetRawTransactionHash(ctx context.Context, rawTransaction string) (string, error)
+	GetOperationExpiry(ctx context.Context, rawTransaction string) (*model.OperationExpiry, error)
+	GetManagerOperation(ctx context.Context, rawTransaction string) (*model.ManagerOperation, error)
 	GetTransactions(ctx context.Context, blockNumber uint64) ([]*model.Transaction, error)
 }
 
 type TransactionStore interface {
 	CreateTransactions(ctx context.Context, transactions []*model.Transaction) error
+	CommitBlock(ctx context.Context, block *common_model.Block, transactions []*model.Transaction, txCount uint64) (time.Duration, error)
+	RepairBlock(ctx context.Context, block *common_model.Block, transactions []*model.Transaction, txCount uint64) (time.Duration, error)
 	GetTransactions(ctx context.Context, hashes []string) ([]*model.Transaction, error)
-	GetTransactionsBetweenBlocks(ctx context.Context, addresses []string, fromBlock, toBlock uint64, limit, offset uint64) ([]*model.Transaction, uint64, error)
-	GetTransactionsBetweenDates(ctx context.Context, addresses []string, fromDate, toDate time.Time, limit, offset uint64) ([]*model.Transaction, uint64, error)
+	GetTransactionsBetweenBlocks(ctx context.Context, addresses []string, fromBlock, toBlock uint64, limit, offset uint64, confirmed *model.ConfirmedFilter) ([]*model.Transaction, uint64, error)
+	GetTransactionsBetweenDates(ctx context.Context, addresses []string, fromDate, toDate time.Time, limit, offset uint64, confirmed *model.ConfirmedFilter) ([]*model.Transaction, uint64, error)
 	MarkPinned(ctx context.Context, addresses []string) error
 	Broadcast(ctx context.Context, transaction *model.Transaction) error
-	GetPendingBroadcasts(ctx context.Context, broadcastedBeforeBlock, limit uint64) ([]*model.Transaction, error)
-	UpdateBroadcast(ctx context.Context, hash string, status string, message string, broadcastedAtBlock uint64) error
+	GetPendingBroadcasts(ctx context.Context, blockNumber, maxAttempts, limit uint64) ([]*model.Transaction, error)
+	UpdateBroadcast(ctx context.Context, hash string, status string, message string, broadcastedAtBlock, nextAttemptBlock uint64) error
 	GetBroadcastsToGarbageCollect(ctx context.Context, beforeBlock uint64) ([]string, error)
+	GetExpiredBroadcasts(ctx context.Context, lastBlock uint64) ([]string, error)
+	GetBroadcast(ctx context.Context, hash string) (*model.Transaction, error)
+	ReplaceBroadcast(ctx context.Context, replacedHash string, transaction *model.Transaction) error
+	GetBroadcastQueues(ctx context.Context, customerID, source string) ([]*model.BroadcastQueue, error)
+	GetOperationGroup(ctx context.Context, hash string) ([]*model.Transaction, error)
+	InsertBroadcastHistory(ctx context.Context, entries []*model.BroadcastHistoryEntry) error
+	GetBroadcastHistory(ctx context.Context, hash string) ([]*model.BroadcastHistoryEntry, error)
+	CancelBroadcast(ctx context.Context, hash, cancelledBy, reason string) error
+	GetDependentBroadcasts(ctx context.Context, hash string) ([]string, error)
+	FailDependentBroadcasts(ctx context.Context) ([]*model.Transaction, error)
 	GarbageCollectBroadcasts(ctx context.Context, broadcastHashes []string) error
 	GarbageCollectTransactions(ctx context.Context, beforeBlock uint64) error
 	DumpPendingBroadcasts(ctx context.Context, limit, offset uint64, asOfSystemTime time.Time) ([]*model.Transaction, uint64, error)
 	DumpPinnedTransactions(ctx context.Context, limit, offset uint64, asOfSystemTime time.Time) ([]*model.Transaction, uint64, error)
 	DeleteBlockTransactions(ctx context.Context, blockNumber uint64) error
+	GetBlocksTransactionHashes(ctx context.Context, blockNumbers []uint64) ([]string, error)
+	RollbackBlocks(ctx context.Context, reorg *model.Reorg) error
+	GetReorgs(ctx context.Context, fromDate, toDate time.Time, limit, offset uint64) ([]*model.Reorg, uint64, error)
+	CreateIndexerHalt(ctx context.Context, halt *model.IndexerHalt) (string, error)
+	GetIndexerHalt(ctx context.Context) (*model.IndexerHalt, error)
+	DecideIndexerHalt(ctx context.Context, id, decision, decidedBy string) error
+	CloseIndexerHalt(ctx context.Context, id string) error
+	CreateBackfill(ctx context.Context, backfill *model.Backfill) (string, error)
+	GetBackfill(ctx context.Context, id string) (*model.Backfill, error)
+	GetPendingBackfills(ctx context.Context) ([]*model.Backfill, error)
+	CommitBackfillBlock(ctx context.Context, backfillID string, shard, blockNumber uint64, transactions []*model.Transaction) error
+	CompleteBackfill(ctx context.Context, id string) error
+	CreateAddressOnboardings(ctx context.Context, onboardings []*model.AddressOnboarding) error
+	GetAddressOnboardings(ctx context.Context, addresses []string) ([]*model.AddressOnboarding, error)
+	GetAddressesCreatedSince(ctx context.Context, since time.Time) ([]string, time.Time, error)
+	GetBroadcastedHashes(ctx context.Context, hashes []string) ([]string, error)
+	GetStoredBlocks(ctx context.Context, fromBlock, toBlock uint64) ([]*model.StoredBlock, error)
+	CreateIntegrityFindings(ctx context.Context, findings []*model.IntegrityFinding) error
+	GetIntegrityFindings(ctx context.Context, fromDate, toDate time.Time, limit, offset uint64) ([]*model.IntegrityFinding, uint64, error)
+	GetIndexerProgress(ctx context.Context, name string) (uint64, error)
+	SetIndexerProgress(ctx context.Context, name string, blockNumber uint64) error
+	CreateWebhook(ctx context.Context, webhook *model.Webhook) (string, error)
+	GetWebhooks(ctx context.Context, customerID string) ([]*model.Webhook, error)
+	GetActiveWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, customerID, id string) error
+	CreateWebhookDeliveries(ctx context.Context, deliveries []*model.WebhookDelivery) (uint64, error)
+	GetDueWebhookDeliveries(ctx context.Context, limit uint64) ([]*model.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	GetWebhookDeliveries(ctx context.Context, customerID, webhookID string, limit, offset uint64) ([]*model.WebhookDelivery, uint64, error)
+	GetWebhookTransactionHashes(ctx context.Context, webhookID, eventType string, hashes []string) ([]string, error)
+	GetCustomerBroadcasts(ctx context.Context, customerID string, since time.Time, sinceHash string, limit uint64) ([]*model.Transaction, error)
+	GetOutboxEvents(ctx context.Context, limit uint64) ([]*model.OutboxEvent, error)
+	MarkOutboxPublished(ctx context.Context, ids []uint64) error
+	DeletePublishedOutboxEvents(ctx context.Context, before time.Time) error
+	GetConfirmationPolicy(ctx context.Context, customerID string) (*model.ConfirmationPolicy, error)
+	SetConfirmationPolicy(ctx context.Context, policy *model.ConfirmationPolicy) error
+	AssignAddressChunks(ctx context.Context, numChunks uint64) error
+	LeaseChunk(ctx context.Context, lockID string, lease time.Duration, processedBefore time.Time) (uint64, bool, error)
+	ReleaseChunk(ctx context.Context, chunkID uint64, lockID string, processed bool) error
+	RenewChunk(ctx context.Context, chunkID uint64, lockID string, lease time.Duration) (bool, error)
+	GetChunkAddresses(ctx context.Context, chunkID uint64) ([]string, error)
+	CreateBalanceBaselines(ctx context.Context, baselines []*model.BalanceBaseline) error
+	GetIndexedBalances(ctx context.Context, addresses []string, blockNumber uint64) (map[string]*big.Int, error)
+	CreateBalanceDiscrepancies(ctx context.Context, discrepancies []*model.BalanceDiscrepancy) error
+	GetBalanceDiscrepancies(ctx context.Context, fromDate, toDate time.Time, limit, offset uint64) ([]*model.BalanceDiscrepancy, uint64, error)
+	GetAddresses(ctx context.Context, afterAddress string, limit uint64) ([]string, error)
+	GetDayEndBlocks(ctx context.Context, fromBlock, toBlock uint64) ([]*common_model.Block, error)
+	CreateBalanceSnapshots(ctx context.Context, snapshots []*model.BalanceSnapshot) error
+	GetBalanceSnapshots(ctx context.Context, address, boundary string, fromDate, toDate time.Time) ([]*model.BalanceSnapshot, error)
+	GetBlockAtTime(ctx context.Context, date time.Time) (*common_model.Block, error)
 }
 
 // XTZService is the tezos service handler.
@@ -111,38 +265,582 @@ func NewXTZService(addressStore common_service.AddressStore, blockStore common_s
 }
 
 func (s *XTZService) AddAddresses(ctx context.Context, req *AddAddressesReq) error {
-	return s.addressStore.CreateAddresses(ctx, req.Addresses)
+	err := s.addressStore.CreateAddresses(ctx, req.Addresses)
+	if err != nil {
+		return err
+	}
+
+	if req.SinceBlock == 0 && req.SinceDate.IsZero() {
+		return nil
+	}
+	return s.onboardAddresses(ctx, req)
+}
+
+// onboardAddresses rebuilds the history of the addresses since the requested block or date.
+// The retained transactions are pinned right away, and the blocks up to the last indexed one are scanned
+// again from the node by a backfill restricted to the addresses. Later blocks are indexed by the block fetcher.
+func (s *XTZService) onboardAddresses(ctx context.Context, req *AddAddressesReq) error {
+	var sinceBlock = req.SinceBlock
+	if !req.SinceDate.IsZero() {
+		blockNumber, err := s.getFirstBlockNumberAfter(ctx, req.SinceDate, true)
+		if err != nil {
+			return err
+		}
+		sinceBlock = blockNumber
+	}
+
+	err := s.transactionStore.MarkPinned(ctx, req.Addresses)
+	if err != nil {
+		return err
+	}
+
+	var (
+		toBlock    = sinceBlock
+		backfillID *string
+	)
+	block, err := s.blockStore.GetLastBlock(ctx)
+	switch {
+	case err == cockroach.ErrNoBlock:
+		// Nothing is indexed yet, the block fetcher will index the history.
+	case err != nil:
+		return err
+	case sinceBlock <= block.Number:
+		toBlock = block.Number
+		id, err := s.transactionStore.CreateBackfill(ctx, newBackfill(sinceBlock, toBlock, onboardingBackfillShards, req.Addresses))
+		if err != nil {
+			return err
+		}
+		backfillID = &id
+	}
+
+	var onboardings []*model.AddressOnboarding
+	for _, address := range req.Addresses {
+		onboardings = append(onboardings, &model.AddressOnboarding{Address: address, SinceBlock: sinceBlock, ToBlock: toBlock, BackfillID: backfillID})
+	}
+	return s.transactionStore.CreateAddressOnboardings(ctx, onboardings)
+}
+
+// getFirstBlockNumberAfter returns the number of the first block produced after the given date, or at or after it if
+// inclusive is set, by binary search on the node. It returns the number of the next block if no block is produced yet.
+func (s *XTZService) getFirstBlockNumberAfter(ctx context.Context, date time.Time, inclusive bool) (uint64, error) {
+	height, err := s.client.GetHeight(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var low, high = uint64(0), height.Height + 1
+	for low < high {
+		mid := low + (high-low)/2
+		block, err := s.client.GetBlock(ctx, mid)
+		if err != nil {
+			return 0, err
+		}
+		if block.Timestamp == nil {
+			return 0, errors.Errorf("block %d has no timestamp", mid)
+		}
+
+		if block.Timestamp.After(date) || (inclusive && block.Timestamp.Equal(date)) {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low, nil
 }
 
 func (s *XTZService) Broadcast(ctx context.Context, req *BroadcastReq) (string, error) {
-	hash, err := s.client.GetRawTransactionHash(ctx, req.RawTransaction)
+	transaction, expiry, err := s.newBroadcast(ctx, req.RawTransaction, req.CustomerID)
+	if err != nil {
+		return "", err
+	}
+
+	// A raw transaction is public once in a mempool, another customer resubmitting it does not take the broadcast over.
+	existing, err := s.transactionStore.GetBroadcast(ctx, transaction.Hash)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil && existing.CustomerID != nil && *existing.CustomerID != req.CustomerID {
+		return "", errors.Errorf("operation %q is already broadcasted by another customer", transaction.Hash)
+	}
+
+	if req.NotBeforeBlock > 0 {
+		// Broadcasted at NotBeforeBlock, it is included in the next block at best.
+		if transaction.ExpiryBlock != nil && req.NotBeforeBlock >= *transaction.ExpiryBlock {
+			return "", errors.Errorf("operation expires at level %d, it cannot be broadcasted from level %d", *transaction.ExpiryBlock, req.NotBeforeBlock)
+		}
+		transaction.NotBeforeBlock = &req.NotBeforeBlock
+	}
+	if !req.NotBefore.IsZero() {
+		notBefore := req.NotBefore.UTC()
+		if expiry != nil && !notBefore.Before(expiry.ExpiryTime) {
+			return "", errors.Errorf("operation expires at level %d, around %s, it cannot be broadcasted from %s", expiry.ExpiryLevel, expiry.ExpiryTime.Format(time.RFC3339), notBefore.Format(time.RFC3339))
+		}
+		transaction.NotBefore = &notBefore
+	}
+	if req.AfterHash != "" {
+		if req.AfterHash == transaction.Hash {
+			return "", errors.New("operation cannot be broadcasted after itself")
+		}
+		err = s.checkPredecessor(ctx, req.AfterHash, req.CustomerID)
+		if err != nil {
+			return "", err
+		}
+		transaction.AfterHash = &req.AfterHash
+	}
+
+	err = s.transactionStore.Broadcast(ctx, transaction)
 	if err != nil {
 		return "", err
 	}
 
+	InsertBroadcastTrail(ctx, s.broadcastTrailsStore, s.transactionStore, &model.BroadcastHistoryEntry{Hash: transaction.Hash, Action: "store", Status: common_model.NEW.String(), BlockNumber: *transaction.CreatedAtBlockNumber})
+
+	return transaction.Hash, nil
+}
+
+// checkPredecessor checks that a broadcast can be broadcasted after the broadcast hash of the customer: the predecessor
+// is tracked, and neither failed nor is to be failed.
+func (s *XTZService) checkPredecessor(ctx context.Context, hash, customerID string) error {
+	predecessor, err := s.transactionStore.GetBroadcast(ctx, hash)
+	if err != nil {
+		return err
+	}
+	switch {
+	case predecessor == nil || predecessor.CustomerID == nil || *predecessor.CustomerID != customerID:
+		return errors.Errorf("predecessor broadcast %q not found", hash)
+	case predecessor.BlockNumber != nil && common_model.ToStatus(predecessor.Status) != common_model.SUCCESS:
+		return errors.Errorf("predecessor broadcast %q failed in block %d", hash, *predecessor.BlockNumber)
+	case predecessor.BlockNumber == nil && !isPendingStatus(predecessor.Status):
+		return errors.Errorf("predecessor broadcast %q is %s, it will not be included", hash, predecessor.Status)
+	case predecessor.BlockNumber == nil && predecessor.ReplacedBy != nil:
+		return errors.Errorf("predecessor broadcast %q is replaced by %q", hash, *predecessor.ReplacedBy)
+	}
+	return nil
+}
+
+// maxReplacements bounds the number of replacements of a broadcast.
+const maxReplacements = 20
+
+// ReplaceBroadcast broadcasts an operation replacing a pending broadcast of the customer, with the same source and
+// counter and a higher fee. The replaced broadcast is not broadcasted anymore, but it can still land.
+func (s *XTZService) ReplaceBroadcast(ctx context.Context, req *ReplaceBroadcastReq) (string, error) {
+	replaced, err := s.transactionStore.GetBroadcast(ctx, req.Hash)
+	if err != nil {
+		return "", err
+	}
+	if replaced == nil || replaced.CustomerID == nil || *replaced.CustomerID != req.CustomerID {
+		return "", errors.Errorf("broadcast %q not found", req.Hash)
+	}
+
+	switch {
+	case replaced.BlockNumber != nil:
+		return "", errors.Errorf("broadcast %q is already included in block %d", req.Hash, *replaced.BlockNumber)
+	case !isPendingStatus(replaced.Status):
+		return "", errors.Errorf("broadcast %q is %s, it cannot be replaced", req.Hash, replaced.Status)
+	case replaced.ReplacedBy != nil:
+		return "", errors.Errorf("broadcast %q is already replaced by %q", req.Hash, *replaced.ReplacedBy)
+	case replaced.RawTransaction == nil:
+		return "", errors.Errorf("broadcast %q has no raw transaction", req.Hash)
+	}
+
+	replacements, err := s.getBroadcastReplacements(ctx, replaced)
+	if err != nil {
+		return "", err
+	}
+	if len(replacements) > maxReplacements {
+		return "", errors.Errorf("broadcast %q cannot be replaced more than %d times", req.Hash, maxReplacements)
+	}
+
+	previous, err := s.client.GetManagerOperation(ctx, *replaced.RawTransaction)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not decode broadcast %q", req.Hash)
+	}
+	operation, err := s.client.GetManagerOperation(ctx, req.RawTransaction)
+	if err != nil {
+		return "", errors.Wrap(err, "could not decode raw transaction")
+	}
+	switch {
+	case operation.Source != previous.Source:
+		return "", errors.Errorf("source %q should be the one of the replaced broadcast, %q", operation.Source, previous.Source)
+	case operation.Counter.Cmp(previous.Counter) != 0:
+		return "", errors.Errorf("counter %s should be the one of the replaced broadcast, %s", operation.Counter, previous.Counter)
+	case operation.Fee.Cmp(previous.Fee) <= 0:
+		return "", errors.Errorf("fee %s should be higher than the one of the replaced broadcast, %s", operation.Fee, previous.Fee)
+	}
+
+	transaction, _, err := s.newBroadcast(ctx, req.RawTransaction, req.CustomerID)
+	if err != nil {
+		return "", err
+	}
+	// The replacement is scheduled and conditioned as the replaced broadcast.
+	transaction.NotBeforeBlock, transaction.NotBefore, transaction.AfterHash = replaced.NotBeforeBlock, replaced.NotBefore, replaced.AfterHash
+	existing, err := s.transactionStore.GetBroadcast(ctx, transaction.Hash)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return "", errors.Errorf("raw transaction is already broadcasted as %q", transaction.Hash)
+	}
+
+	err = s.transactionStore.ReplaceBroadcast(ctx, req.Hash, transaction)
+	if err != nil {
+		return "", err
+	}
+
+	InsertBroadcastTrail(ctx, s.broadcastTrailsStore, s.transactionStore, &model.BroadcastHistoryEntry{Hash: transaction.Hash, Action: "replace", Status: common_model.NEW.String(), BlockNumber: *transaction.CreatedAtBlockNumber})
+
+	return transaction.Hash, nil
+}
+
+// GetBroadcastReplacements returns the broadcasts of an operation replaced by fee, and the one that landed if any.
+func (s *XTZService) GetBroadcastReplacements(ctx context.Context, req *GetBroadcastReplacementsReq) (*model.BroadcastReplacements, error) {
+	broadcast, err := s.transactionStore.GetBroadcast(ctx, req.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if broadcast == nil || broadcast.CustomerID == nil || *broadcast.CustomerID != req.CustomerID {
+		return nil, errors.Errorf("broadcast %q not found", req.Hash)
+	}
+
+	transactions, err := s.getBroadcastReplacements(ctx, broadcast)
+	if err != nil {
+		return nil, err
+	}
+
+	var replacements = &model.BroadcastReplacements{Transactions: transactions}
+	for _, tx := range transactions {
+		if tx.BlockNumber != nil {
+			replacements.Landed = tx
+		}
+	}
+	return replacements, nil
+}
+
+// getBroadcastReplacements follows the links of a broadcast, and returns the broadcasts of its operation from the
+// first one to the last replacement.
+func (s *XTZService) getBroadcastReplacements(ctx context.Context, broadcast *model.Transaction) ([]*model.Transaction, error) {
+	var transactions = []*model.Transaction{broadcast}
+	for first := broadcast; first.Replaces != nil && len(transactions) <= maxReplacements; {
+		previous, err := s.transactionStore.GetBroadcast(ctx, *first.Replaces)
+		if err != nil {
+			return nil, err
+		}
+		if previous == nil {
+			break
+		}
+		transactions = append([]*model.Transaction{previous}, transactions...)
+		first = previous
+	}
+	for last := broadcast; last.ReplacedBy != nil && len(transactions) <= maxReplacements; {
+		next, err := s.transactionStore.GetBroadcast(ctx, *last.ReplacedBy)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			break
+		}
+		transactions = append(transactions, next)
+		last = next
+	}
+	return transactions, nil
+}
+
+// GetBroadcastQueues returns the queues of the pending broadcasts of the customer by source address.
+func (s *XTZService) GetBroadcastQueues(ctx context.Context, req *GetBroadcastQueuesReq) ([]*model.BroadcastQueue, error) {
+	return s.transactionStore.GetBroadcastQueues(ctx, req.CustomerID, req.Source)
+}
+
+// GetOperationGroup returns the status of a broadcasted operation of the customer and of each of its contents.
+func (s *XTZService) GetOperationGroup(ctx context.Context, req *GetOperationGroupReq) (*model.OperationGroup, error) {
+	transactions, err := s.transactionStore.GetOperationGroup(ctx, req.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 || transactions[0].Index != 0 || !transactions[0].Broadcasted ||
+		transactions[0].CustomerID == nil || *transactions[0].CustomerID != req.CustomerID {
+		return nil, errors.Errorf("broadcast %q not found", req.Hash)
+	}
+
+	return toOperationGroup(transactions), nil
+}
+
+// toOperationGroup returns the group of the rows of an operation, the first one being the broadcast.
+func toOperationGroup(transactions []*model.Transaction) *model.OperationGroup {
+	broadcast := transactions[0]
+	group := &model.OperationGroup{
+		Hash:        broadcast.Hash,
+		Status:      broadcast.Status,
+		Message:     broadcast.Message,
+		BlockNumber: broadcast.BlockNumber,
+		Contents:    []*model.Transaction{},
+	}
+	if broadcast.BlockNumber == nil {
+		return group
+	}
+
+	// The status of an included group is derived from its contents, without them it stays the one of the broadcast.
+	var status, message = common_model.SUCCESS.String(), (*string)(nil)
+	for _, tx := range transactions {
+		if tx.BlockNumber == nil {
+			continue
+		}
+		tx.CustomerID = nil
+		group.Contents = append(group.Contents, tx)
+		if common_model.ToStatus(tx.Status) != common_model.SUCCESS && message == nil {
+			m := fmt.Sprintf("content %d failed", tx.Index)
+			if tx.Message != nil {
+				m = fmt.Sprintf("content %d: %s", tx.Index, *tx.Message)
+			}
+			status, message = common_model.FAILURE.String(), &m
+		}
+	}
+	if len(group.Contents) > 0 {
+		group.Status, group.Message = status, message
+	}
+	return group
+}
+
+// newBroadcast returns the broadcast of a raw transaction, timed out once it cannot be included anymore, and the
+// expiry of the operation, nil if it is unknown.
+// A manager operation is queued behind the pending broadcasts of its source with a lower counter, the others are
+// broadcasted independently.
+func (s *XTZService) newBroadcast(ctx context.Context, rawTransaction, customer string) (*model.Transaction, *model.OperationExpiry, error) {
+	hash, err := s.client.GetRawTransactionHash(ctx, rawTransaction)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Without its expiry, a broadcast is not timed out when its branch expires, it is left to the garbage collection.
+	var branch *string
+	var expiryBlock *uint64
+	expiry, err := s.client.GetOperationExpiry(ctx, rawTransaction)
+	if err != nil {
+		logger.TechLog.Warn(ctx, "could not get operation expiry, broadcast will not be timed out on expiry", zap.String("hash", hash), zap.Error(err))
+		expiry = nil
+	} else {
+		branch, expiryBlock = &expiry.Branch, &expiry.ExpiryLevel
+	}
+
 	var blockNumber = s.startBlock
 	block, err := s.blockStore.GetLastBlock(ctx)
 	if err == nil {
 		blockNumber = block.Number
 	}
 
-	err = s.transactionStore.Broadcast(ctx, &model.Transaction{Hash: hash, RawTransaction: &req.RawTransaction, Timestamp: &time.Time{}, CreatedAtBlockNumber: &blockNumber})
-	if err != nil {
-		return "", err
+	var customerID *string
+	if customer != "" {
+		customerID = &customer
+	}
+
+	var source *string
+	var counter *uint64
+	operation, err := s.client.GetManagerOperation(ctx, rawTransaction)
+	switch {
+	case err != nil:
+		logger.TechLog.Info(ctx, "broadcast is not queued, it is not a manager operation", zap.String("hash", hash), zap.Error(err))
+	case !operation.Counter.IsUint64():
+		logger.TechLog.Info(ctx, "broadcast is not queued, its counter is out of range", zap.String("hash", hash), zap.String("counter", operation.Counter.String()))
+	default:
+		c := operation.Counter.Uint64()
+		source, counter = &operation.Source, &c
 	}
 
-	err = s.broadcastTrailsStore.InsertBroadcastTrails(ctx, []*common_model.BroadcastTrail{{
+	return &model.Transaction{
+		Hash:                 hash,
+		RawTransaction:       &rawTransaction,
+		Timestamp:            &time.Time{},
+		CreatedAtBlockNumber: &blockNumber,
+		CustomerID:           customerID,
+		Branch:               branch,
+		ExpiryBlock:          expiryBlock,
+		BroadcastSource:      source,
+		BroadcastCounter:     counter,
+	}, expiry, nil
+}
+
+// InsertBroadcastTrail records an action on a broadcast in the trails, and in the broadcast history with the error
+// returned by the node and the level at which it happened. A failure is only logged.
+func InsertBroadcastTrail(ctx context.Context, trailsStore common_service.BroadcastTrailsStore, transactionStore TransactionStore, entry *model.BroadcastHistoryEntry) {
+	entry.CreatedAt = time.Now().UTC()
+	err := trailsStore.InsertBroadcastTrails(ctx, []*common_model.BroadcastTrail{{
 		Currency:        "XTZ",
-		Action:          "store",
-		TransactionHash: hash,
-		BroadcastStatus: common_model.NEW.String(),
-		Date:            time.Now().UTC(),
+		Action:          entry.Action,
+		TransactionHash: entry.Hash,
+		BroadcastStatus: entry.Status,
+		Date:            entry.CreatedAt,
 	}})
 	if err != nil {
-		logger.TechLog.Error(ctx, "unable to insert trail", zap.Error(err), zap.String("currency", "XTZ"), zap.String("action", "broadcast"), zap.String("transaction_hash", hash), zap.String("status", common_model.NEW.String()))
+		logger.TechLog.Error(ctx, "unable to insert trail", zap.Error(err), zap.String("currency", "XTZ"), zap.String("action", entry.Action), zap.String("transaction_hash", entry.Hash), zap.String("status", entry.Status))
+	}
+
+	err = transactionStore.InsertBroadcastHistory(ctx, []*model.BroadcastHistoryEntry{entry})
+	if err != nil {
+		logger.TechLog.Error(ctx, "unable to insert broadcast history", zap.Error(err), zap.String("action", entry.Action), zap.String("transaction_hash", entry.Hash))
+	}
+}
+
+// CancelBroadcast cancels a pending broadcast of the customer, so that it is never broadcasted again, with the pending
+// broadcasts that cannot be included without it. A broadcast already sent to a node can still be included until its
+// expiry, the cancellation warns about it then.
+func (s *XTZService) CancelBroadcast(ctx context.Context, req *CancelBroadcastReq) (*model.BroadcastCancellation, error) {
+	broadcast, err := s.transactionStore.GetBroadcast(ctx, req.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if broadcast == nil || broadcast.CustomerID == nil || *broadcast.CustomerID != req.CustomerID {
+		return nil, errors.Errorf("broadcast %q not found", req.Hash)
+	}
+
+	switch {
+	case broadcast.BlockNumber != nil:
+		return nil, errors.Errorf("broadcast %q is already included in block %d", req.Hash, *broadcast.BlockNumber)
+	case !isPendingStatus(broadcast.Status):
+		return nil, errors.Errorf("broadcast %q is %s, it cannot be cancelled", req.Hash, broadcast.Status)
+	case broadcast.ReplacedBy != nil:
+		return nil, errors.Errorf("broadcast %q is replaced, its replacement %q should be cancelled", req.Hash, *broadcast.ReplacedBy)
+	}
+
+	err = s.transactionStore.CancelBroadcast(ctx, req.Hash, req.CancelledBy, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled, err := s.transactionStore.GetBroadcast(ctx, req.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if cancelled == nil || cancelled.CancelledAt == nil {
+		return nil, errors.Errorf("broadcast %q could not be cancelled, it changed meanwhile", req.Hash)
+	}
+
+	var blockNumber = s.startBlock
+	block, err := s.blockStore.GetLastBlock(ctx)
+	if err == nil {
+		blockNumber = block.Number
+	}
+	InsertBroadcastTrail(ctx, s.broadcastTrailsStore, s.transactionStore, &model.BroadcastHistoryEntry{Hash: req.Hash, Action: "cancel", Status: cancelled.Status, Message: cancelled.Message, BlockNumber: blockNumber})
+
+	dependents, err := s.cancelDependents(ctx, req.Hash, req.CancelledBy, blockNumber)
+	if err != nil {
+		logger.TechLog.Error(ctx, "could not cancel the dependents of a cancelled broadcast", zap.String("hash", req.Hash), zap.Error(err))
+	}
+
+	cancellation := &model.BroadcastCancellation{
+		Hash:        cancelled.Hash,
+		Status:      cancelled.Status,
+		CancelledBy: req.CancelledBy,
+		Reason:      req.Reason,
+		CancelledAt: *cancelled.CancelledAt,
+		ExpiryBlock: cancelled.ExpiryBlock,
+		Dependents:  dependents,
 	}
 
-	return hash, nil
+	// The operation was sent to a node unless it was never attempted.
+	if cancelled.BroadcastAttempts == 0 {
+		return cancellation, nil
+	}
+	height, err := s.client.GetHeight(ctx)
+	if err != nil {
+		logger.TechLog.Warn(ctx, "could not get height, the cancelled broadcast is considered includable", zap.String("hash", req.Hash), zap.Error(err))
+	}
+	if err != nil || cancelled.ExpiryBlock == nil || *cancelled.ExpiryBlock > height.Height {
+		warning := "the operation was sent to a node, it may still be in a mempool and be included"
+		if cancelled.ExpiryBlock != nil {
+			warning = fmt.Sprintf("%s up to block %d", warning, *cancelled.ExpiryBlock)
+		}
+		cancellation.MayBeIncluded, cancellation.Warning = true, &warning
+	}
+	return cancellation, nil
+}
+
+// cancelDependents cancels the pending broadcasts that cannot be included without the cancelled one, and the ones
+// depending on them in turn. It returns the cancelled ones.
+func (s *XTZService) cancelDependents(ctx context.Context, hash, cancelledBy string, blockNumber uint64) ([]string, error) {
+	var (
+		cancelled = []string{}
+		reason    = fmt.Sprintf("predecessor %s cancelled", hash)
+		message   = fmt.Sprintf("cancelled by %s: %s", cancelledBy, reason)
+	)
+	for queue := []string{hash}; len(queue) > 0; queue = queue[1:] {
+		dependents, err := s.transactionStore.GetDependentBroadcasts(ctx, queue[0])
+		if err != nil {
+			return cancelled, err
+		}
+		for _, dependent := range dependents {
+			err = s.transactionStore.CancelBroadcast(ctx, dependent, cancelledBy, reason)
+			if err != nil {
+				return cancelled, err
+			}
+			InsertBroadcastTrail(ctx, s.broadcastTrailsStore, s.transactionStore, &model.BroadcastHistoryEntry{Hash: dependent, Action: "cancel", Status: model.StatusCancelled, Message: &message, BlockNumber: blockNumber})
+			cancelled = append(cancelled, dependent)
+			queue = append(queue, dependent)
+		}
+	}
+	return cancelled, nil
+}
+
+// isPendingStatus reports whether a broadcast with the given status is still to be broadcasted.
+func isPendingStatus(status string) bool {
+	if status == model.StatusCancelled {
+		return false
+	}
+	st := common_model.ToStatus(status)
+	return st == common_model.NEW || st == common_model.PENDING || st == common_model.FAILURE
+}
+
+// GetBroadcastHistory returns what happened to a broadcast of the customer: its storage, each attempt with the error
+// returned by the node and the level at which it happened, its expiry, and the block it is included in.
+func (s *XTZService) GetBroadcastHistory(ctx context.Context, req *GetBroadcastHistoryReq) (*model.BroadcastHistory, error) {
+	broadcast, err := s.transactionStore.GetBroadcast(ctx, req.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if broadcast == nil || broadcast.CustomerID == nil || *broadcast.CustomerID != req.CustomerID {
+		return nil, errors.Errorf("broadcast %q not found", req.Hash)
+	}
+
+	entries, err := s.transactionStore.GetBroadcastHistory(ctx, req.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		entries = rebuildBroadcastHistory(broadcast)
+	}
+
+	return &model.BroadcastHistory{
+		Hash:        broadcast.Hash,
+		Status:      broadcast.Status,
+		Message:     broadcast.Message,
+		BlockNumber: broadcast.BlockNumber,
+		Entries:     entries,
+	}, nil
+}
+
+// rebuildBroadcastHistory returns the history of a broadcast stored before its history was recorded, from what the
+// broadcast keeps: its storage, and its last attempt with the error returned by the node.
+func rebuildBroadcastHistory(broadcast *model.Transaction) []*model.BroadcastHistoryEntry {
+	var entries = []*model.BroadcastHistoryEntry{}
+	if broadcast.CreatedAtBlockNumber != nil {
+		entry := &model.BroadcastHistoryEntry{Hash: broadcast.Hash, Action: "store", Status: common_model.NEW.String(), BlockNumber: *broadcast.CreatedAtBlockNumber}
+		if broadcast.CreatedAt != nil {
+			entry.CreatedAt = *broadcast.CreatedAt
+		}
+		entries = append(entries, entry)
+	}
+	if broadcast.BroadcastAttempts > 0 && broadcast.BroadcastedAtBlock != nil {
+		entry := &model.BroadcastHistoryEntry{Hash: broadcast.Hash, Action: "broadcast", Status: common_model.PENDING.String(), BlockNumber: *broadcast.BroadcastedAtBlock}
+		if broadcast.Message != nil {
+			entry.Status, entry.Message = common_model.FAILURE.String(), broadcast.Message
+			if common_model.ToStatus(broadcast.Status) == common_model.INVALID {
+				entry.Status = broadcast.Status
+			}
+		}
+		if broadcast.StatusUpdatedAt != nil {
+			entry.CreatedAt = *broadcast.StatusUpdatedAt
+		}
+		entries = append(entries, entry)
+	}
+	return entries
 }
 
 func (s *XTZService) GetBlockchainInfo(ctx context.Context, req *GetBlockchainInfoReq) (*model.BlockchainInfo, error) {
@@ -161,8 +859,54 @@ func (s *XTZService) GetEstimatedFee(ctx context.Context, req *GetEstimatedFeeRe
 	return s.client.GetEstimatedFee(ctx)
 }
 
+// GetBalances returns the balances of the addresses at the tip, and at BlockNumber or at the last block produced
+// at or before AtDate if set.
 func (s *XTZService) GetBalances(ctx context.Context, req *GetBalancesReq) ([]*model.Balance, error) {
-	return s.client.GetBalances(ctx, req.Addresses, req.BlockNumber)
+	var blockNumber = req.BlockNumber
+	if !req.AtDate.IsZero() {
+		block, err := s.getBlockAtTime(ctx, req.AtDate)
+		if err != nil {
+			return nil, err
+		}
+		blockNumber = block.Number
+	}
+
+	balances, err := s.client.GetBalances(ctx, req.Addresses, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, balance := range balances {
+		balance.BlockNumber = blockNumber
+	}
+	return balances, nil
+}
+
+// GetBlockAtTime returns the last block produced at or before the given date.
+func (s *XTZService) GetBlockAtTime(ctx context.Context, req *GetBlockAtTimeReq) (*common_model.Block, error) {
+	return s.getBlockAtTime(ctx, req.Date)
+}
+
+// getBlockAtTime returns the last block produced at or before the given date, from the stored blocks if they tell it,
+// from the node otherwise.
+func (s *XTZService) getBlockAtTime(ctx context.Context, date time.Time) (*common_model.Block, error) {
+	block, err := s.transactionStore.GetBlockAtTime(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	if block != nil {
+		return block, nil
+	}
+
+	// The last block produced at or before the date precedes the first one produced after it.
+	blockNumber, err := s.getFirstBlockNumberAfter(ctx, date, false)
+	if err != nil {
+		return nil, err
+	}
+	if blockNumber == 0 {
+		return nil, errors.Errorf("no block produced at or before %s", date.UTC().Format(time.RFC3339))
+	}
+	return s.client.GetBlock(ctx, blockNumber-1)
 }
 
 func (s *XTZService) GetCounters(ctx context.Context, req *GetCountersReq) ([]*model.Counter, error) {
@@ -170,6 +914,10 @@ func (s *XTZService) GetCounters(ctx context.Context, req *GetCountersReq) ([]*m
 }
 
 func (s *XTZService) GetTransactionsByHashes(ctx context.Context, req *GetTransactionsByHashesReq) ([]*model.Transaction, uint64, error) {
+	if req.ConfirmedOnly && req.Policy == nil {
+		return nil, 0, errors.New("confirmed only requires a confirmation policy")
+	}
+
 	transactions, err := s.transactionStore.GetTransactions(ctx, req.Hashes)
 	if err != nil {
 		return nil, 0, err
@@ -180,6 +928,17 @@ func (s *XTZService) GetTransactionsByHashes(ctx context.Context, req *GetTransa
 		return nil, 0, err
 	}
 
+	setConfirmations(transactions, height.Height, req.Policy)
+	if req.ConfirmedOnly {
+		confirmed := []*model.Transaction{}
+		for _, transaction := range transactions {
+			if transaction.Confirmed {
+				confirmed = append(confirmed, transaction)
+			}
+		}
+		transactions = confirmed
+	}
+
 	return transactions, height.Height, nil
 }
 
@@ -189,28 +948,223 @@ func (s *XTZService) GetTransactionsByBlocks(ctx context.Context, req *GetTransa
 		return nil, 0, 0, err
 	}
 
-	transactions, totalItems, err := s.transactionStore.GetTransactionsBetweenBlocks(ctx, req.Addresses, req.FromBlock, req.ToBlock, req.Limit, req.Offset)
+	confirmed, err := confirmedFilter(req.Policy, req.ConfirmedOnly, height.Height)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	transactions, totalItems, err := s.transactionStore.GetTransactionsBetweenBlocks(ctx, req.Addresses, req.FromBlock, req.ToBlock, req.Limit, req.Offset, confirmed)
 	if err != nil {
 		return nil, 0, 0, err
 	}
 
+	setConfirmations(transactions, height.Height, req.Policy)
 	return transactions, totalItems, height.Height, nil
 }
 
 func (s *XTZService) GetTransactionsByDates(ctx context.Context, req *GetTransactionsByDatesReq) ([]*model.Transaction, uint64, uint64, error) {
-	transactions, totalItems, err := s.transactionStore.GetTransactionsBetweenDates(ctx, req.Addresses, req.FromDate, req.ToDate, req.Limit, req.Offset)
+	height, err := s.client.GetHeight(ctx)
 	if err != nil {
 		return nil, 0, 0, err
 	}
 
-	height, err := s.client.GetHeight(ctx)
+	confirmed, err := confirmedFilter(req.Policy, req.ConfirmedOnly, height.Height)
 	if err != nil {
 		return nil, 0, 0, err
 	}
 
+	transactions, totalItems, err := s.transactionStore.GetTransactionsBetweenDates(ctx, req.Addresses, req.FromDate, req.ToDate, req.Limit, req.Offset, confirmed)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	setConfirmations(transactions, height.Height, req.Policy)
 	return transactions, totalItems, height.Height, nil
 }
 
+// confirmedFilter returns the filter of the transactions confirmed under the policy, or nil if confirmedOnly is not set.
+func confirmedFilter(policy *model.ConfirmationPolicy, confirmedOnly bool, height uint64) (*model.ConfirmedFilter, error) {
+	if !confirmedOnly {
+		return nil, nil
+	}
+	if policy == nil {
+		return nil, errors.New("confirmed only requires a confirmation policy")
+	}
+	return &model.ConfirmedFilter{Policy: policy, Height: height}, nil
+}
+
+// setConfirmations sets the confirmations of the transactions when the chain is at height, and their Confirmed state
+// under the policy if it is set. The transactions of the last block have one confirmation.
+func setConfirmations(transactions []*model.Transaction, height uint64, policy *model.ConfirmationPolicy) {
+	for _, transaction := range transactions {
+		transaction.Confirmations = 0
+		if transaction.BlockNumber != nil && *transaction.BlockNumber <= height {
+			transaction.Confirmations = height - *transaction.BlockNumber + 1
+		}
+
+		transaction.Confirmed = policy != nil && transaction.Status == common_model.SUCCESS.String() &&
+			transaction.Confirmations > 0 && transaction.Confirmations >= policy.RequiredConfirmations(transaction.Amount)
+	}
+}
+
 func (s *XTZService) GetRawTransactionHash(ctx context.Context, rawTransaction string) (string, error) {
 	return s.client.GetRawTransactionHash(ctx, rawTransaction)
 }
+
+// GetIndexerHalt returns the current halt of the block fetcher, or nil if it is running.
+func (s *XTZService) GetIndexerHalt(ctx context.Context, req *GetIndexerHaltReq) (*model.IndexerHalt, error) {
+	return s.transactionStore.GetIndexerHalt(ctx)
+}
+
+// ResolveIndexerHalt records the operator decision on a halt of the block fetcher, which applies it on its next run.
+func (s *XTZService) ResolveIndexerHalt(ctx context.Context, req *ResolveIndexerHaltReq) error {
+	switch req.Decision {
+	case model.HaltDecisionAccept, model.HaltDecisionSwitchNode:
+	default:
+		return errors.Errorf("unknown halt decision %q", req.Decision)
+	}
+
+	return s.transactionStore.DecideIndexerHalt(ctx, req.ID, req.Decision, req.DecidedBy)
+}
+
+// CreateBackfill schedules the indexing of the [FromBlock, ToBlock] range, split in shards of consecutive blocks.
+// The backfill job picks it up on its next run.
+func (s *XTZService) CreateBackfill(ctx context.Context, req *CreateBackfillReq) (string, error) {
+	if req.FromBlock > req.ToBlock {
+		return "", errors.Errorf("from block %d is after to block %d", req.FromBlock, req.ToBlock)
+	}
+
+	return s.transactionStore.CreateBackfill(ctx, newBackfill(req.FromBlock, req.ToBlock, req.Shards, nil))
+}
+
+// newBackfill splits the [fromBlock, toBlock] range in at most numShards shards of consecutive blocks.
+func newBackfill(fromBlock, toBlock, numShards uint64, addresses []string) *model.Backfill {
+	numBlocks := toBlock - fromBlock + 1
+	switch {
+	case numShards == 0:
+		numShards = 1
+	case numShards > numBlocks:
+		numShards = numBlocks
+	}
+	shardSize := (numBlocks + numShards - 1) / numShards
+
+	backfill := &model.Backfill{FromBlock: fromBlock, ToBlock: toBlock, Addresses: addresses}
+	for from := fromBlock; from <= toBlock; from += shardSize {
+		to := from + shardSize - 1
+		if to > toBlock {
+			to = toBlock
+		}
+		backfill.Shards = append(backfill.Shards, &model.BackfillShard{Shard: uint64(len(backfill.Shards)), FromBlock: from, ToBlock: to})
+	}
+	return backfill
+}
+
+// GetAddressOnboarding returns the progress of the history scan of the given addresses.
+func (s *XTZService) GetAddressOnboarding(ctx context.Context, req *GetAddressOnboardingReq) ([]*model.AddressOnboarding, error) {
+	return s.transactionStore.GetAddressOnboardings(ctx, req.Addresses)
+}
+
+// GetBackfill returns a backfill with the progress of its shards.
+func (s *XTZService) GetBackfill(ctx context.Context, req *GetBackfillReq) (*model.Backfill, error) {
+	return s.transactionStore.GetBackfill(ctx, req.ID)
+}
+
+// GetIntegrityFindings returns the findings of the integrity verifier between the given dates, most recent first.
+func (s *XTZService) GetIntegrityFindings(ctx context.Context, req *GetIntegrityFindingsReq) ([]*model.IntegrityFinding, uint64, error) {
+	return s.transactionStore.GetIntegrityFindings(ctx, req.FromDate, req.ToDate, req.Limit, req.Offset)
+}
+
+// GetBalanceDiscrepancies returns the discrepancies found by the balance reconciliation between the given dates,
+// most recent first.
+func (s *XTZService) GetBalanceDiscrepancies(ctx context.Context, req *GetBalanceDiscrepanciesReq) ([]*model.BalanceDiscrepancy, uint64, error) {
+	return s.transactionStore.GetBalanceDiscrepancies(ctx, req.FromDate, req.ToDate, req.Limit, req.Offset)
+}
+
+// GetBalanceSeries returns the balance snapshots of an address between the given dates, by increasing block.
+// The snapshots whose balance could not be fetched have no balance and an error.
+func (s *XTZService) GetBalanceSeries(ctx context.Context, req *GetBalanceSeriesReq) ([]*model.BalanceSnapshot, error) {
+	return s.transactionStore.GetBalanceSnapshots(ctx, req.Address, req.Boundary, req.FromDate, req.ToDate)
+}
+
+// CreateWebhook registers a webhook of the customer, notified of the events from the next indexed block.
+func (s *XTZService) CreateWebhook(ctx context.Context, req *CreateWebhookReq) (string, error) {
+	if err := webhook.CheckURL(req.URL); err != nil {
+		return "", err
+	}
+
+	var fromBlock = s.startBlock
+	block, err := s.blockStore.GetLastBlock(ctx)
+	switch {
+	case err == cockroach.ErrNoBlock:
+	case err != nil:
+		return "", err
+	default:
+		fromBlock = block.Number + 1
+	}
+
+	return s.transactionStore.CreateWebhook(ctx, &model.Webhook{
+		CustomerID:    req.CustomerID,
+		URL:           req.URL,
+		Secret:        req.Secret,
+		Addresses:     req.Addresses,
+		Confirmations: req.Confirmations,
+		FromBlock:     fromBlock,
+	})
+}
+
+// GetWebhooks returns the active webhooks of the customer, without their secret.
+func (s *XTZService) GetWebhooks(ctx context.Context, req *GetWebhooksReq) ([]*model.Webhook, error) {
+	webhooks, err := s.transactionStore.GetWebhooks(ctx, req.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook disables a webhook of the customer. Its pending deliveries are not attempted anymore.
+func (s *XTZService) DeleteWebhook(ctx context.Context, req *DeleteWebhookReq) error {
+	return s.transactionStore.DeleteWebhook(ctx, req.CustomerID, req.ID)
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook of the customer, most recent first.
+func (s *XTZService) GetWebhookDeliveries(ctx context.Context, req *GetWebhookDeliveriesReq) ([]*model.WebhookDelivery, uint64, error) {
+	return s.transactionStore.GetWebhookDeliveries(ctx, req.CustomerID, req.WebhookID, req.Limit, req.Offset)
+}
+
+// GetConfirmationPolicy returns the confirmation policy of the customer, or the default one if the customer has none.
+// If there is no default policy either, defaultRequiredConfirmations are required for all amounts.
+func (s *XTZService) GetConfirmationPolicy(ctx context.Context, req *GetConfirmationPolicyReq) (*model.ConfirmationPolicy, error) {
+	for _, customerID := range []string{req.CustomerID, ""} {
+		policy, err := s.transactionStore.GetConfirmationPolicy(ctx, customerID)
+		if err != nil {
+			return nil, err
+		}
+		if policy != nil {
+			return policy, nil
+		}
+	}
+
+	return &model.ConfirmationPolicy{Confirmations: defaultRequiredConfirmations, Thresholds: []*model.ConfirmationThreshold{}}, nil
+}
+
+// SetConfirmationPolicy creates or replaces the confirmation policy of the customer. The thresholds must have
+// strictly increasing amounts.
+func (s *XTZService) SetConfirmationPolicy(ctx context.Context, req *SetConfirmationPolicyReq) error {
+	policy := &model.ConfirmationPolicy{CustomerID: req.CustomerID, Confirmations: req.Confirmations, Thresholds: []*model.ConfirmationThreshold{}}
+	for i, threshold := range req.Thresholds {
+		minAmount, ok := new(big.Int).SetString(threshold.MinAmount, 10)
+		if !ok || minAmount.Sign() <= 0 {
+			return errors.Errorf("invalid threshold amount %q", threshold.MinAmount)
+		}
+		if i > 0 && minAmount.Cmp(policy.Thresholds[i-1].MinAmount) <= 0 {
+			return errors.Errorf("threshold amount %q should be greater than the previous one", threshold.MinAmount)
+		}
+		policy.Thresholds = append(policy.Thresholds, &model.ConfirmationThreshold{MinAmount: minAmount, Confirmations: threshold.Confirmations})
+	}
+
+	return s.transactionStore.SetConfirmationPolicy(ctx, policy)
+}
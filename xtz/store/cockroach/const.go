@@ -10,6 +10,48 @@ const (
 	// XTZChunkTableName is the name of the database table where XTZ blocks are stored.
 	XTZChunkTableName = "xtz_chunk"
 
+	// XTZAddressOnboardingTableName is the name of the database table where the onboarding of the XTZ addresses is tracked.
+	XTZAddressOnboardingTableName = "xtz_address_onboarding"
+
+	// XTZBackfillTableName is the name of the database table where the XTZ backfills are stored.
+	XTZBackfillTableName = "xtz_backfill"
+
+	// XTZBackfillShardTableName is the name of the database table where the progress of the XTZ backfill shards is checkpointed.
+	XTZBackfillShardTableName = "xtz_backfill_shard"
+
+	// XTZIndexerProgressTableName is the name of the database table where the XTZ jobs checkpoint their progress.
+	XTZIndexerProgressTableName = "xtz_indexer_progress"
+
+	// XTZIntegrityFindingTableName is the name of the database table where the findings of the XTZ integrity verifier are stored.
+	XTZIntegrityFindingTableName = "xtz_integrity_finding"
+
+	// XTZBalanceDiscrepancyTableName is the name of the database table where the discrepancies found by the XTZ balance reconciliation are stored.
+	XTZBalanceDiscrepancyTableName = "xtz_balance_discrepancy"
+
+	// XTZBalanceBaselineTableName is the name of the database table where the XTZ balance reconciliation records the opening balances of the addresses.
+	XTZBalanceBaselineTableName = "xtz_balance_baseline"
+
+	// XTZBalanceSnapshotTableName is the name of the database table where the XTZ balance snapshots of the addresses are stored.
+	XTZBalanceSnapshotTableName = "xtz_balance_snapshot"
+
+	// XTZConfirmationPolicyTableName is the name of the database table where the XTZ confirmation policies of the customers are stored.
+	XTZConfirmationPolicyTableName = "xtz_confirmation_policy"
+
+	// XTZIndexerHaltTableName is the name of the database table where the halts of the XTZ indexer are stored.
+	XTZIndexerHaltTableName = "xtz_indexer_halt"
+
+	// XTZOutboxTableName is the name of the database table where the changes of the XTZ transactions are written for the relay.
+	XTZOutboxTableName = "xtz_outbox"
+
+	// XTZReorgTableName is the name of the database table where XTZ reorgs are journaled.
+	XTZReorgTableName = "xtz_reorg"
+
+	// XTZWebhookTableName is the name of the database table where the XTZ webhooks of the customers are stored.
+	XTZWebhookTableName = "xtz_webhook"
+
+	// XTZWebhookDeliveryTableName is the name of the database table where the deliveries of the XTZ webhooks are logged.
+	XTZWebhookDeliveryTableName = "xtz_webhook_delivery"
+
 	// XTZTransactionAttributeTableName is the name of the database table where XTZ blocks are stored.
 	XTZTransactionAttributeTableName = "xtz_tx_attributes"
 )
@@ -1,17 +1,42 @@
 package cockroach
 
 import (
+	"math/big"
 	"time"
 
 	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	"github.com/t-dx/tg-blocksd/pkg/helper"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
 )
 
+// statusCancelled is the stored status of the broadcasts cancelled, model.StatusCancelled. The common statuses have
+// no such one, it is stored out of their range.
+const statusCancelled common_model.Status = 100
+
+// fromStatus returns the name of a stored status.
+func fromStatus(st common_model.Status) string {
+	if st == statusCancelled {
+		return model.StatusCancelled
+	}
+	return common_model.FromStatus(st)
+}
+
+// toStatus returns the stored status of a status name.
+func toStatus(status string) common_model.Status {
+	if status == model.StatusCancelled {
+		return statusCancelled
+	}
+	return common_model.ToStatus(status)
+}
+
 type transaction struct {
 	ID                   string              `db:"id"`
 	Hash                 string              `db:"hash"`
 	Index                uint64              `db:"idx"`
+	Kind                 *string             `db:"kind"`
 	BlockNumber          *int64              `db:"block_number"`
 	SourceAddress        *string             `db:"addr_from"`
 	DestinationAddress   *string             `db:"addr_to"`
@@ -27,6 +52,22 @@ type transaction struct {
 	CreatedAt            *time.Time          `db:"created_at"`
 	CreatedAtBlockNumber *uint64             `db:"created_at_block"`
 	BroadcastedAtBlock   *uint64             `db:"broadcasted_at_block"`
+	CustomerID           *string             `db:"customer_id"`
+	BroadcastAttempts    uint64              `db:"broadcast_attempts"`
+	NextAttemptBlock     uint64              `db:"next_attempt_block"`
+	Branch               *string             `db:"branch"`
+	ExpiryBlock          *uint64             `db:"expiry_block"`
+	Replaces             *string             `db:"replaces"`
+	ReplacedBy           *string             `db:"replaced_by"`
+	BroadcastSource      *string             `db:"broadcast_source"`
+	BroadcastCounter     *uint64             `db:"broadcast_counter"`
+	CancelledBy          *string             `db:"cancelled_by"`
+	CancelReason         *string             `db:"cancel_reason"`
+	CancelledAt          *time.Time          `db:"cancelled_at"`
+	NotBeforeBlock       *uint64             `db:"not_before_block"`
+	NotBefore            *time.Time          `db:"not_before"`
+	AfterHash            *string             `db:"after_hash"`
+	StatusUpdatedAt      *time.Time          `db:"status_updated_at"`
 }
 
 func toModelTransaction(t *transaction) *model.Transaction {
@@ -34,13 +75,14 @@ func toModelTransaction(t *transaction) *model.Transaction {
 		ID:                   t.ID,
 		Hash:                 t.Hash,
 		Index:                t.Index,
+		Kind:                 t.Kind,
 		BlockNumber:          helper.BlockNumberPtrToUint64Ptr(t.BlockNumber),
 		SourceAddress:        t.SourceAddress,
 		DestinationAddress:   t.DestinationAddress,
 		Amount:               helper.StringPtrToBigInt(t.Amount),
 		Fee:                  helper.StringPtrToBigInt(t.Fee),
 		Counter:              helper.StringPtrToBigInt(t.Counter),
-		Status:               common_model.FromStatus(t.Status),
+		Status:               fromStatus(t.Status),
 		RawTransaction:       t.RawTransaction,
 		Pinned:               t.Pinned,
 		Broadcasted:          t.Broadcasted,
@@ -49,6 +91,22 @@ func toModelTransaction(t *transaction) *model.Transaction {
 		CreatedAt:            t.CreatedAt,
 		CreatedAtBlockNumber: t.CreatedAtBlockNumber,
 		BroadcastedAtBlock:   t.BroadcastedAtBlock,
+		CustomerID:           t.CustomerID,
+		BroadcastAttempts:    t.BroadcastAttempts,
+		NextAttemptBlock:     t.NextAttemptBlock,
+		Branch:               t.Branch,
+		ExpiryBlock:          t.ExpiryBlock,
+		Replaces:             t.Replaces,
+		ReplacedBy:           t.ReplacedBy,
+		BroadcastSource:      t.BroadcastSource,
+		BroadcastCounter:     t.BroadcastCounter,
+		CancelledBy:          t.CancelledBy,
+		CancelReason:         t.CancelReason,
+		CancelledAt:          t.CancelledAt,
+		NotBeforeBlock:       t.NotBeforeBlock,
+		NotBefore:            t.NotBefore,
+		AfterHash:            t.AfterHash,
+		StatusUpdatedAt:      t.StatusUpdatedAt,
 	}
 }
 
@@ -59,3 +117,427 @@ func toModelTransactions(legacyTransactions []*transaction) []*model.Transaction
 	}
 	return transactions
 }
+
+type reorg struct {
+	ID              string         `db:"id"`
+	DetectedAtBlock uint64         `db:"detected_at_block"`
+	ForkBlock       uint64         `db:"fork_block"`
+	Depth           uint64         `db:"depth"`
+	OldHashes       pq.StringArray `db:"old_hashes"`
+	NewHashes       pq.StringArray `db:"new_hashes"`
+	TxHashes        pq.StringArray `db:"tx_hashes"`
+	CreatedAt       *time.Time     `db:"created_at"`
+}
+
+func toModelReorgs(storedReorgs []*reorg) []*model.Reorg {
+	var reorgs = []*model.Reorg{}
+	for _, r := range storedReorgs {
+		reorgs = append(reorgs, &model.Reorg{
+			ID:                r.ID,
+			DetectedAtBlock:   r.DetectedAtBlock,
+			ForkBlock:         r.ForkBlock,
+			Depth:             r.Depth,
+			OldHashes:         r.OldHashes,
+			NewHashes:         r.NewHashes,
+			TransactionHashes: r.TxHashes,
+			CreatedAt:         r.CreatedAt,
+		})
+	}
+	return reorgs
+}
+
+type indexerHalt struct {
+	ID          string     `db:"id"`
+	BlockNumber uint64     `db:"block_number"`
+	Depth       uint64     `db:"depth"`
+	MaxDepth    uint64     `db:"max_depth"`
+	StoredHash  *string    `db:"stored_hash"`
+	ChainHash   *string    `db:"chain_hash"`
+	Decision    *string    `db:"decision"`
+	DecidedBy   *string    `db:"decided_by"`
+	DecidedAt   *time.Time `db:"decided_at"`
+	ClosedAt    *time.Time `db:"closed_at"`
+	CreatedAt   *time.Time `db:"created_at"`
+}
+
+func toModelIndexerHalt(h *indexerHalt) *model.IndexerHalt {
+	return &model.IndexerHalt{
+		ID:          h.ID,
+		BlockNumber: h.BlockNumber,
+		Depth:       h.Depth,
+		MaxDepth:    h.MaxDepth,
+		StoredHash:  h.StoredHash,
+		ChainHash:   h.ChainHash,
+		Decision:    h.Decision,
+		DecidedBy:   h.DecidedBy,
+		DecidedAt:   h.DecidedAt,
+		ClosedAt:    h.ClosedAt,
+		CreatedAt:   h.CreatedAt,
+	}
+}
+
+type backfill struct {
+	ID          string         `db:"id"`
+	FromBlock   uint64         `db:"from_block"`
+	ToBlock     uint64         `db:"to_block"`
+	Addresses   pq.StringArray `db:"addresses"`
+	CompletedAt *time.Time     `db:"completed_at"`
+	CreatedAt   *time.Time     `db:"created_at"`
+}
+
+type backfillShard struct {
+	BackfillID  string     `db:"backfill_id"`
+	Shard       uint64     `db:"shard"`
+	FromBlock   uint64     `db:"from_block"`
+	ToBlock     uint64     `db:"to_block"`
+	NextBlock   uint64     `db:"next_block"`
+	CompletedAt *time.Time `db:"completed_at"`
+	UpdatedAt   *time.Time `db:"updated_at"`
+}
+
+func toModelBackfills(storedBackfills []*backfill, storedShards []*backfillShard) []*model.Backfill {
+	var (
+		backfills = []*model.Backfill{}
+		byID      = map[string]*model.Backfill{}
+	)
+	for _, b := range storedBackfills {
+		res := &model.Backfill{
+			ID:          b.ID,
+			FromBlock:   b.FromBlock,
+			ToBlock:     b.ToBlock,
+			Addresses:   b.Addresses,
+			Shards:      []*model.BackfillShard{},
+			CompletedAt: b.CompletedAt,
+			CreatedAt:   b.CreatedAt,
+		}
+		backfills = append(backfills, res)
+		byID[b.ID] = res
+	}
+	for _, s := range storedShards {
+		if b, ok := byID[s.BackfillID]; ok {
+			b.Shards = append(b.Shards, &model.BackfillShard{
+				BackfillID:  s.BackfillID,
+				Shard:       s.Shard,
+				FromBlock:   s.FromBlock,
+				ToBlock:     s.ToBlock,
+				NextBlock:   s.NextBlock,
+				CompletedAt: s.CompletedAt,
+				UpdatedAt:   s.UpdatedAt,
+			})
+		}
+	}
+	return backfills
+}
+
+type addressOnboarding struct {
+	Address             string     `db:"address"`
+	SinceBlock          uint64     `db:"since_block"`
+	ToBlock             uint64     `db:"to_block"`
+	BackfillID          *string    `db:"backfill_id"`
+	BackfillCompletedAt *time.Time `db:"completed_at"`
+	ScannedBlocks       uint64     `db:"scanned_blocks"`
+	CreatedAt           *time.Time `db:"created_at"`
+}
+
+func toModelAddressOnboardings(storedOnboardings []*addressOnboarding) []*model.AddressOnboarding {
+	var onboardings = []*model.AddressOnboarding{}
+	for _, o := range storedOnboardings {
+		// Without backfill, there was no history to scan.
+		status := model.OnboardingPending
+		switch {
+		case o.BackfillID == nil || o.BackfillCompletedAt != nil:
+			status = model.OnboardingCompleted
+		case o.ScannedBlocks > 0:
+			status = model.OnboardingScanning
+		}
+
+		onboardings = append(onboardings, &model.AddressOnboarding{
+			Address:       o.Address,
+			SinceBlock:    o.SinceBlock,
+			ToBlock:       o.ToBlock,
+			BackfillID:    o.BackfillID,
+			ScannedBlocks: o.ScannedBlocks,
+			Status:        status,
+			CreatedAt:     o.CreatedAt,
+		})
+	}
+	return onboardings
+}
+
+type block struct {
+	Number    uint64     `db:"block_number"`
+	Hash      *string    `db:"block_hash"`
+	Timestamp *time.Time `db:"block_timestamp"`
+}
+
+type storedBlock struct {
+	Number        uint64  `db:"block_number"`
+	Hash          *string `db:"block_hash"`
+	TxCount       *uint64 `db:"tx_count"`
+	StoredTxCount uint64  `db:"stored_tx_count"`
+}
+
+func toModelStoredBlocks(storedBlocks []*storedBlock) []*model.StoredBlock {
+	var blocks = []*model.StoredBlock{}
+	for _, b := range storedBlocks {
+		blocks = append(blocks, &model.StoredBlock{Number: b.Number, Hash: b.Hash, TxCount: b.TxCount, StoredTxCount: b.StoredTxCount})
+	}
+	return blocks
+}
+
+type integrityFinding struct {
+	ID          string     `db:"id"`
+	BlockNumber uint64     `db:"block_number"`
+	Kind        string     `db:"kind"`
+	StoredValue *string    `db:"stored_value"`
+	ChainValue  *string    `db:"chain_value"`
+	Repaired    bool       `db:"repaired"`
+	CreatedAt   *time.Time `db:"created_at"`
+}
+
+func toModelIntegrityFindings(storedFindings []*integrityFinding) []*model.IntegrityFinding {
+	var findings = []*model.IntegrityFinding{}
+	for _, f := range storedFindings {
+		findings = append(findings, &model.IntegrityFinding{
+			ID:          f.ID,
+			BlockNumber: f.BlockNumber,
+			Kind:        f.Kind,
+			StoredValue: f.StoredValue,
+			ChainValue:  f.ChainValue,
+			Repaired:    f.Repaired,
+			CreatedAt:   f.CreatedAt,
+		})
+	}
+	return findings
+}
+
+type indexedBalance struct {
+	Address string `db:"address"`
+	Balance string `db:"balance"`
+}
+
+type balanceDiscrepancy struct {
+	ID             string     `db:"id"`
+	Address        string     `db:"address"`
+	ChunkID        uint64     `db:"chunk_id"`
+	BlockNumber    uint64     `db:"block_number"`
+	NodeBalance    string     `db:"node_balance"`
+	IndexedBalance string     `db:"indexed_balance"`
+	CreatedAt      *time.Time `db:"created_at"`
+}
+
+func toModelBalanceDiscrepancies(storedDiscrepancies []*balanceDiscrepancy) []*model.BalanceDiscrepancy {
+	var discrepancies = []*model.BalanceDiscrepancy{}
+	for _, d := range storedDiscrepancies {
+		discrepancies = append(discrepancies, &model.BalanceDiscrepancy{
+			ID:             d.ID,
+			Address:        d.Address,
+			ChunkID:        d.ChunkID,
+			BlockNumber:    d.BlockNumber,
+			NodeBalance:    helper.StringPtrToBigInt(&d.NodeBalance),
+			IndexedBalance: helper.StringPtrToBigInt(&d.IndexedBalance),
+			CreatedAt:      d.CreatedAt,
+		})
+	}
+	return discrepancies
+}
+
+type balanceSnapshot struct {
+	Address        string     `db:"address"`
+	BlockNumber    uint64     `db:"block_number"`
+	Boundary       string     `db:"boundary"`
+	BlockTimestamp *time.Time `db:"block_timestamp"`
+	Balance        *string    `db:"balance"`
+	Error          *string    `db:"error"`
+	CreatedAt      *time.Time `db:"created_at"`
+}
+
+func toModelBalanceSnapshots(storedSnapshots []*balanceSnapshot) []*model.BalanceSnapshot {
+	var snapshots = []*model.BalanceSnapshot{}
+	for _, s := range storedSnapshots {
+		snapshots = append(snapshots, &model.BalanceSnapshot{
+			Address:        s.Address,
+			BlockNumber:    s.BlockNumber,
+			Boundary:       s.Boundary,
+			BlockTimestamp: s.BlockTimestamp,
+			Balance:        helper.StringPtrToBigInt(s.Balance),
+			Error:          s.Error,
+			CreatedAt:      s.CreatedAt,
+		})
+	}
+	return snapshots
+}
+
+type webhook struct {
+	ID            string         `db:"id"`
+	CustomerID    string         `db:"customer_id"`
+	URL           string         `db:"url"`
+	Secret        string         `db:"secret"`
+	Addresses     pq.StringArray `db:"addresses"`
+	Confirmations uint64         `db:"confirmations"`
+	FromBlock     uint64         `db:"from_block"`
+	CreatedAt     *time.Time     `db:"created_at"`
+}
+
+func toModelWebhooks(storedWebhooks []*webhook) []*model.Webhook {
+	var webhooks = []*model.Webhook{}
+	for _, w := range storedWebhooks {
+		webhooks = append(webhooks, &model.Webhook{
+			ID:            w.ID,
+			CustomerID:    w.CustomerID,
+			URL:           w.URL,
+			Secret:        w.Secret,
+			Addresses:     w.Addresses,
+			Confirmations: w.Confirmations,
+			FromBlock:     w.FromBlock,
+			CreatedAt:     w.CreatedAt,
+		})
+	}
+	return webhooks
+}
+
+type webhookDelivery struct {
+	ID             string     `db:"id"`
+	WebhookID      string     `db:"webhook_id"`
+	EventType      string     `db:"event_type"`
+	EventKey       string     `db:"event_key"`
+	TxHash         string     `db:"tx_hash"`
+	Payload        string     `db:"payload"`
+	Status         string     `db:"status"`
+	Attempts       uint64     `db:"attempts"`
+	NextAttemptAt  *time.Time `db:"next_attempt_at"`
+	LastStatusCode *int       `db:"last_status_code"`
+	LastError      *string    `db:"last_error"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+	CreatedAt      *time.Time `db:"created_at"`
+}
+
+func toModelWebhookDeliveries(storedDeliveries []*webhookDelivery) []*model.WebhookDelivery {
+	var deliveries = []*model.WebhookDelivery{}
+	for _, d := range storedDeliveries {
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			ID:              d.ID,
+			WebhookID:       d.WebhookID,
+			EventType:       d.EventType,
+			EventKey:        d.EventKey,
+			TransactionHash: d.TxHash,
+			Payload:         d.Payload,
+			Status:          d.Status,
+			Attempts:        d.Attempts,
+			NextAttemptAt:   d.NextAttemptAt,
+			LastStatusCode:  d.LastStatusCode,
+			LastError:       d.LastError,
+			DeliveredAt:     d.DeliveredAt,
+			CreatedAt:       d.CreatedAt,
+		})
+	}
+	return deliveries
+}
+
+type outboxEvent struct {
+	ID                 uint64               `db:"id"`
+	EventType          string               `db:"event_type"`
+	TxHash             string               `db:"tx_hash"`
+	Index              uint64               `db:"idx"`
+	BlockNumber        *int64               `db:"block_number"`
+	SourceAddress      *string              `db:"addr_from"`
+	DestinationAddress *string              `db:"addr_to"`
+	Amount             *string              `db:"amount"`
+	Fee                *string              `db:"fee"`
+	Status             common_model.Status  `db:"status"`
+	PreviousStatus     *common_model.Status `db:"previous_status"`
+	CreatedAt          *time.Time           `db:"created_at"`
+}
+
+func toModelOutboxEvents(storedEvents []*outboxEvent) []*model.OutboxEvent {
+	var events = []*model.OutboxEvent{}
+	for _, e := range storedEvents {
+		var previousStatus *string
+		if e.PreviousStatus != nil {
+			status := fromStatus(*e.PreviousStatus)
+			previousStatus = &status
+		}
+		events = append(events, &model.OutboxEvent{
+			ID:                 e.ID,
+			Type:               e.EventType,
+			TransactionHash:    e.TxHash,
+			Index:              e.Index,
+			BlockNumber:        helper.BlockNumberPtrToUint64Ptr(e.BlockNumber),
+			SourceAddress:      e.SourceAddress,
+			DestinationAddress: e.DestinationAddress,
+			Amount:             helper.StringPtrToBigInt(e.Amount),
+			Fee:                helper.StringPtrToBigInt(e.Fee),
+			Status:             fromStatus(e.Status),
+			PreviousStatus:     previousStatus,
+			CreatedAt:          e.CreatedAt,
+		})
+	}
+	return events
+}
+
+type confirmationPolicy struct {
+	CustomerID             string         `db:"customer_id"`
+	Confirmations          uint64         `db:"confirmations"`
+	ThresholdAmounts       pq.StringArray `db:"threshold_amounts"`
+	ThresholdConfirmations pq.Int64Array  `db:"threshold_confirmations"`
+	UpdatedAt              *time.Time     `db:"updated_at"`
+}
+
+func toModelConfirmationPolicy(p *confirmationPolicy) (*model.ConfirmationPolicy, error) {
+	if len(p.ThresholdAmounts) != len(p.ThresholdConfirmations) {
+		return nil, errors.Errorf("confirmation policy of %q has %d threshold amounts for %d confirmations", p.CustomerID, len(p.ThresholdAmounts), len(p.ThresholdConfirmations))
+	}
+
+	policy := &model.ConfirmationPolicy{CustomerID: p.CustomerID, Confirmations: p.Confirmations, Thresholds: []*model.ConfirmationThreshold{}, UpdatedAt: p.UpdatedAt}
+	for i, amount := range p.ThresholdAmounts {
+		minAmount, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			return nil, errors.Errorf("invalid threshold amount %q in confirmation policy of %q", amount, p.CustomerID)
+		}
+		policy.Thresholds = append(policy.Thresholds, &model.ConfirmationThreshold{MinAmount: minAmount, Confirmations: uint64(p.ThresholdConfirmations[i])})
+	}
+	return policy, nil
+}
+
+type broadcastQueue struct {
+	Source      string `db:"broadcast_source"`
+	Depth       uint64 `db:"depth"`
+	NextCounter uint64 `db:"next_counter"`
+}
+
+func toModelBroadcastQueues(storedQueues []*broadcastQueue) []*model.BroadcastQueue {
+	var queues = []*model.BroadcastQueue{}
+	for _, q := range storedQueues {
+		queues = append(queues, &model.BroadcastQueue{
+			Source:      q.Source,
+			Depth:       q.Depth,
+			NextCounter: q.NextCounter,
+		})
+	}
+	return queues
+}
+
+type broadcastHistoryEntry struct {
+	ID          uint64              `db:"id"`
+	TxHash      string              `db:"tx_hash"`
+	Action      string              `db:"action"`
+	Status      common_model.Status `db:"status"`
+	Message     *string             `db:"message"`
+	BlockNumber uint64              `db:"block_number"`
+	CreatedAt   time.Time           `db:"created_at"`
+}
+
+func toModelBroadcastHistoryEntries(storedEntries []*broadcastHistoryEntry) []*model.BroadcastHistoryEntry {
+	var entries = []*model.BroadcastHistoryEntry{}
+	for _, e := range storedEntries {
+		entries = append(entries, &model.BroadcastHistoryEntry{
+			Hash:        e.TxHash,
+			Action:      e.Action,
+			Status:      fromStatus(e.Status),
+			Message:     e.Message,
+			BlockNumber: e.BlockNumber,
+			CreatedAt:   e.CreatedAt,
+		})
+	}
+	return entries
+}
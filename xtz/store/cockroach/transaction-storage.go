@@ -3,6 +3,8 @@ package cockroach
 import (
 	"context"
 	"fmt"
+	"math/big"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -13,9 +15,27 @@ import (
 	"github.com/t-dx/tg-blocksd/pkg/helper"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
 
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
+const (
+	// commitBlockBatchSize is the maximum number of transactions inserted by a single statement when committing a block.
+	commitBlockBatchSize = 1000
+
+	// batchMaxAttempts is the number of times a batch of statements is tried before giving up on serialization errors.
+	batchMaxAttempts = 5
+
+	// batchRetryBackoff is the delay before the first retry of a batch, doubled at each subsequent retry.
+	batchRetryBackoff = 50 * time.Millisecond
+
+	// sameOperation matches the broadcast f with the broadcast a when it is a, or a replacement of a or of a broadcast
+	// a replaces: replacements keep the customer, the source and the counter, so only one of them can be included.
+	sameOperation = `(f.hash = a.hash OR (f.customer_id = a.customer_id AND f.broadcast_source = a.broadcast_source AND f.broadcast_counter = a.broadcast_counter AND (f.replaces IS NOT NULL OR a.replaces IS NOT NULL)))`
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 // TransactionStorage is the handler through which a CockroachDB backend can be queried.
 type TransactionStorage struct {
 	db database.DB
@@ -28,46 +48,190 @@ func NewTransactionStorage(db database.DB) *TransactionStorage {
 
 // CreateTransactions saves the provided transactions objects in the database 'xtz_tx' table.
 func (s *TransactionStorage) CreateTransactions(ctx context.Context, transactions []*model.Transaction) error {
-	var begin = `INSERT INTO xtz_tx (hash, idx, block_number, addr_to, addr_from, amount, fee, counter, timestamp, pinned, broadcasted, status, created_at) VALUES `
-	var conflict = `ON CONFLICT(hash, idx) DO UPDATE SET (block_number, addr_to, addr_from, amount, fee, counter, timestamp, status)=(excluded.block_number, excluded.addr_to, excluded.addr_from, excluded.amount, excluded.fee, excluded.counter, excluded.timestamp, excluded.status);`
-
 	if len(transactions) == 0 {
 		return nil
 	}
 
+	query, err := createTransactionsStatement(transactions)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, query)
+	if err != nil {
+		return errors.Wrapf(err, "could not execute sql batch statement")
+	}
+	return nil
+}
+
+func createTransactionsStatement(transactions []*model.Transaction) (string, error) {
+	var begin = `INSERT INTO xtz_tx (hash, idx, kind, block_number, addr_to, addr_from, amount, fee, counter, timestamp, pinned, broadcasted, status, message, created_at) VALUES `
+	var conflict = `ON CONFLICT(hash, idx) DO UPDATE SET (kind, block_number, addr_to, addr_from, amount, fee, counter, timestamp, status, message, pinned, status_updated_at)=(excluded.kind, excluded.block_number, excluded.addr_to, excluded.addr_from, excluded.amount, excluded.fee, excluded.counter, excluded.timestamp, excluded.status, excluded.message, xtz_tx.pinned OR excluded.pinned, IF(xtz_tx.broadcasted AND xtz_tx.status != excluded.status, NOW(), xtz_tx.status_updated_at));`
+
 	now := time.Now()
 
 	var values = ""
 	for _, tx := range transactions {
 		switch {
 		case tx == nil:
-			return errors.New("transaction should not be nil")
-		case tx.Amount == nil:
-			return errors.New("transaction.Amount should not be nil")
+			return "", errors.New("transaction should not be nil")
+		case tx.Amount == nil && tx.Kind == nil:
+			return "", errors.New("transaction.Amount should not be nil")
 		case !helper.IsBase64Alphabet(tx.Hash):
-			return errors.New("Invalid character detected in transaction hash")
+			return "", errors.New("Invalid character detected in transaction hash")
 		}
-		values = values + fmt.Sprintf(`('%s', %d, %s, %s, %s, %s, %s, %s, %s, %s, false, %d, %s),`,
-			tx.Hash, tx.Index, database.Uint64OrNull(tx.BlockNumber), database.StringOrNull(tx.DestinationAddress), database.StringOrNull(tx.SourceAddress),
+		values = values + fmt.Sprintf(`('%s', %d, %s, %s, %s, %s, %s, %s, %s, %s, %s, false, %d, %s, %s),`,
+			tx.Hash, tx.Index, database.StringOrNull(tx.Kind), database.Uint64OrNull(tx.BlockNumber), database.StringOrNull(tx.DestinationAddress), database.StringOrNull(tx.SourceAddress),
 			database.BigIntOrNull(tx.Amount), database.BigIntOrNull(tx.Fee), database.BigIntOrNull(tx.Counter),
-			database.FormattedTimestampOrNull(tx.Timestamp), database.FormattedBool(tx.Pinned), common_model.ToStatus(tx.Status), database.FormattedTimestampOrNull(&now))
+			database.FormattedTimestampOrNull(tx.Timestamp), database.FormattedBool(tx.Pinned), toStatus(tx.Status),
+			database.StringOrNull(tx.Message), database.FormattedTimestampOrNull(&now))
 	}
 	values = values[:len(values)-1]
 
-	var query = begin + values + conflict
-	_, err := s.db.ExecContext(ctx, query)
+	// Only the inserted rows, and the broadcasts whose status changed, are written to the outbox: a block committed
+	// again does not emit its transfers again.
+	condition := fmt.Sprintf(`created_at = %s OR status_updated_at = NOW()`, database.FormattedTimestampOrNull(&now))
+	return outboxStatement(model.EventTransferIndexed, begin+values+conflict, condition), nil
+}
+
+// outboxStatement wraps a statement changing rows of 'xtz_tx', so that the changed rows are also written to
+// the 'xtz_outbox' table with the given event type. Both are written by the same statement, hence in the same transaction.
+// Only the changed transfers are written to the outbox, and if condition is set, only the ones matching it.
+func outboxStatement(eventType, statement, condition string) string {
+	if condition != "" {
+		condition = " AND (" + condition + ")"
+	}
+	return fmt.Sprintf(`WITH changed AS (%s RETURNING hash, idx, kind, block_number, addr_from, addr_to, amount, fee, status, created_at, status_updated_at)
+INSERT INTO xtz_outbox (event_type, tx_hash, idx, block_number, addr_from, addr_to, amount, fee, status, created_at)
+SELECT '%s', hash, idx, block_number, addr_from, addr_to, amount, fee, status, NOW() FROM changed WHERE kind IS NULL%s;`, strings.TrimSuffix(strings.TrimSpace(statement), ";"), eventType, condition)
+}
+
+// CommitBlock saves the transactions of a block and the block entry in a single database transaction,
+// so that a block is either fully stored or not at all. txCount is the number of transactions of the block
+// on chain, which can be more than the stored ones.
+// It returns the time spent preparing the insertion of the transactions, the statements being then sent to the
+// database in a single round trip.
+func (s *TransactionStorage) CommitBlock(ctx context.Context, block *common_model.Block, transactions []*model.Transaction, txCount uint64) (time.Duration, error) {
+	begin := time.Now()
+	statements, err := commitBlockStatements(block, transactions, txCount)
+	if err != nil {
+		return 0, err
+	}
+	insertDuration := time.Since(begin)
+
+	return insertDuration, s.execBatch(ctx, statements)
+}
+
+// RepairBlock replaces a stored block with the given one in a single database transaction. The stored transactions
+// of the block that are not among the given ones are deleted, except the broadcasted ones.
+// It returns the time spent preparing the insertion of the transactions, as CommitBlock.
+func (s *TransactionStorage) RepairBlock(ctx context.Context, block *common_model.Block, transactions []*model.Transaction, txCount uint64) (time.Duration, error) {
+	begin := time.Now()
+	statements, err := commitBlockStatements(block, transactions, txCount)
+	if err != nil {
+		return 0, err
+	}
+
+	var keep = make([]string, len(transactions))
+	for i, tx := range transactions {
+		// Hashes are checked by commitBlockStatements.
+		keep[i] = fmt.Sprintf("('%s', %d)", tx.Hash, tx.Index)
+	}
+	var deleteStatement = fmt.Sprintf(`DELETE FROM xtz_tx WHERE block_number = %d AND broadcasted = false`, block.Number)
+	if len(keep) > 0 {
+		deleteStatement = fmt.Sprintf(`DELETE FROM xtz_tx WHERE block_number = %d AND broadcasted = false AND (hash, idx) NOT IN (%s)`, block.Number, strings.Join(keep, ","))
+	}
+
+	statements = append([]string{outboxStatement(model.EventTransferReorged, deleteStatement, "")}, statements...)
+	insertDuration := time.Since(begin)
+
+	return insertDuration, s.execBatch(ctx, statements)
+}
+
+// commitBlockStatements returns the statements storing the transactions, then the block entry.
+func commitBlockStatements(block *common_model.Block, transactions []*model.Transaction, txCount uint64) ([]string, error) {
+	switch {
+	case block == nil:
+		return nil, errors.New("block should not be nil")
+	case block.Hash != nil && !helper.IsBase64Alphabet(*block.Hash):
+		return nil, errors.New("Invalid character detected in block hash")
+	}
+
+	statements, err := createTransactionsStatements(transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	// The contents of a broadcasted operation after the first one, which is merged with the broadcast, belong to
+	// the customer of the broadcast and are kept with it.
+	statements = append(statements, fmt.Sprintf(`UPDATE xtz_tx AS t SET (broadcasted, customer_id) = (true, b.customer_id) FROM xtz_tx AS b
+WHERE t.block_number = %d AND t.idx > 0 AND t.broadcasted = false AND b.hash = t.hash AND b.idx = 0 AND b.broadcasted = true;`, block.Number))
+
+	now := time.Now()
+	statements = append(statements, fmt.Sprintf(`UPSERT INTO xtz_block (block_number, block_hash, block_timestamp, tx_count, created_at) VALUES (%d, %s, %s, %d, %s);`,
+		block.Number, database.StringOrNull(block.Hash), database.FormattedTimestampOrNull(block.Timestamp), txCount, database.FormattedTimestampOrNull(&now)))
+
+	return statements, nil
+}
+
+// createTransactionsStatements returns the statements storing the transactions, by batches of commitBlockBatchSize.
+func createTransactionsStatements(transactions []*model.Transaction) ([]string, error) {
+	var statements []string
+	for i := 0; i < len(transactions); i += commitBlockBatchSize {
+		min, max := i, i+commitBlockBatchSize
+		if max > len(transactions) {
+			max = len(transactions)
+		}
+
+		statement, err := createTransactionsStatement(transactions[min:max])
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+	}
+	return statements, nil
+}
+
+// execBatch sends the statements to the database as a single batch. CockroachDB runs the statements of a batch
+// in one implicit transaction, which is rolled back entirely if any of them fails.
+// The batch is retried when the transaction could not be serialized.
+func (s *TransactionStorage) execBatch(ctx context.Context, statements []string) error {
+	var query = strings.Join(statements, "\n")
+
+	var err error
+	for attempt := 0; attempt < batchMaxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(batchRetryBackoff << uint(attempt-1)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		_, err = s.db.ExecContext(ctx, query)
+		if err == nil || !isRetryableError(err) {
+			break
+		}
+	}
 	if err != nil {
 		return errors.Wrapf(err, "could not execute sql batch statement")
 	}
 	return nil
 }
 
+// isRetryableError reports whether the transaction failed with a serialization error (SQLSTATE 40001),
+// in which case CockroachDB expects the client to retry it.
+func isRetryableError(err error) bool {
+	pqErr, ok := errors.Cause(err).(*pq.Error)
+	return ok && pqErr.Code == "40001"
+}
+
 // GetTransactions queries stocked transactions for the given hashes.
 func (s *TransactionStorage) GetTransactions(ctx context.Context, hashes []string) ([]*model.Transaction, error) {
 	var query = `
-SELECT id, hash, idx, block_number, addr_to, addr_from, amount, fee, counter, timestamp, pinned, broadcasted, rawtx, status, message, created_at, created_at_block, broadcasted_at_block
+SELECT id, hash, idx, block_number, addr_to, addr_from, amount, fee, counter, timestamp, pinned, broadcasted, rawtx, status, message, created_at, created_at_block, broadcasted_at_block, branch, expiry_block
 FROM xtz_tx
-WHERE hash in (%[1]s);
+WHERE hash in (%[1]s) AND kind IS NULL;
 `
 	if len(hashes) == 0 {
 		return []*model.Transaction{}, nil
@@ -109,21 +273,22 @@ WHERE hash = $1
 	_, _ = s.db.ExecContext(ctx, query, hash)
 }
 
-// GetTransactionsBetweenBlocks queries stocked transactions for a given address and block numbers.
-func (s *TransactionStorage) GetTransactionsBetweenBlocks(ctx context.Context, addresses []string, fromBlock, toBlock uint64, limit, offset uint64) ([]*model.Transaction, uint64, error) {
+// GetTransactionsBetweenBlocks queries stocked transactions for a given address and block numbers, ordered by
+// block, so that they can be paged. If confirmed is set, only the transactions confirmed under its policy are returned.
+func (s *TransactionStorage) GetTransactionsBetweenBlocks(ctx context.Context, addresses []string, fromBlock, toBlock uint64, limit, offset uint64, confirmed *model.ConfirmedFilter) ([]*model.Transaction, uint64, error) {
 	const query = `
 SELECT * FROM (
   SELECT id, hash, idx, block_number, addr_to, addr_from, amount, fee, counter, timestamp, pinned, broadcasted, rawtx, status, message, created_at, created_at_block, broadcasted_at_block
   FROM xtz_tx
   WHERE addr_from in (%[1]s)
 	AND block_number >= $1
-	AND block_number <= $2
+	AND block_number <= $2 AND kind IS NULL%[2]s
   UNION SELECT id, hash, idx, block_number, addr_to, addr_from, amount, fee, counter, timestamp, pinned, broadcasted, rawtx, status, message, created_at, created_at_block, broadcasted_at_block
   FROM xtz_tx
   WHERE addr_to in (%[1]s)
 	AND block_number >= $1
-	AND block_number <= $2
-) LIMIT $3 OFFSET $4;
+	AND block_number <= $2 AND kind IS NULL%[2]s
+) ORDER BY block_number, hash, idx LIMIT $3 OFFSET $4;
 `
 	const countQuery = `
 SELECT count(*) FROM (
@@ -131,12 +296,12 @@ SELECT count(*) FROM (
   FROM xtz_tx
   WHERE addr_from in (%[1]s)
 	AND block_number >= $1
-	AND block_number <= $2
+	AND block_number <= $2 AND kind IS NULL%[2]s
   UNION SELECT hash
   FROM xtz_tx
   WHERE addr_to in (%[1]s)
 	AND block_number >= $1
-	AND block_number <= $2
+	AND block_number <= $2 AND kind IS NULL%[2]s
 );
 `
 	if len(addresses) == 0 {
@@ -154,8 +319,13 @@ SELECT count(*) FROM (
 	// Remove trailing comma.
 	args = args[:len(args)-1]
 
+	condition, err := confirmedCondition(confirmed)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	var storedTransactions []*transaction
-	if err := s.db.Select(&storedTransactions, fmt.Sprintf(query, args), fromBlock, toBlock, limit, offset); err != nil {
+	if err := s.db.Select(&storedTransactions, fmt.Sprintf(query, args, condition), fromBlock, toBlock, limit, offset); err != nil {
 		return nil, 0, err
 	}
 	// Read repare. If the status is success, the error message should be nil.
@@ -169,7 +339,7 @@ SELECT count(*) FROM (
 	transactions := toModelTransactions(storedTransactions)
 
 	var count uint64
-	if err := database.QueryRowContext(ctx, s.db, fmt.Sprintf(countQuery, args), database.WithArgs(fromBlock, toBlock), database.WithDest(&count)); err != nil {
+	if err := database.QueryRowContext(ctx, s.db, fmt.Sprintf(countQuery, args, condition), database.WithArgs(fromBlock, toBlock), database.WithDest(&count)); err != nil {
 		return nil, 0, err
 	}
 
@@ -177,19 +347,20 @@ SELECT count(*) FROM (
 }
 
 // GetTransactionsBetweenDates queries stocked transactions for a given address and dates.
-func (s *TransactionStorage) GetTransactionsBetweenDates(ctx context.Context, addresses []string, fromDate, toDate time.Time, limit, offset uint64) ([]*model.Transaction, uint64, error) {
+// If confirmed is set, only the transactions confirmed under its policy are returned.
+func (s *TransactionStorage) GetTransactionsBetweenDates(ctx context.Context, addresses []string, fromDate, toDate time.Time, limit, offset uint64, confirmed *model.ConfirmedFilter) ([]*model.Transaction, uint64, error) {
 	const query = `
 SELECT * FROM (
   SELECT id, hash, idx, block_number, addr_to, addr_from, amount, fee, counter, timestamp, pinned, broadcasted, rawtx, status, message, created_at, created_at_block, broadcasted_at_block
   FROM xtz_tx
   WHERE addr_from in (%[1]s)
 	AND timestamp >= $1
-	AND timestamp <= $2
+	AND timestamp <= $2 AND kind IS NULL%[2]s
   UNION SELECT id, hash, idx, block_number, addr_to, addr_from, amount, fee, counter, timestamp, pinned, broadcasted, rawtx, status, message, created_at, created_at_block, broadcasted_at_block
   FROM xtz_tx
   WHERE addr_to in (%[1]s)
 	AND timestamp >= $1
-	AND timestamp <= $2
+	AND timestamp <= $2 AND kind IS NULL%[2]s
 ) LIMIT $3 OFFSET $4;
 `
 	const countQuery = `
@@ -198,12 +369,12 @@ SELECT count(*) FROM (
   FROM xtz_tx
   WHERE addr_from in (%[1]s)
 	AND timestamp >= $1
-	AND timestamp <= $2
+	AND timestamp <= $2 AND kind IS NULL%[2]s
   UNION SELECT hash
   FROM xtz_tx
   WHERE addr_to in (%[1]s)
 	AND timestamp >= $1
-	AND timestamp <= $2
+	AND timestamp <= $2 AND kind IS NULL%[2]s
 );
 `
 	if len(addresses) == 0 {
@@ -221,8 +392,13 @@ SELECT count(*) FROM (
 	// Remove trailing comma.
 	args = args[:len(args)-1]
 
+	condition, err := confirmedCondition(confirmed)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	var storedTransactions []*transaction
-	if err := s.db.Select(&storedTransactions, fmt.Sprintf(query, args), fromDate.UTC(), toDate.UTC(), limit, offset); err != nil {
+	if err := s.db.Select(&storedTransactions, fmt.Sprintf(query, args, condition), fromDate.UTC(), toDate.UTC(), limit, offset); err != nil {
 		return nil, 0, err
 	}
 	// Read repare. If the status is success, the error message should be nil.
@@ -236,14 +412,46 @@ SELECT count(*) FROM (
 	transactions := toModelTransactions(storedTransactions)
 
 	var count uint64
-	if err := database.QueryRowContext(ctx, s.db, fmt.Sprintf(countQuery, args), database.WithArgs(fromDate.UTC(), toDate.UTC()), database.WithDest(&count)); err != nil {
+	if err := database.QueryRowContext(ctx, s.db, fmt.Sprintf(countQuery, args, condition), database.WithArgs(fromDate.UTC(), toDate.UTC()), database.WithDest(&count)); err != nil {
 		return nil, 0, err
 	}
 
 	return transactions, count, nil
 }
 
+// confirmedCondition returns the condition restricting a query to the transactions confirmed under the filter,
+// or an empty string if it is nil. A transaction is confirmed if it succeeded and its block is old enough for its amount.
+//
 //nolint:gosec
+func confirmedCondition(confirmed *model.ConfirmedFilter) (string, error) {
+	if confirmed == nil {
+		return "", nil
+	}
+	if confirmed.Policy == nil {
+		return "", errors.New("confirmed filter should have a policy")
+	}
+
+	// The transactions of the last block have one confirmation.
+	maxBlock := func(confirmations uint64) int64 {
+		return int64(confirmed.Height) + 1 - int64(confirmations)
+	}
+
+	var blockCondition = fmt.Sprintf("block_number <= %d", maxBlock(confirmed.Policy.Confirmations))
+	if len(confirmed.Policy.Thresholds) > 0 {
+		blockCondition = "CASE"
+		for i := len(confirmed.Policy.Thresholds) - 1; i >= 0; i-- {
+			threshold := confirmed.Policy.Thresholds[i]
+			if threshold.MinAmount == nil {
+				return "", errors.New("threshold amount should not be nil")
+			}
+			blockCondition += fmt.Sprintf(" WHEN amount >= %s THEN block_number <= %d", threshold.MinAmount.String(), maxBlock(threshold.Confirmations))
+		}
+		blockCondition += fmt.Sprintf(" ELSE block_number <= %d END", maxBlock(confirmed.Policy.Confirmations))
+	}
+
+	return fmt.Sprintf("\n\tAND status = %d AND block_number >= 0 AND (%s)", common_model.SUCCESS, blockCondition), nil
+}
+
 func (s *TransactionStorage) MarkPinned(ctx context.Context, addresses []string) error {
 	batchSize := 1000
 
@@ -272,9 +480,9 @@ func (s *TransactionStorage) MarkPinned(ctx context.Context, addresses []string)
 
 func (s *TransactionStorage) Broadcast(ctx context.Context, transaction *model.Transaction) error {
 	query := `
-INSERT INTO xtz_tx (hash, idx, block_number, pinned, broadcasted, status, rawtx, timestamp, created_at, created_at_block, broadcasted_at_block)
-VALUES(:hash, 0, -1, false, true, 0, :rawtx, :timestamp, NOW(), :created_at_block, 0)
-ON CONFLICT (hash, idx) DO UPDATE SET (broadcasted, status, message, created_at_block, broadcasted_at_block) = (true, excluded.status, NULL, excluded.created_at_block, 0);
+INSERT INTO xtz_tx (hash, idx, block_number, pinned, broadcasted, status, rawtx, timestamp, created_at, created_at_block, broadcasted_at_block, customer_id, branch, expiry_block, broadcast_source, broadcast_counter, not_before_block, not_before, after_hash, status_updated_at)
+VALUES(:hash, 0, -1, false, true, 0, :rawtx, :timestamp, NOW(), :created_at_block, 0, :customer_id, :branch, :expiry_block, :broadcast_source, :broadcast_counter, :not_before_block, :not_before, :after_hash, NOW())
+ON CONFLICT (hash, idx) DO UPDATE SET (broadcasted, status, message, created_at_block, broadcasted_at_block, customer_id, broadcast_attempts, next_attempt_block, branch, expiry_block, broadcast_source, broadcast_counter, not_before_block, not_before, after_hash, status_updated_at) = (true, excluded.status, NULL, excluded.created_at_block, 0, COALESCE(xtz_tx.customer_id, excluded.customer_id), 0, 0, excluded.branch, excluded.expiry_block, excluded.broadcast_source, excluded.broadcast_counter, excluded.not_before_block, excluded.not_before, excluded.after_hash, NOW());
 `
 	if _, err := s.db.NamedExecContext(ctx, query, transaction); err != nil {
 		return err
@@ -282,39 +490,210 @@ ON CONFLICT (hash, idx) DO UPDATE SET (broadcasted, status, message, created_at_
 	return nil
 }
 
-func (s *TransactionStorage) GetPendingBroadcasts(ctx context.Context, broadcastedBeforeBlock, limit uint64) ([]*model.Transaction, error) {
+// GetPendingBroadcasts returns the broadcasts not yet mined whose next attempt is due at blockNumber, and that have
+// been attempted less than maxAttempts times. A maxAttempts of 0 means no maximum.
+// The broadcasts that cannot be included after blockNumber anymore, and the replaced ones, are left out.
+// The broadcasts of a source are queued by counter: only the first one still pending is returned, the next one
+// being returned once it is included, expired, or timed out by the garbage collection. A predecessor attempted
+// maxAttempts times, or whose expiry is unknown, still holds back its successors, which the node would refuse while it
+// is in the mempool.
+// A scheduled broadcast is returned once blockNumber reaches its not_before_block and its not_before time is past,
+// and a conditional one once its after_hash predecessor, or a replacement of it, is included successfully.
+func (s *TransactionStorage) GetPendingBroadcasts(ctx context.Context, blockNumber, maxAttempts, limit uint64) ([]*model.Transaction, error) {
+	query := fmt.Sprintf(`
+SELECT hash, status, rawtx, broadcast_attempts, next_attempt_block, broadcast_source, broadcast_counter
+FROM xtz_tx@xtz_tx_broadcasted_status_block_number_next_attempt_block_idx AS t
+WHERE broadcasted = true AND status IN (%[1]d, %[2]d, %[3]d) AND block_number = -1 AND next_attempt_block <= $1
+	AND ($2 = 0 OR broadcast_attempts < $2) AND (expiry_block IS NULL OR expiry_block > $1) AND replaced_by IS NULL
+	AND (not_before_block IS NULL OR not_before_block <= $1) AND (not_before IS NULL OR not_before <= NOW())
+	AND (after_hash IS NULL OR EXISTS (
+		SELECT 1 FROM xtz_tx AS a JOIN xtz_tx AS f ON `+sameOperation+`
+		WHERE a.hash = t.after_hash AND a.idx = 0 AND f.idx = 0 AND f.block_number >= 0 AND f.status = %[4]d
+	))
+	AND (broadcast_source IS NULL OR NOT EXISTS (
+		SELECT 1 FROM xtz_tx AS p
+		WHERE p.broadcast_source = t.broadcast_source AND p.broadcast_counter < t.broadcast_counter
+			AND p.broadcasted = true AND p.status IN (%[1]d, %[2]d, %[3]d) AND p.block_number = -1
+			AND (p.expiry_block IS NULL OR p.expiry_block > $1) AND p.replaced_by IS NULL
+	))
+ORDER BY next_attempt_block
+LIMIT $3;
+`, common_model.NEW, common_model.PENDING, common_model.FAILURE, common_model.SUCCESS)
+
+	var storedTransactions []*transaction
+	if err := s.db.Select(&storedTransactions, query, blockNumber, maxAttempts, limit); err != nil {
+		return nil, err
+	}
+
+	return toModelTransactions(storedTransactions), nil
+}
+
+// InsertBroadcastHistory records actions on broadcasts.
+func (s *TransactionStorage) InsertBroadcastHistory(ctx context.Context, entries []*model.BroadcastHistoryEntry) error {
+	const query = `
+INSERT INTO xtz_broadcast_history (tx_hash, action, status, message, block_number, created_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+`
+	for _, e := range entries {
+		if e == nil {
+			return errors.New("entry should not be nil")
+		}
+		// The message is the error returned by the node, it is passed as an argument rather than formatted.
+		st := toStatus(e.Status)
+		if _, err := s.db.ExecContext(ctx, query, e.Hash, e.Action, strconv.Itoa(int(st)), e.Message, e.BlockNumber, e.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBroadcastHistory returns the actions on a broadcast in chronological order. The actions before the history was
+// recorded are not returned.
+func (s *TransactionStorage) GetBroadcastHistory(ctx context.Context, hash string) ([]*model.BroadcastHistoryEntry, error) {
+	const query = `
+SELECT id, tx_hash, action, status, message, block_number, created_at
+FROM xtz_broadcast_history
+WHERE tx_hash = $1
+ORDER BY created_at, id;
+`
+	var storedEntries []*broadcastHistoryEntry
+	if err := s.db.Select(&storedEntries, query, hash); err != nil {
+		return nil, err
+	}
+
+	return toModelBroadcastHistoryEntries(storedEntries), nil
+}
+
+// GetBroadcastQueues returns the queues of the pending broadcasts of the customer by source address, all of them if
+// source is empty.
+func (s *TransactionStorage) GetBroadcastQueues(ctx context.Context, customerID, source string) ([]*model.BroadcastQueue, error) {
 	query := fmt.Sprintf(`
-SELECT hash, status, rawtx
+SELECT broadcast_source, count(*) AS depth, min(broadcast_counter) AS next_counter
 FROM xtz_tx
-WHERE broadcasted = true AND status IN (%d, %d, %d) AND block_number = -1 AND broadcasted_at_block <= $1 LIMIT $2;
+WHERE customer_id = $1 AND ($2 = '' OR broadcast_source = $2) AND broadcast_source IS NOT NULL
+	AND broadcasted = true AND status IN (%d, %d, %d) AND block_number = -1 AND replaced_by IS NULL
+GROUP BY broadcast_source
+ORDER BY broadcast_source;
 `, common_model.NEW, common_model.PENDING, common_model.FAILURE)
 
+	var storedQueues []*broadcastQueue
+	if err := s.db.Select(&storedQueues, query, customerID, source); err != nil {
+		return nil, err
+	}
+
+	return toModelBroadcastQueues(storedQueues), nil
+}
+
+// GetOperationGroup returns the rows of an operation group ordered by index: the broadcast, merged with the first
+// content once included, and the other included contents.
+func (s *TransactionStorage) GetOperationGroup(ctx context.Context, hash string) ([]*model.Transaction, error) {
+	const query = `
+SELECT hash, idx, kind, block_number, addr_to, addr_from, amount, fee, counter, timestamp, broadcasted, status, message, customer_id
+FROM xtz_tx
+WHERE hash = $1
+ORDER BY idx;
+`
 	var storedTransactions []*transaction
-	if err := s.db.Select(&storedTransactions, query, broadcastedBeforeBlock, limit); err != nil {
+	if err := s.db.Select(&storedTransactions, query, hash); err != nil {
 		return nil, err
 	}
 
 	return toModelTransactions(storedTransactions), nil
 }
 
-// UpdateBroadcast updates a broadcasted transaction with a new timestamp, status and error message.
-func (s *TransactionStorage) UpdateBroadcast(ctx context.Context, hash, status, message string, broadcastedAtBlock uint64) error {
+// GetBroadcast returns the broadcast of the given hash, nil if there is none.
+func (s *TransactionStorage) GetBroadcast(ctx context.Context, hash string) (*model.Transaction, error) {
 	const query = `
-UPDATE xtz_tx SET (broadcasted_at_block, status, message) = ($2, $3, $4)
-WHERE hash = $1
+SELECT hash, idx, block_number, status, message, rawtx, customer_id, created_at, created_at_block, broadcasted_at_block, broadcast_attempts, next_attempt_block, branch, expiry_block, replaces, replaced_by, broadcast_source, broadcast_counter, cancelled_by, cancel_reason, cancelled_at, not_before_block, not_before, after_hash, status_updated_at
+FROM xtz_tx
+WHERE hash = $1 AND broadcasted = true
+ORDER BY idx
+LIMIT 1;
 `
-	st := common_model.ToStatus(status)
-	if _, err := s.db.ExecContext(ctx, query, hash, broadcastedAtBlock, strconv.Itoa(int(st)), message); err != nil {
+	var storedTransactions []*transaction
+	if err := s.db.Select(&storedTransactions, query, hash); err != nil {
+		return nil, err
+	}
+
+	if len(storedTransactions) == 0 {
+		return nil, nil
+	}
+	return toModelTransaction(storedTransactions[0]), nil
+}
+
+// ReplaceBroadcast stores the broadcast of an operation replacing the one of replacedHash, and links both, in a single
+// statement. The replaced broadcast is not broadcasted anymore.
+// It fails if the replaced broadcast is already replaced, mined or in a terminal status, e.g. because of a concurrent
+// replacement.
+func (s *TransactionStorage) ReplaceBroadcast(ctx context.Context, replacedHash string, transaction *model.Transaction) error {
+	switch {
+	case transaction == nil:
+		return errors.New("transaction should not be nil")
+	case !helper.IsBase64Alphabet(transaction.Hash) || !helper.IsBase64Alphabet(replacedHash):
+		return errors.New("Invalid character detected in transaction hash")
+	}
+
+	query := fmt.Sprintf(`
+WITH replaced AS (
+  UPDATE xtz_tx SET replaced_by = '%[1]s'
+  WHERE hash = '%[2]s' AND broadcasted = true AND replaced_by IS NULL AND block_number = -1 AND status IN (%[3]d, %[4]d, %[5]d)
+  RETURNING hash
+)
+INSERT INTO xtz_tx (hash, idx, block_number, pinned, broadcasted, status, rawtx, timestamp, created_at, created_at_block, broadcasted_at_block, customer_id, branch, expiry_block, replaces, broadcast_source, broadcast_counter, not_before_block, not_before, after_hash, status_updated_at)
+SELECT '%[1]s', 0, -1, false, true, %[3]d, %[6]s, %[7]s, NOW(), %[8]s, 0, %[9]s, %[10]s, %[11]s, '%[2]s', %[12]s, %[13]s, %[14]s, %[15]s, %[16]s, NOW()
+FROM replaced
+LIMIT 1;`,
+		transaction.Hash, replacedHash, common_model.NEW, common_model.PENDING, common_model.FAILURE,
+		database.StringOrNull(transaction.RawTransaction), database.FormattedTimestampOrNull(transaction.Timestamp),
+		database.Uint64OrNull(transaction.CreatedAtBlockNumber), database.StringOrNull(transaction.CustomerID), database.StringOrNull(transaction.Branch),
+		database.Uint64OrNull(transaction.ExpiryBlock), database.StringOrNull(transaction.BroadcastSource), database.Uint64OrNull(transaction.BroadcastCounter),
+		database.Uint64OrNull(transaction.NotBeforeBlock), database.FormattedTimestampOrNull(transaction.NotBefore), database.StringOrNull(transaction.AfterHash))
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.Errorf("broadcast %q cannot be replaced anymore", replacedHash)
+	}
+	return nil
+}
+
+// UpdateBroadcast updates a broadcasted transaction after an attempt with a new timestamp, status and error message,
+// counts the attempt and schedules the next one at nextAttemptBlock.
+// A broadcast that left the pending statuses during the attempt, e.g. cancelled or mined, is left unchanged.
+// A status change is written to the outbox.
+func (s *TransactionStorage) UpdateBroadcast(ctx context.Context, hash, status, message string, broadcastedAtBlock, nextAttemptBlock uint64) error {
+	query := fmt.Sprintf(`
+WITH previous AS (SELECT hash, idx, status FROM xtz_tx WHERE hash = $1),
+changed AS (
+  UPDATE xtz_tx SET (broadcasted_at_block, status, message, broadcast_attempts, next_attempt_block, status_updated_at) = ($2, $3, $4, broadcast_attempts + 1, $5, IF(status = $3, status_updated_at, NOW()))
+  WHERE hash = $1 AND status IN (%d, %d, %d)
+  RETURNING hash, idx, block_number, addr_from, addr_to, amount, fee, status
+)
+INSERT INTO xtz_outbox (event_type, tx_hash, idx, block_number, addr_from, addr_to, amount, fee, status, previous_status, created_at)
+SELECT '`+model.EventBroadcastStatus+`', c.hash, c.idx, c.block_number, c.addr_from, c.addr_to, c.amount, c.fee, c.status, p.status, NOW()
+FROM changed AS c JOIN previous AS p ON p.hash = c.hash AND p.idx = c.idx
+WHERE c.status != p.status;
+`, common_model.NEW, common_model.PENDING, common_model.FAILURE)
+	st := toStatus(status)
+	if _, err := s.db.ExecContext(ctx, query, hash, broadcastedAtBlock, strconv.Itoa(int(st)), message, nextAttemptBlock); err != nil {
 		return err
 	}
 	return nil
 }
 
+// GetBroadcastsToGarbageCollect returns the pending broadcasts created before beforeBlock whose expiry is unknown.
+// The ones whose expiry is known are timed out from GetExpiredBroadcasts instead.
 func (s *TransactionStorage) GetBroadcastsToGarbageCollect(ctx context.Context, beforeBlock uint64) ([]string, error) {
 	query := fmt.Sprintf(`
 SELECT hash
 FROM xtz_tx@xtz_tx_broadcasted_status_block_number_broadcasted_at_block_idx
-WHERE broadcasted = true AND status IN (%d, %d) AND block_number = -1 AND created_at_block <= $1;
+WHERE broadcasted = true AND status IN (%d, %d) AND block_number = -1 AND created_at_block <= $1 AND expiry_block IS NULL;
 `, common_model.PENDING, common_model.FAILURE)
 
 	var hashes []string
@@ -325,10 +704,121 @@ WHERE broadcasted = true AND status IN (%d, %d) AND block_number = -1 AND create
 	return hashes, nil
 }
 
+// GetExpiredBroadcasts returns the broadcasts not mined up to lastBlock, the last indexed block, that cannot be
+// included after it anymore: their expiry level, the last level at which they can be included, is reached.
+func (s *TransactionStorage) GetExpiredBroadcasts(ctx context.Context, lastBlock uint64) ([]string, error) {
+	query := fmt.Sprintf(`
+SELECT hash
+FROM xtz_tx@xtz_tx_broadcasted_status_block_number_expiry_block_idx
+WHERE broadcasted = true AND status IN (%d, %d, %d) AND block_number = -1 AND expiry_block <= $1;
+`, common_model.NEW, common_model.PENDING, common_model.FAILURE)
+
+	var hashes []string
+	if err := s.db.Select(&hashes, query, lastBlock); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// CancelBroadcast cancels a broadcast not yet mined and still to be broadcasted: it is set CANCELLED so that it is not
+// broadcasted anymore, and who cancelled it and why are recorded. The status change is written to the outbox.
+// A broadcast mined or already in a terminal status is left unchanged.
+func (s *TransactionStorage) CancelBroadcast(ctx context.Context, hash, cancelledBy, reason string) error {
+	query := fmt.Sprintf(`
+WITH previous AS (SELECT hash, idx, status FROM xtz_tx WHERE hash = $1),
+changed AS (
+  UPDATE xtz_tx SET (status, message, cancelled_by, cancel_reason, cancelled_at, status_updated_at) = (%[4]d, $4, $2, $3, NOW(), NOW())
+  WHERE hash = $1 AND broadcasted = true AND block_number = -1 AND status IN (%[1]d, %[2]d, %[3]d)
+  RETURNING hash, idx, block_number, addr_from, addr_to, amount, fee, status
+)
+INSERT INTO xtz_outbox (event_type, tx_hash, idx, block_number, addr_from, addr_to, amount, fee, status, previous_status, created_at)
+SELECT '`+model.EventBroadcastStatus+`', c.hash, c.idx, c.block_number, c.addr_from, c.addr_to, c.amount, c.fee, c.status, p.status, NOW()
+FROM changed AS c JOIN previous AS p ON p.hash = c.hash AND p.idx = c.idx;
+`, common_model.NEW, common_model.PENDING, common_model.FAILURE, statusCancelled)
+
+	message := fmt.Sprintf("cancelled by %s: %s", cancelledBy, reason)
+	if _, err := s.db.ExecContext(ctx, query, hash, cancelledBy, reason, message); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetDependentBroadcasts returns the pending broadcasts of the customer of the given one that cannot be included
+// without it: the ones of its source with a higher counter, and the ones to be broadcasted after it or after a broadcast
+// it replaces. A replaced broadcast has no dependents, they depend on its replacement.
+func (s *TransactionStorage) GetDependentBroadcasts(ctx context.Context, hash string) ([]string, error) {
+	query := fmt.Sprintf(`
+SELECT t.hash
+FROM xtz_tx AS t JOIN xtz_tx AS a ON a.hash = $1 AND a.idx = 0 AND a.replaced_by IS NULL
+WHERE t.broadcasted = true AND t.idx = 0 AND t.status IN (%d, %d, %d) AND t.block_number = -1 AND t.replaced_by IS NULL
+	AND t.hash != a.hash AND t.customer_id = a.customer_id
+	AND ((t.broadcast_source = a.broadcast_source AND t.broadcast_counter > a.broadcast_counter) OR EXISTS (
+		SELECT 1 FROM xtz_tx AS f WHERE f.hash = t.after_hash AND f.idx = 0 AND `+sameOperation+`
+	))
+ORDER BY t.hash;
+`, common_model.NEW, common_model.PENDING, common_model.FAILURE)
+
+	var hashes = []string{}
+	if err := s.db.Select(&hashes, query, hash); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// FailDependentBroadcasts sets INVALID the pending broadcasts to be broadcasted after a broadcast that failed, together
+// with its replacements: one of them was included but not applied, or none of them is left to be broadcasted, the
+// replaced ones aside. A replaced predecessor timing out does not fail them while its replacement can still be included.
+// It returns them with the predecessor in AfterHash. The status changes are written to the outbox.
+func (s *TransactionStorage) FailDependentBroadcasts(ctx context.Context) ([]*model.Transaction, error) {
+	query := fmt.Sprintf(`
+WITH failed AS (
+  SELECT t.hash
+  FROM xtz_tx AS t JOIN xtz_tx AS a ON a.hash = t.after_hash AND a.idx = 0 AND a.broadcasted = true
+  WHERE t.broadcasted = true AND t.idx = 0 AND t.status IN (%[1]d, %[2]d, %[3]d) AND t.block_number = -1 AND t.after_hash IS NOT NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM xtz_tx AS f WHERE f.idx = 0 AND `+sameOperation+` AND f.block_number >= 0 AND f.status = %[5]d
+	)
+	AND (EXISTS (
+		SELECT 1 FROM xtz_tx AS f WHERE f.idx = 0 AND `+sameOperation+` AND f.block_number >= 0
+	) OR NOT EXISTS (
+		SELECT 1 FROM xtz_tx AS f WHERE f.idx = 0 AND `+sameOperation+` AND f.block_number = -1 AND f.replaced_by IS NULL AND f.status IN (%[1]d, %[2]d, %[3]d)
+	))
+),
+previous AS (SELECT hash, idx, status FROM xtz_tx WHERE hash IN (SELECT hash FROM failed) AND idx = 0),
+changed AS (
+  UPDATE xtz_tx SET (status, message, status_updated_at) = (%[4]d, 'predecessor ' || after_hash || ' failed', NOW())
+  WHERE hash IN (SELECT hash FROM failed) AND idx = 0
+  RETURNING hash, idx, block_number, addr_from, addr_to, amount, fee, status, message, after_hash
+),
+outbox AS (
+  INSERT INTO xtz_outbox (event_type, tx_hash, idx, block_number, addr_from, addr_to, amount, fee, status, previous_status, created_at)
+  SELECT '`+model.EventBroadcastStatus+`', c.hash, c.idx, c.block_number, c.addr_from, c.addr_to, c.amount, c.fee, c.status, p.status, NOW()
+  FROM changed AS c JOIN previous AS p ON p.hash = c.hash AND p.idx = c.idx
+)
+SELECT hash, idx, status, message, after_hash FROM changed;
+`, common_model.NEW, common_model.PENDING, common_model.FAILURE, common_model.INVALID, common_model.SUCCESS)
+
+	var storedTransactions []*transaction
+	if err := s.db.Select(&storedTransactions, query); err != nil {
+		return nil, err
+	}
+	return toModelTransactions(storedTransactions), nil
+}
+
+// GarbageCollectBroadcasts times out the given broadcasts, unless they left the pending statuses meanwhile, e.g.
+// cancelled or mined. The status changes are written to the outbox.
 func (s *TransactionStorage) GarbageCollectBroadcasts(ctx context.Context, broadcastHashes []string) error {
 	query := `
-UPDATE xtz_tx SET status = $1
-WHERE hash in (%[1]s);
+WITH previous AS (SELECT hash, idx, status FROM xtz_tx WHERE hash in (%[1]s)),
+changed AS (
+  UPDATE xtz_tx SET (status, status_updated_at) = ($1, NOW())
+  WHERE hash in (%[1]s) AND status IN (%[2]d, %[3]d, %[4]d)
+  RETURNING hash, idx, block_number, addr_from, addr_to, amount, fee, status
+)
+INSERT INTO xtz_outbox (event_type, tx_hash, idx, block_number, addr_from, addr_to, amount, fee, status, previous_status, created_at)
+SELECT '` + model.EventBroadcastStatus + `', c.hash, c.idx, c.block_number, c.addr_from, c.addr_to, c.amount, c.fee, c.status, p.status, NOW()
+FROM changed AS c JOIN previous AS p ON p.hash = c.hash AND p.idx = c.idx;
 `
 	batchSize := 100
 	for {
@@ -342,13 +832,13 @@ WHERE hash in (%[1]s);
 		}
 
 		var args string
-		for _, broadcastHash := range broadcastHashes {
+		for _, broadcastHash := range broadcastHashes[:max] {
 			args += fmt.Sprintf("'%s',", broadcastHash)
 		}
 		// Remove trailing comma.
 		args = args[:len(args)-1]
 
-		if _, err := s.db.ExecContext(ctx, fmt.Sprintf(query, args), common_model.TIMEOUT); err != nil {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf(query, args, common_model.NEW, common_model.PENDING, common_model.FAILURE), common_model.TIMEOUT); err != nil {
 			return err
 		}
 
@@ -357,6 +847,8 @@ WHERE hash in (%[1]s);
 	return nil
 }
 
+// GarbageCollectTransactions deletes the transactions up to beforeBlock that are neither pinned nor broadcasted, then
+// the history of the broadcasts that are not stored anymore.
 func (s *TransactionStorage) GarbageCollectTransactions(ctx context.Context, beforeBlock uint64) error {
 	for {
 		// Get IDs
@@ -365,7 +857,7 @@ func (s *TransactionStorage) GarbageCollectTransactions(ctx context.Context, bef
 			return err
 		}
 		if len(ids) == 0 {
-			return nil
+			return s.garbageCollectBroadcastHistory(ctx)
 		}
 
 		// Delete IDs
@@ -394,6 +886,28 @@ func (s *TransactionStorage) GarbageCollectTransactions(ctx context.Context, bef
 	}
 }
 
+// garbageCollectBroadcastHistory deletes the history of the broadcasts deleted from the 'xtz_tx' table, by batches.
+func (s *TransactionStorage) garbageCollectBroadcastHistory(ctx context.Context) error {
+	const query = `
+DELETE FROM xtz_broadcast_history AS h
+WHERE NOT EXISTS (SELECT 1 FROM xtz_tx AS t WHERE t.hash = h.tx_hash AND t.broadcasted = true)
+LIMIT 10000;
+`
+	for {
+		res, err := s.db.ExecContext(ctx, query)
+		if err != nil {
+			return errors.Wrapf(err, "could not delete broadcast history")
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
 func (s *TransactionStorage) getTransactionIDs(ctx context.Context, limit int, beforeBlock uint64) ([]string, error) {
 	const query = "SELECT id FROM xtz_tx WHERE pinned = false AND broadcasted = false AND block_number <= $1 LIMIT $2"
 	rows, err := s.db.QueryContext(ctx, query, beforeBlock, limit)
@@ -433,14 +947,14 @@ func (s *TransactionStorage) DumpPendingBroadcasts(ctx context.Context, limit, o
 SELECT hash, rawtx
 FROM xtz_tx
 AS OF SYSTEM TIME '%s'
-WHERE broadcasted = true AND status IN (%d, %d, %d) LIMIT $1 OFFSET $2;
+WHERE broadcasted = true AND idx = 0 AND status IN (%d, %d, %d) LIMIT $1 OFFSET $2;
 `, database.FormatSystemTime(asOfSystemTime), common_model.NEW, common_model.PENDING, common_model.FAILURE)
 
 	countQuery := fmt.Sprintf(`
 SELECT count(*)
 FROM xtz_tx
 AS OF SYSTEM TIME '%s'
-WHERE broadcasted = true AND status IN (%d, %d, %d);
+WHERE broadcasted = true AND idx = 0 AND status IN (%d, %d, %d);
 `, database.FormatSystemTime(asOfSystemTime), common_model.NEW, common_model.PENDING, common_model.FAILURE)
 
 	var storedTransactions []*transaction
@@ -540,3 +1054,1031 @@ func (s *TransactionStorage) getTransactionIDsForBlock(ctx context.Context, limi
 	}
 	return ids, nil
 }
+
+// GetBlocksTransactionHashes returns the distinct hashes of the transactions stored for the given blocks.
+func (s *TransactionStorage) GetBlocksTransactionHashes(ctx context.Context, blockNumbers []uint64) ([]string, error) {
+	const query = `
+SELECT DISTINCT hash
+FROM xtz_tx@xtz_tx_block_number_idx
+WHERE block_number IN (%[1]s);
+`
+	if len(blockNumbers) == 0 {
+		return []string{}, nil
+	}
+
+	var hashes = []string{}
+	if err := s.db.Select(&hashes, fmt.Sprintf(query, formatNumbers(blockNumbers))); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// RollbackBlocks deletes the transactions and the entries of the blocks rolled back by a reorg,
+// and records the reorg in the 'xtz_reorg' journal, in a single database transaction. The deleted transactions are written to the outbox,
+// and the transfer checkpoints of the webhooks are moved back to the fork so that the new blocks are notified.
+func (s *TransactionStorage) RollbackBlocks(ctx context.Context, reorg *model.Reorg) error {
+	if reorg == nil || reorg.Depth == 0 {
+		return errors.New("reorg should roll back at least one block")
+	}
+
+	var blockNumbers []uint64
+	for blockNumber := reorg.ForkBlock + 1; blockNumber <= reorg.ForkBlock+reorg.Depth; blockNumber++ {
+		blockNumbers = append(blockNumbers, blockNumber)
+	}
+
+	var arrays = make([]string, 3)
+	for i, hashes := range [][]string{reorg.OldHashes, reorg.NewHashes, reorg.TransactionHashes} {
+		array, err := formatHashArray(hashes)
+		if err != nil {
+			return err
+		}
+		arrays[i] = array
+	}
+
+	var statements = []string{
+		fmt.Sprintf(`INSERT INTO xtz_reorg (detected_at_block, fork_block, depth, old_hashes, new_hashes, tx_hashes, created_at) VALUES (%d, %d, %d, %s, %s, %s, NOW());`,
+			reorg.DetectedAtBlock, reorg.ForkBlock, reorg.Depth, arrays[0], arrays[1], arrays[2]),
+		outboxStatement(model.EventTransferReorged, fmt.Sprintf(`DELETE FROM xtz_tx WHERE block_number IN (%s)`, formatNumbers(blockNumbers)), ""),
+		fmt.Sprintf(`DELETE FROM xtz_block WHERE block_number IN (%s);`, formatNumbers(blockNumbers)),
+		fmt.Sprintf(`UPDATE xtz_indexer_progress SET (block_number, updated_at) = (%[1]d, NOW()) WHERE name LIKE 'webhook:transfer.%%' AND block_number > %[1]d;`, reorg.ForkBlock+1),
+	}
+
+	return s.execBatch(ctx, statements)
+}
+
+// GetReorgs returns the reorgs journaled between the given dates, most recent first.
+func (s *TransactionStorage) GetReorgs(ctx context.Context, fromDate, toDate time.Time, limit, offset uint64) ([]*model.Reorg, uint64, error) {
+	const query = `
+SELECT id, detected_at_block, fork_block, depth, old_hashes, new_hashes, tx_hashes, created_at
+FROM xtz_reorg
+WHERE created_at >= $1 AND created_at <= $2
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4;
+`
+	const countQuery = `
+SELECT count(*)
+FROM xtz_reorg
+WHERE created_at >= $1 AND created_at <= $2;
+`
+	var storedReorgs []*reorg
+	if err := s.db.Select(&storedReorgs, query, fromDate.UTC(), toDate.UTC(), limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	var count uint64
+	if err := database.QueryRowContext(ctx, s.db, countQuery, database.WithArgs(fromDate.UTC(), toDate.UTC()), database.WithDest(&count)); err != nil {
+		return nil, 0, err
+	}
+
+	return toModelReorgs(storedReorgs), count, nil
+}
+
+// CreateIndexerHalt records that the block fetcher halted, and returns the ID of the halt.
+func (s *TransactionStorage) CreateIndexerHalt(ctx context.Context, halt *model.IndexerHalt) (string, error) {
+	const query = `
+INSERT INTO xtz_indexer_halt (block_number, depth, max_depth, stored_hash, chain_hash, created_at)
+VALUES ($1, $2, $3, $4, $5, NOW())
+RETURNING id;
+`
+	if halt == nil {
+		return "", errors.New("halt should not be nil")
+	}
+
+	var id string
+	if err := database.QueryRowContext(ctx, s.db, query, database.WithArgs(halt.BlockNumber, halt.Depth, halt.MaxDepth, halt.StoredHash, halt.ChainHash), database.WithDest(&id)); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// GetIndexerHalt returns the halt of the block fetcher that is not closed yet, or nil if the block fetcher is not halted.
+func (s *TransactionStorage) GetIndexerHalt(ctx context.Context) (*model.IndexerHalt, error) {
+	const query = `
+SELECT id, block_number, depth, max_depth, stored_hash, chain_hash, decision, decided_by, decided_at, closed_at, created_at
+FROM xtz_indexer_halt
+WHERE closed_at IS NULL
+ORDER BY created_at DESC
+LIMIT 1;
+`
+	var storedHalts []*indexerHalt
+	if err := s.db.Select(&storedHalts, query); err != nil {
+		return nil, err
+	}
+
+	if len(storedHalts) == 0 {
+		return nil, nil
+	}
+	return toModelIndexerHalt(storedHalts[0]), nil
+}
+
+// DecideIndexerHalt records the operator decision on an open halt. A halt can only be decided once.
+func (s *TransactionStorage) DecideIndexerHalt(ctx context.Context, id, decision, decidedBy string) error {
+	const query = `
+UPDATE xtz_indexer_halt SET (decision, decided_by, decided_at) = ($2, $3, NOW())
+WHERE id = $1 AND closed_at IS NULL AND decision IS NULL;
+`
+	res, err := s.db.ExecContext(ctx, query, id, decision, decidedBy)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.Errorf("no undecided open halt with id %q", id)
+	}
+	return nil
+}
+
+// CloseIndexerHalt closes a halt once the block fetcher has applied the operator decision.
+func (s *TransactionStorage) CloseIndexerHalt(ctx context.Context, id string) error {
+	const query = `
+UPDATE xtz_indexer_halt SET closed_at = NOW()
+WHERE id = $1 AND closed_at IS NULL;
+`
+	if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateBackfill records a backfill and its shards, and returns the ID of the backfill.
+// Every shard starts at its first block.
+func (s *TransactionStorage) CreateBackfill(ctx context.Context, backfill *model.Backfill) (string, error) {
+	const query = `
+WITH b AS (
+	INSERT INTO xtz_backfill (from_block, to_block, shards, addresses, created_at) VALUES ($1, $2, $3, $4, NOW()) RETURNING id
+)
+INSERT INTO xtz_backfill_shard (backfill_id, shard, from_block, to_block, next_block, updated_at)
+SELECT b.id, s.shard, s.from_block, s.to_block, s.from_block, NOW()
+FROM b, (VALUES %[1]s) AS s (shard, from_block, to_block)
+RETURNING backfill_id;
+`
+	if backfill == nil || len(backfill.Shards) == 0 {
+		return "", errors.New("backfill should have at least one shard")
+	}
+
+	var values = make([]string, len(backfill.Shards))
+	for i, shard := range backfill.Shards {
+		values[i] = fmt.Sprintf("(%d, %d, %d)", shard.Shard, shard.FromBlock, shard.ToBlock)
+	}
+
+	var ids []string
+	if err := s.db.Select(&ids, fmt.Sprintf(query, strings.Join(values, ",")), backfill.FromBlock, backfill.ToBlock, len(backfill.Shards), pq.Array(backfill.Addresses)); err != nil {
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", errors.New("backfill was not created")
+	}
+
+	return ids[0], nil
+}
+
+// GetBackfill returns a backfill with the progress of its shards.
+func (s *TransactionStorage) GetBackfill(ctx context.Context, id string) (*model.Backfill, error) {
+	const query = `
+SELECT id, from_block, to_block, addresses, completed_at, created_at
+FROM xtz_backfill
+WHERE id = $1;
+`
+	const shardsQuery = `
+SELECT backfill_id, shard, from_block, to_block, next_block, completed_at, updated_at
+FROM xtz_backfill_shard
+WHERE backfill_id = $1
+ORDER BY shard;
+`
+	var storedBackfills []*backfill
+	if err := s.db.Select(&storedBackfills, query, id); err != nil {
+		return nil, err
+	}
+	if len(storedBackfills) == 0 {
+		return nil, errors.Errorf("no backfill with id %q", id)
+	}
+
+	var storedShards []*backfillShard
+	if err := s.db.Select(&storedShards, shardsQuery, id); err != nil {
+		return nil, err
+	}
+
+	return toModelBackfills(storedBackfills, storedShards)[0], nil
+}
+
+// GetPendingBackfills returns the backfills that are not completed, oldest first.
+func (s *TransactionStorage) GetPendingBackfills(ctx context.Context) ([]*model.Backfill, error) {
+	const query = `
+SELECT id, from_block, to_block, addresses, completed_at, created_at
+FROM xtz_backfill
+WHERE completed_at IS NULL
+ORDER BY created_at;
+`
+	const shardsQuery = `
+SELECT s.backfill_id, s.shard, s.from_block, s.to_block, s.next_block, s.completed_at, s.updated_at
+FROM xtz_backfill_shard AS s
+JOIN xtz_backfill AS b ON b.id = s.backfill_id
+WHERE b.completed_at IS NULL
+ORDER BY s.shard;
+`
+	var storedBackfills []*backfill
+	if err := s.db.Select(&storedBackfills, query); err != nil {
+		return nil, err
+	}
+
+	var storedShards []*backfillShard
+	if err := s.db.Select(&storedShards, shardsQuery); err != nil {
+		return nil, err
+	}
+
+	return toModelBackfills(storedBackfills, storedShards), nil
+}
+
+// CommitBackfillBlock stores the transactions of a backfilled block and moves the checkpoint of its shard
+// past the block, in a single database transaction. The 'xtz_block' table is left untouched.
+func (s *TransactionStorage) CommitBackfillBlock(ctx context.Context, backfillID string, shard, blockNumber uint64, transactions []*model.Transaction) error {
+	if !uuidRegexp.MatchString(backfillID) {
+		return errors.Errorf("invalid backfill id %q", backfillID)
+	}
+
+	statements, err := createTransactionsStatements(transactions)
+	if err != nil {
+		return err
+	}
+
+	statements = append(statements, fmt.Sprintf(`UPDATE xtz_backfill_shard SET (next_block, completed_at, updated_at) = (%[3]d + 1, CASE WHEN %[3]d >= to_block THEN NOW() END, NOW()) WHERE backfill_id = '%[1]s' AND shard = %[2]d;`,
+		backfillID, shard, blockNumber))
+
+	return s.execBatch(ctx, statements)
+}
+
+// CompleteBackfill marks a backfill completed once all its shards are.
+func (s *TransactionStorage) CompleteBackfill(ctx context.Context, id string) error {
+	const query = `
+UPDATE xtz_backfill SET completed_at = NOW()
+WHERE id = $1 AND completed_at IS NULL AND NOT EXISTS (SELECT 1 FROM xtz_backfill_shard WHERE backfill_id = $1 AND completed_at IS NULL);
+`
+	if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateAddressOnboardings records the onboarding of addresses, replacing their previous onboarding if any.
+func (s *TransactionStorage) CreateAddressOnboardings(ctx context.Context, onboardings []*model.AddressOnboarding) error {
+	const query = `
+UPSERT INTO xtz_address_onboarding (address, since_block, to_block, backfill_id, created_at)
+VALUES ($1, $2, $3, $4, NOW());
+`
+	for _, onboarding := range onboardings {
+		if _, err := s.db.ExecContext(ctx, query, onboarding.Address, onboarding.SinceBlock, onboarding.ToBlock, onboarding.BackfillID); err != nil {
+			return errors.Wrapf(err, "could not create onboarding of address %q", onboarding.Address)
+		}
+	}
+	return nil
+}
+
+// GetAddressOnboardings returns the onboarding of the given addresses, with the progress of their backfill.
+// Addresses that were never onboarded are omitted.
+func (s *TransactionStorage) GetAddressOnboardings(ctx context.Context, addresses []string) ([]*model.AddressOnboarding, error) {
+	const query = `
+SELECT o.address, o.since_block, o.to_block, o.backfill_id, b.completed_at,
+	COALESCE((SELECT sum(s.next_block - s.from_block) FROM xtz_backfill_shard AS s WHERE s.backfill_id = o.backfill_id), 0) AS scanned_blocks,
+	o.created_at
+FROM xtz_address_onboarding AS o
+LEFT JOIN xtz_backfill AS b ON b.id = o.backfill_id
+WHERE o.address = ANY($1)
+ORDER BY o.address;
+`
+	var storedOnboardings []*addressOnboarding
+	if err := s.db.Select(&storedOnboardings, query, pq.Array(addresses)); err != nil {
+		return nil, err
+	}
+
+	return toModelAddressOnboardings(storedOnboardings), nil
+}
+
+// GetAddressesCreatedSince returns the addresses of the 'xtz_addresses' table created at or after the given date,
+// with the creation date of the most recent one.
+func (s *TransactionStorage) GetAddressesCreatedSince(ctx context.Context, since time.Time) ([]string, time.Time, error) {
+	const query = `
+SELECT address, created_at
+FROM xtz_addresses@xtz_addresses_created_at_idx
+WHERE created_at >= $1
+ORDER BY created_at;
+`
+	var storedAddresses []*struct {
+		Address   string    `db:"address"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+	if err := s.db.Select(&storedAddresses, query, since.UTC()); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var (
+		addresses = make([]string, len(storedAddresses))
+		last      time.Time
+	)
+	for i, a := range storedAddresses {
+		addresses[i] = a.Address
+		last = a.CreatedAt
+	}
+	return addresses, last, nil
+}
+
+// GetBroadcastedHashes returns the given hashes that belong to broadcasted transactions.
+func (s *TransactionStorage) GetBroadcastedHashes(ctx context.Context, hashes []string) ([]string, error) {
+	const query = `
+SELECT DISTINCT hash
+FROM xtz_tx
+WHERE hash = ANY($1) AND broadcasted = true;
+`
+	if len(hashes) == 0 {
+		return []string{}, nil
+	}
+
+	var res = []string{}
+	if err := s.db.Select(&res, query, pq.Array(hashes)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetStoredBlocks returns the entries of the 'xtz_block' table between the given blocks, in increasing order, with
+// the number of transactions stored for each of them.
+func (s *TransactionStorage) GetStoredBlocks(ctx context.Context, fromBlock, toBlock uint64) ([]*model.StoredBlock, error) {
+	const query = `
+SELECT b.block_number, b.block_hash, b.tx_count, (SELECT count(*) FROM xtz_tx AS t WHERE t.block_number = b.block_number AND t.kind IS NULL) AS stored_tx_count
+FROM xtz_block AS b
+WHERE b.block_number >= $1 AND b.block_number <= $2
+ORDER BY b.block_number;
+`
+	var storedBlocks []*storedBlock
+	if err := s.db.Select(&storedBlocks, query, fromBlock, toBlock); err != nil {
+		return nil, err
+	}
+
+	return toModelStoredBlocks(storedBlocks), nil
+}
+
+// CreateIntegrityFindings saves the findings of the integrity verifier.
+func (s *TransactionStorage) CreateIntegrityFindings(ctx context.Context, findings []*model.IntegrityFinding) error {
+	const query = `
+INSERT INTO xtz_integrity_finding (block_number, kind, stored_value, chain_value, repaired, created_at)
+VALUES ($1, $2, $3, $4, $5, NOW());
+`
+	for _, finding := range findings {
+		if _, err := s.db.ExecContext(ctx, query, finding.BlockNumber, finding.Kind, finding.StoredValue, finding.ChainValue, finding.Repaired); err != nil {
+			return errors.Wrapf(err, "could not create finding for block %d", finding.BlockNumber)
+		}
+	}
+	return nil
+}
+
+// GetIntegrityFindings returns the findings of the integrity verifier between the given dates, most recent first.
+func (s *TransactionStorage) GetIntegrityFindings(ctx context.Context, fromDate, toDate time.Time, limit, offset uint64) ([]*model.IntegrityFinding, uint64, error) {
+	const query = `
+SELECT id, block_number, kind, stored_value, chain_value, repaired, created_at
+FROM xtz_integrity_finding
+WHERE created_at >= $1 AND created_at <= $2
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4;
+`
+	const countQuery = `
+SELECT count(*)
+FROM xtz_integrity_finding
+WHERE created_at >= $1 AND created_at <= $2;
+`
+	var storedFindings []*integrityFinding
+	if err := s.db.Select(&storedFindings, query, fromDate.UTC(), toDate.UTC(), limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	var count uint64
+	if err := database.QueryRowContext(ctx, s.db, countQuery, database.WithArgs(fromDate.UTC(), toDate.UTC()), database.WithDest(&count)); err != nil {
+		return nil, 0, err
+	}
+
+	return toModelIntegrityFindings(storedFindings), count, nil
+}
+
+// AssignAddressChunks makes sure the chunks 0 to numChunks-1 exist in 'xtz_chunk', and assigns the addresses without
+// chunk to one of them by hash of the address. The assignment is stable, addresses are never moved to another chunk.
+func (s *TransactionStorage) AssignAddressChunks(ctx context.Context, numChunks uint64) error {
+	const (
+		createChunks = `
+INSERT INTO xtz_chunk (id, last_processed_time, locked, locked_uuid, locked_until)
+SELECT id, '1970-01-01', false, '', '1970-01-01' FROM generate_series(0, %d) AS id
+ON CONFLICT (id) DO NOTHING;`
+		assignAddresses = `
+UPDATE xtz_addresses SET chunk_id = crc32ieee(address) %% %d WHERE chunk_id IS NULL;`
+	)
+	if numChunks == 0 {
+		return errors.New("number of chunks should be positive")
+	}
+
+	return s.execBatch(ctx, []string{fmt.Sprintf(createChunks, numChunks-1), fmt.Sprintf(assignAddresses, numChunks)})
+}
+
+// LeaseChunk locks the least recently processed chunk that is not locked and was last processed before
+// processedBefore, until the lease expires. It returns false if no chunk is available.
+// The chunk is locked with lockID, that must be given back to ReleaseChunk.
+func (s *TransactionStorage) LeaseChunk(ctx context.Context, lockID string, lease time.Duration, processedBefore time.Time) (uint64, bool, error) {
+	const query = `
+UPDATE xtz_chunk
+SET locked = true, locked_uuid = $1, locked_until = NOW() + $2 * INTERVAL '1 millisecond'
+WHERE id = (
+  SELECT id FROM xtz_chunk
+  WHERE (NOT locked OR locked_until < NOW()) AND last_processed_time < $3
+  ORDER BY last_processed_time
+  LIMIT 1
+) AND (NOT locked OR locked_until < NOW())
+RETURNING id;
+`
+	var ids []uint64
+	if err := s.db.Select(&ids, query, lockID, lease.Milliseconds(), processedBefore.UTC()); err != nil {
+		return 0, false, err
+	}
+
+	if len(ids) == 0 {
+		return 0, false, nil
+	}
+	return ids[0], true, nil
+}
+
+// ReleaseChunk unlocks a chunk leased with lockID, and marks it as processed if processed is set.
+// It does nothing if the lease expired and the chunk was leased again by someone else.
+func (s *TransactionStorage) ReleaseChunk(ctx context.Context, chunkID uint64, lockID string, processed bool) error {
+	const query = `
+UPDATE xtz_chunk
+SET locked = false, locked_until = NOW(), last_processed_time = CASE WHEN $3 THEN NOW() ELSE last_processed_time END
+WHERE id = $1 AND locked_uuid = $2;
+`
+	if _, err := s.db.ExecContext(ctx, query, chunkID, lockID, processed); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetChunkAddresses returns the addresses assigned to the chunk.
+func (s *TransactionStorage) GetChunkAddresses(ctx context.Context, chunkID uint64) ([]string, error) {
+	const query = `
+SELECT address
+FROM xtz_addresses
+WHERE chunk_id = $1;
+`
+	var addresses = []string{}
+	if err := s.db.Select(&addresses, query, chunkID); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// RenewChunk extends the lease of a chunk leased with lockID. It returns false if the lease expired and the chunk was
+// leased again by someone else.
+func (s *TransactionStorage) RenewChunk(ctx context.Context, chunkID uint64, lockID string, lease time.Duration) (bool, error) {
+	const query = `
+UPDATE xtz_chunk
+SET locked_until = NOW() + $3 * INTERVAL '1 millisecond'
+WHERE id = $1 AND locked AND locked_uuid = $2
+RETURNING id;
+`
+	var ids []uint64
+	if err := s.db.Select(&ids, query, chunkID, lockID, lease.Milliseconds()); err != nil {
+		return false, err
+	}
+	return len(ids) > 0, nil
+}
+
+// CreateBalanceBaselines saves the opening balances of the addresses. The baseline of an address is never replaced.
+func (s *TransactionStorage) CreateBalanceBaselines(ctx context.Context, baselines []*model.BalanceBaseline) error {
+	const query = `
+INSERT INTO xtz_balance_baseline (address, block_number, balance, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (address) DO NOTHING;
+`
+	for _, b := range baselines {
+		if _, err := s.db.ExecContext(ctx, query, b.Address, b.BlockNumber, b.Balance.String()); err != nil {
+			return errors.Wrapf(err, "could not create baseline for address %q", b.Address)
+		}
+	}
+	return nil
+}
+
+// GetIndexedBalances returns the balances of the addresses at blockNumber derived from their baseline and their indexed
+// transactions after it: the successful transfers received, minus the successful transfers sent, minus the fees of all
+// the operations sent.
+// The addresses without baseline at or before blockNumber are not returned.
+func (s *TransactionStorage) GetIndexedBalances(ctx context.Context, addresses []string, blockNumber uint64) (map[string]*big.Int, error) {
+	const query = `
+SELECT b.address, b.balance + COALESCE(SUM(d.delta), 0) AS balance
+FROM xtz_balance_baseline AS b
+LEFT JOIN (
+  SELECT addr_to AS address, block_number, COALESCE(amount, 0) AS delta
+  FROM xtz_tx
+  WHERE addr_to = ANY($1) AND status = %[1]d AND block_number >= 0 AND block_number <= $2
+  UNION ALL SELECT addr_from AS address, block_number, -COALESCE(amount, 0) AS delta
+  FROM xtz_tx
+  WHERE addr_from = ANY($1) AND status = %[1]d AND block_number >= 0 AND block_number <= $2
+  UNION ALL SELECT addr_from AS address, block_number, -COALESCE(fee, 0) AS delta
+  FROM xtz_tx
+  WHERE addr_from = ANY($1) AND block_number >= 0 AND block_number <= $2
+) AS d ON d.address = b.address AND d.block_number > b.block_number
+WHERE b.address = ANY($1) AND b.block_number <= $2
+GROUP BY b.address, b.balance;
+`
+	var storedBalances []*indexedBalance
+	if err := s.db.Select(&storedBalances, fmt.Sprintf(query, common_model.SUCCESS), pq.Array(addresses), blockNumber); err != nil {
+		return nil, err
+	}
+
+	var balances = make(map[string]*big.Int, len(storedBalances))
+	for _, b := range storedBalances {
+		balance, ok := new(big.Int).SetString(b.Balance, 10)
+		if !ok {
+			return nil, errors.Errorf("invalid indexed balance %q for address %q", b.Balance, b.Address)
+		}
+		balances[b.Address] = balance
+	}
+	return balances, nil
+}
+
+// CreateBalanceDiscrepancies saves the discrepancies found by the balance reconciliation.
+func (s *TransactionStorage) CreateBalanceDiscrepancies(ctx context.Context, discrepancies []*model.BalanceDiscrepancy) error {
+	const query = `
+INSERT INTO xtz_balance_discrepancy (address, chunk_id, block_number, node_balance, indexed_balance, created_at)
+VALUES ($1, $2, $3, $4, $5, NOW());
+`
+	for _, d := range discrepancies {
+		if _, err := s.db.ExecContext(ctx, query, d.Address, d.ChunkID, d.BlockNumber, d.NodeBalance.String(), d.IndexedBalance.String()); err != nil {
+			return errors.Wrapf(err, "could not create discrepancy for address %q", d.Address)
+		}
+	}
+	return nil
+}
+
+// GetBalanceDiscrepancies returns the discrepancies found by the balance reconciliation between the given dates,
+// most recent first.
+func (s *TransactionStorage) GetBalanceDiscrepancies(ctx context.Context, fromDate, toDate time.Time, limit, offset uint64) ([]*model.BalanceDiscrepancy, uint64, error) {
+	const query = `
+SELECT id, address, chunk_id, block_number, node_balance, indexed_balance, created_at
+FROM xtz_balance_discrepancy
+WHERE created_at >= $1 AND created_at <= $2
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $4;
+`
+	const countQuery = `
+SELECT count(*)
+FROM xtz_balance_discrepancy
+WHERE created_at >= $1 AND created_at <= $2;
+`
+	var storedDiscrepancies []*balanceDiscrepancy
+	if err := s.db.Select(&storedDiscrepancies, query, fromDate.UTC(), toDate.UTC(), limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	var count uint64
+	if err := database.QueryRowContext(ctx, s.db, countQuery, database.WithArgs(fromDate.UTC(), toDate.UTC()), database.WithDest(&count)); err != nil {
+		return nil, 0, err
+	}
+
+	return toModelBalanceDiscrepancies(storedDiscrepancies), count, nil
+}
+
+// GetAddresses returns up to limit indexed addresses after the given one, in lexicographic order.
+func (s *TransactionStorage) GetAddresses(ctx context.Context, afterAddress string, limit uint64) ([]string, error) {
+	const query = `
+SELECT address
+FROM xtz_addresses
+WHERE address > $1
+ORDER BY address
+LIMIT $2;
+`
+	var addresses = []string{}
+	if err := s.db.Select(&addresses, query, afterAddress, limit); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// GetDayEndBlocks returns the stored blocks of the range that are the last of their UTC day, i.e. whose next block
+// is stored and has a timestamp on a later day. The last block of a day missing its next block is not returned.
+func (s *TransactionStorage) GetDayEndBlocks(ctx context.Context, fromBlock, toBlock uint64) ([]*common_model.Block, error) {
+	const query = `
+SELECT b.block_number, b.block_hash, b.block_timestamp
+FROM xtz_block AS b
+JOIN xtz_block AS n ON n.block_number = b.block_number + 1
+WHERE b.block_number >= $1 AND b.block_number <= $2
+	AND date_trunc('day', n.block_timestamp AT TIME ZONE 'UTC') > date_trunc('day', b.block_timestamp AT TIME ZONE 'UTC')
+ORDER BY b.block_number;
+`
+	var storedBlocks []*block
+	if err := s.db.Select(&storedBlocks, query, fromBlock, toBlock); err != nil {
+		return nil, err
+	}
+
+	var blocks = []*common_model.Block{}
+	for _, b := range storedBlocks {
+		blocks = append(blocks, &common_model.Block{Number: b.Number, Hash: b.Hash, Timestamp: b.Timestamp})
+	}
+	return blocks, nil
+}
+
+// GetBlockAtTime returns the last stored block produced at or before the date, provided its next block is stored and
+// was produced after the date. It returns nil otherwise, as the block cannot be told from the stored blocks alone.
+func (s *TransactionStorage) GetBlockAtTime(ctx context.Context, date time.Time) (*common_model.Block, error) {
+	const query = `
+SELECT b.block_number, b.block_hash, b.block_timestamp
+FROM xtz_block AS b
+JOIN xtz_block AS n ON n.block_number = b.block_number + 1
+WHERE b.block_timestamp <= $1 AND n.block_timestamp > $1
+ORDER BY b.block_timestamp DESC, b.block_number DESC
+LIMIT 1;
+`
+	var storedBlocks []*block
+	if err := s.db.Select(&storedBlocks, query, date.UTC()); err != nil {
+		return nil, err
+	}
+
+	if len(storedBlocks) == 0 {
+		return nil, nil
+	}
+	return &common_model.Block{Number: storedBlocks[0].Number, Hash: storedBlocks[0].Hash, Timestamp: storedBlocks[0].Timestamp}, nil
+}
+
+// CreateBalanceSnapshots saves balance snapshots, replacing the existing ones of the same addresses and blocks.
+func (s *TransactionStorage) CreateBalanceSnapshots(ctx context.Context, snapshots []*model.BalanceSnapshot) error {
+	const query = `
+UPSERT INTO xtz_balance_snapshot (address, block_number, boundary, block_timestamp, balance, error, created_at)
+VALUES %s;
+`
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	var (
+		values = make([]string, len(snapshots))
+		args   = make([]interface{}, 0, 6*len(snapshots))
+	)
+	for i, snapshot := range snapshots {
+		var balance *string
+		if snapshot.Balance != nil {
+			b := snapshot.Balance.String()
+			balance = &b
+		}
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, NOW())", 6*i+1, 6*i+2, 6*i+3, 6*i+4, 6*i+5, 6*i+6)
+		args = append(args, snapshot.Address, snapshot.BlockNumber, snapshot.Boundary, snapshot.BlockTimestamp, balance, snapshot.Error)
+	}
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(query, strings.Join(values, ", ")), args...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetBalanceSnapshots returns the balance snapshots of the address between the given dates, by increasing block and boundary.
+// If boundary is set, only the snapshots taken at that boundary are returned.
+func (s *TransactionStorage) GetBalanceSnapshots(ctx context.Context, address, boundary string, fromDate, toDate time.Time) ([]*model.BalanceSnapshot, error) {
+	const query = `
+SELECT address, block_number, boundary, block_timestamp, balance, error, created_at
+FROM xtz_balance_snapshot
+WHERE address = $1 AND block_timestamp >= $2 AND block_timestamp <= $3 AND ($4 = '' OR boundary = $4)
+ORDER BY block_number, boundary;
+`
+	var storedSnapshots []*balanceSnapshot
+	if err := s.db.Select(&storedSnapshots, query, address, fromDate.UTC(), toDate.UTC(), boundary); err != nil {
+		return nil, err
+	}
+
+	return toModelBalanceSnapshots(storedSnapshots), nil
+}
+
+// GetIndexerProgress returns the block checkpointed by the job with the given name, or 0 if there is none.
+func (s *TransactionStorage) GetIndexerProgress(ctx context.Context, name string) (uint64, error) {
+	const query = `
+SELECT block_number
+FROM xtz_indexer_progress
+WHERE name = $1;
+`
+	var blockNumbers []uint64
+	if err := s.db.Select(&blockNumbers, query, name); err != nil {
+		return 0, err
+	}
+
+	if len(blockNumbers) == 0 {
+		return 0, nil
+	}
+	return blockNumbers[0], nil
+}
+
+// SetIndexerProgress checkpoints the block reached by the job with the given name.
+func (s *TransactionStorage) SetIndexerProgress(ctx context.Context, name string, blockNumber uint64) error {
+	const query = `
+UPSERT INTO xtz_indexer_progress (name, block_number, updated_at)
+VALUES ($1, $2, NOW());
+`
+	if _, err := s.db.ExecContext(ctx, query, name, blockNumber); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateWebhook stores a webhook and returns its ID.
+func (s *TransactionStorage) CreateWebhook(ctx context.Context, webhook *model.Webhook) (string, error) {
+	const query = `
+INSERT INTO xtz_webhook (customer_id, url, secret, addresses, confirmations, from_block, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW())
+RETURNING id;
+`
+	var id string
+	if err := database.QueryRowContext(ctx, s.db, query, database.WithArgs(webhook.CustomerID, webhook.URL, webhook.Secret, pq.Array(webhook.Addresses), webhook.Confirmations, webhook.FromBlock), database.WithDest(&id)); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// GetWebhooks returns the active webhooks of the customer.
+func (s *TransactionStorage) GetWebhooks(ctx context.Context, customerID string) ([]*model.Webhook, error) {
+	const query = `
+SELECT id, customer_id, url, secret, addresses, confirmations, from_block, created_at
+FROM xtz_webhook
+WHERE customer_id = $1 AND disabled_at IS NULL
+ORDER BY created_at;
+`
+	var storedWebhooks []*webhook
+	if err := s.db.Select(&storedWebhooks, query, customerID); err != nil {
+		return nil, err
+	}
+	return toModelWebhooks(storedWebhooks), nil
+}
+
+// GetActiveWebhooks returns the active webhooks of all the customers.
+func (s *TransactionStorage) GetActiveWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	const query = `
+SELECT id, customer_id, url, secret, addresses, confirmations, from_block, created_at
+FROM xtz_webhook
+WHERE disabled_at IS NULL;
+`
+	var storedWebhooks []*webhook
+	if err := s.db.Select(&storedWebhooks, query); err != nil {
+		return nil, err
+	}
+	return toModelWebhooks(storedWebhooks), nil
+}
+
+// DeleteWebhook disables a webhook of the customer. Its delivery log is kept.
+func (s *TransactionStorage) DeleteWebhook(ctx context.Context, customerID, id string) error {
+	const query = `
+UPDATE xtz_webhook SET disabled_at = NOW()
+WHERE id = $1 AND customer_id = $2 AND disabled_at IS NULL;
+`
+	if !uuidRegexp.MatchString(id) {
+		return errors.Errorf("invalid webhook id %q", id)
+	}
+
+	res, err := s.db.ExecContext(ctx, query, id, customerID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.Errorf("no webhook %s", id)
+	}
+	return nil
+}
+
+// CreateWebhookDeliveries stores the deliveries of new events, and returns the number of created deliveries.
+// Events already logged for the webhook are ignored.
+func (s *TransactionStorage) CreateWebhookDeliveries(ctx context.Context, deliveries []*model.WebhookDelivery) (uint64, error) {
+	const query = `
+INSERT INTO xtz_webhook_delivery (webhook_id, event_type, event_key, tx_hash, payload, status, attempts, next_attempt_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, 0, NOW(), NOW())
+ON CONFLICT (webhook_id, event_key) DO NOTHING;
+`
+	var created uint64
+	for _, delivery := range deliveries {
+		res, err := s.db.ExecContext(ctx, query, delivery.WebhookID, delivery.EventType, delivery.EventKey, delivery.TransactionHash, delivery.Payload, model.DeliveryPending)
+		if err != nil {
+			return created, errors.Wrapf(err, "could not create delivery %s of webhook %s", delivery.EventKey, delivery.WebhookID)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return created, err
+		}
+		created += uint64(n)
+	}
+	return created, nil
+}
+
+// GetDueWebhookDeliveries returns the pending deliveries of the active webhooks whose next attempt is due, oldest first.
+func (s *TransactionStorage) GetDueWebhookDeliveries(ctx context.Context, limit uint64) ([]*model.WebhookDelivery, error) {
+	const query = `
+SELECT d.id, d.webhook_id, d.event_type, d.event_key, d.tx_hash, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at
+FROM xtz_webhook_delivery AS d
+JOIN xtz_webhook AS w ON w.id = d.webhook_id
+WHERE d.status = $1 AND d.next_attempt_at <= NOW() AND w.disabled_at IS NULL
+ORDER BY d.next_attempt_at
+LIMIT $2;
+`
+	var storedDeliveries []*webhookDelivery
+	if err := s.db.Select(&storedDeliveries, query, model.DeliveryPending, limit); err != nil {
+		return nil, err
+	}
+	return toModelWebhookDeliveries(storedDeliveries), nil
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery attempt.
+func (s *TransactionStorage) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	const query = `
+UPDATE xtz_webhook_delivery SET (status, attempts, next_attempt_at, last_status_code, last_error, delivered_at) = ($2, $3, $4, $5, $6, $7)
+WHERE id = $1;
+`
+	if _, err := s.db.ExecContext(ctx, query, delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError, delivery.DeliveredAt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook of the customer, most recent first.
+func (s *TransactionStorage) GetWebhookDeliveries(ctx context.Context, customerID, webhookID string, limit, offset uint64) ([]*model.WebhookDelivery, uint64, error) {
+	const query = `
+SELECT d.id, d.webhook_id, d.event_type, d.event_key, d.tx_hash, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at
+FROM xtz_webhook_delivery AS d
+JOIN xtz_webhook AS w ON w.id = d.webhook_id
+WHERE d.webhook_id = $1 AND w.customer_id = $2
+ORDER BY d.created_at DESC
+LIMIT $3 OFFSET $4;
+`
+	const countQuery = `
+SELECT count(*)
+FROM xtz_webhook_delivery AS d
+JOIN xtz_webhook AS w ON w.id = d.webhook_id
+WHERE d.webhook_id = $1 AND w.customer_id = $2;
+`
+	if !uuidRegexp.MatchString(webhookID) {
+		return nil, 0, errors.Errorf("invalid webhook id %q", webhookID)
+	}
+
+	var storedDeliveries []*webhookDelivery
+	if err := s.db.Select(&storedDeliveries, query, webhookID, customerID, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	var count uint64
+	if err := database.QueryRowContext(ctx, s.db, countQuery, database.WithArgs(webhookID, customerID), database.WithDest(&count)); err != nil {
+		return nil, 0, err
+	}
+
+	return toModelWebhookDeliveries(storedDeliveries), count, nil
+}
+
+// GetWebhookTransactionHashes returns the given hashes for which an event of the given type was logged for the webhook.
+func (s *TransactionStorage) GetWebhookTransactionHashes(ctx context.Context, webhookID, eventType string, hashes []string) ([]string, error) {
+	const query = `
+SELECT DISTINCT tx_hash
+FROM xtz_webhook_delivery
+WHERE webhook_id = $1 AND event_type = $2 AND tx_hash = ANY($3);
+`
+	if len(hashes) == 0 {
+		return []string{}, nil
+	}
+
+	var res = []string{}
+	if err := s.db.Select(&res, query, webhookID, eventType, pq.Array(hashes)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetCustomerBroadcasts returns the broadcasts of the customer whose status changed after the given change,
+// identified by its date and hash, ordered by change.
+func (s *TransactionStorage) GetCustomerBroadcasts(ctx context.Context, customerID string, since time.Time, sinceHash string, limit uint64) ([]*model.Transaction, error) {
+	const query = `
+SELECT hash, idx, block_number, status, message, broadcasted, customer_id, status_updated_at
+FROM xtz_tx@xtz_tx_customer_id_status_updated_at_hash_idx
+WHERE customer_id = $1 AND broadcasted = true AND idx = 0 AND (status_updated_at, hash) > ($2, $3)
+ORDER BY status_updated_at, hash
+LIMIT $4;
+`
+	var storedTransactions []*transaction
+	if err := s.db.Select(&storedTransactions, query, customerID, since.UTC(), sinceHash, limit); err != nil {
+		return nil, err
+	}
+	return toModelTransactions(storedTransactions), nil
+}
+
+// GetOutboxEvents returns the oldest events of the outbox not published yet, in order of their IDs.
+// The IDs are allocated from the 'xtz_outbox_id_seq' sequence when the events are written, so an event committed
+// after an event with a greater ID is returned after it, once committed: the order holds for the events of a
+// transaction, and for the events of the transactions committed one after the other, not for concurrent ones.
+func (s *TransactionStorage) GetOutboxEvents(ctx context.Context, limit uint64) ([]*model.OutboxEvent, error) {
+	const query = `
+SELECT id, event_type, tx_hash, idx, block_number, addr_from, addr_to, amount, fee, status, previous_status, created_at
+FROM xtz_outbox@xtz_outbox_published_at_id_idx
+WHERE published_at IS NULL
+ORDER BY id
+LIMIT $1;
+`
+	var storedEvents []*outboxEvent
+	if err := s.db.Select(&storedEvents, query, limit); err != nil {
+		return nil, err
+	}
+	return toModelOutboxEvents(storedEvents), nil
+}
+
+// MarkOutboxPublished marks the given events of the outbox as published.
+func (s *TransactionStorage) MarkOutboxPublished(ctx context.Context, ids []uint64) error {
+	const query = `
+UPDATE xtz_outbox SET published_at = NOW()
+WHERE id IN (%s);
+`
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(query, formatNumbers(ids))); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeletePublishedOutboxEvents deletes the events of the outbox published before the given date.
+func (s *TransactionStorage) DeletePublishedOutboxEvents(ctx context.Context, before time.Time) error {
+	const query = `
+DELETE FROM xtz_outbox
+WHERE published_at < $1;
+`
+	if _, err := s.db.ExecContext(ctx, query, before.UTC()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetConfirmationPolicy returns the confirmation policy of the customer, or nil if there is none.
+func (s *TransactionStorage) GetConfirmationPolicy(ctx context.Context, customerID string) (*model.ConfirmationPolicy, error) {
+	const query = `
+SELECT customer_id, confirmations, threshold_amounts, threshold_confirmations, updated_at
+FROM xtz_confirmation_policy
+WHERE customer_id = $1;
+`
+	var storedPolicies []*confirmationPolicy
+	if err := s.db.Select(&storedPolicies, query, customerID); err != nil {
+		return nil, err
+	}
+
+	if len(storedPolicies) == 0 {
+		return nil, nil
+	}
+	return toModelConfirmationPolicy(storedPolicies[0])
+}
+
+// SetConfirmationPolicy creates or replaces the confirmation policy of the customer.
+func (s *TransactionStorage) SetConfirmationPolicy(ctx context.Context, policy *model.ConfirmationPolicy) error {
+	const query = `
+UPSERT INTO xtz_confirmation_policy (customer_id, confirmations, threshold_amounts, threshold_confirmations, updated_at)
+VALUES ($1, $2, $3, $4, NOW());
+`
+	if policy == nil {
+		return errors.New("policy should not be nil")
+	}
+
+	var (
+		amounts       = make([]string, len(policy.Thresholds))
+		confirmations = make([]int64, len(policy.Thresholds))
+	)
+	for i, threshold := range policy.Thresholds {
+		if threshold.MinAmount == nil {
+			return errors.New("threshold amount should not be nil")
+		}
+		amounts[i] = threshold.MinAmount.String()
+		confirmations[i] = int64(threshold.Confirmations)
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, policy.CustomerID, policy.Confirmations, pq.Array(amounts), pq.Array(confirmations)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func formatNumbers(numbers []uint64) string {
+	var args = make([]string, len(numbers))
+	for i, number := range numbers {
+		args[i] = strconv.FormatUint(number, 10)
+	}
+	return strings.Join(args, ",")
+}
+
+func formatHashArray(hashes []string) (string, error) {
+	var args = make([]string, len(hashes))
+	for i, hash := range hashes {
+		if !helper.IsBase64Alphabet(hash) {
+			return "", errors.Errorf("invalid character detected in hash %q", hash)
+		}
+		args[i] = fmt.Sprintf("'%s'", hash)
+	}
+	return fmt.Sprintf("ARRAY[%s]:::STRING[]", strings.Join(args, ",")), nil
+}
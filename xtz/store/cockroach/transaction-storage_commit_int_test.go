@@ -0,0 +1,148 @@
+// +build integration
+
+package cockroach
+
+// Run the integration tests by including the "integration" tag:
+// go test -v -tags integration -timeout 30s github.com/t-dx/tg-blocksd/pkg/xtz/store/cockroach
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/t-dx/tg-blocksd/internal/utils/database"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
+	helper "github.com/t-dx/tg-blocksd/pkg/helper_test"
+	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
+
+	_ "github.com/lib/pq" // CockroachDB uses the Postgres SQL driver.
+
+	"github.com/stretchr/testify/require"
+)
+
+const commitCurrency = "xtz"
+
+func TestCommitBlock(t *testing.T) {
+	var db = helper.Setup(commitCurrency)
+	defer helper.Cleanup(commitCurrency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	block, transactions := commitBlockEntries(560500, 2*commitBlockBatchSize+1)
+
+	_, err := s.CommitBlock(ctx, block, transactions, uint64(len(transactions)))
+	require.Nil(t, err)
+	require.Equal(t, len(transactions), countRows(t, db, "SELECT count(*) FROM xtz_tx WHERE block_number = 560500"))
+	require.Equal(t, 1, countRows(t, db, "SELECT count(*) FROM xtz_block WHERE block_number = 560500"))
+
+	// Committing the same block again is idempotent.
+	_, err = s.CommitBlock(ctx, block, transactions, uint64(len(transactions)))
+	require.Nil(t, err)
+	require.Equal(t, len(transactions), countRows(t, db, "SELECT count(*) FROM xtz_tx WHERE block_number = 560500"))
+	require.Equal(t, 1, countRows(t, db, "SELECT count(*) FROM xtz_block WHERE block_number = 560500"))
+}
+
+func TestCommitBlockInjectedFailure(t *testing.T) {
+	var db = helper.Setup(commitCurrency)
+	defer helper.Cleanup(commitCurrency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	block, transactions := commitBlockEntries(560500, 2*commitBlockBatchSize+1)
+
+	statements, err := commitBlockStatements(block, transactions, uint64(len(transactions)))
+	require.Nil(t, err)
+	// Three statements for the transactions, one for the block.
+	require.Len(t, statements, 4)
+
+	const failure = "SELECT crdb_internal.force_error('XXUUU', 'injected failure');"
+	tests := []struct {
+		name       string
+		statements []string
+	}{
+		{name: "between transaction batches", statements: injectStatement(statements, 1, failure)},
+		{name: "between transactions and block", statements: injectStatement(statements, len(statements)-1, failure)},
+		{name: "after block", statements: injectStatement(statements, len(statements), failure)},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			require.NotNil(t, s.execBatch(ctx, tst.statements))
+
+			// Nothing of the block must have been stored.
+			require.Equal(t, 0, countRows(t, db, "SELECT count(*) FROM xtz_tx"))
+			require.Equal(t, 0, countRows(t, db, "SELECT count(*) FROM xtz_block"))
+		})
+	}
+}
+
+func TestCommitBlockRetry(t *testing.T) {
+	var db = helper.Setup(commitCurrency)
+	defer helper.Cleanup(commitCurrency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	block, transactions := commitBlockEntries(560500, 10)
+
+	statements, err := commitBlockStatements(block, transactions, uint64(len(transactions)))
+	require.Nil(t, err)
+
+	// Sequences are not transactional, so the serialization error is only raised on the first attempt.
+	_, err = db.ExecContext(ctx, "CREATE SEQUENCE IF NOT EXISTS xtz_commit_retry_seq")
+	require.Nil(t, err)
+	defer db.ExecContext(ctx, "DROP SEQUENCE IF EXISTS xtz_commit_retry_seq") //nolint:errcheck
+
+	const retry = "SELECT CASE WHEN nextval('xtz_commit_retry_seq') = 1 THEN crdb_internal.force_error('40001', 'injected retry') ELSE 0 END;"
+	require.Nil(t, s.execBatch(ctx, injectStatement(statements, len(statements)-1, retry)))
+
+	require.Equal(t, len(transactions), countRows(t, db, "SELECT count(*) FROM xtz_tx WHERE block_number = 560500"))
+	require.Equal(t, 1, countRows(t, db, "SELECT count(*) FROM xtz_block WHERE block_number = 560500"))
+}
+
+func commitBlockEntries(blockNumber uint64, n int) (*common_model.Block, []*model.Transaction) {
+	var (
+		now          = time.Now().UTC().Truncate(time.Second)
+		hash         = fmt.Sprintf("BL%049d", blockNumber)
+		previousHash = fmt.Sprintf("BL%049d", blockNumber-1)
+	)
+
+	block := &common_model.Block{Number: blockNumber, Hash: &hash, PreviousHash: &previousHash, Timestamp: &now}
+
+	transactions := make([]*model.Transaction, n)
+	for i := range transactions {
+		transactions[i] = &model.Transaction{
+			Hash:               fmt.Sprintf("oo%049d", i),
+			BlockNumber:        helper.FromUint64(blockNumber),
+			SourceAddress:      helper.FromString("tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"),
+			DestinationAddress: helper.FromString("tz1ihCKcZ8iRxK1NX35u5xXvGRvnDVCvfPu1"),
+			Amount:             big.NewInt(int64(i)),
+			Fee:                big.NewInt(0),
+			Timestamp:          &now,
+			Status:             common_model.SUCCESS.String(),
+		}
+	}
+
+	return block, transactions
+}
+
+func injectStatement(statements []string, at int, statement string) []string {
+	res := append([]string{}, statements[:at]...)
+	res = append(res, statement)
+	return append(res, statements[at:]...)
+}
+
+func countRows(t *testing.T, db database.DB, query string) int {
+	var count int
+	var rows, err = db.QueryContext(context.Background(), query)
+	require.Nil(t, err)
+	defer rows.Close()
+	require.True(t, rows.Next())
+	require.Nil(t, rows.Scan(&count))
+	require.Nil(t, rows.Err())
+	return count
+}
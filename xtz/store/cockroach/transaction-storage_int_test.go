@@ -383,6 +383,74 @@ INSERT INTO xtz_tx (hash, idx, block_number, amount, broadcasted, status, rawtx,
 	require.Equal(t, 5, count)
 }
 
+func TestRollbackBlocks(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	q := `
+INSERT INTO xtz_tx (hash, idx, block_number, amount, broadcasted, status, pinned, timestamp, created_at) VALUES ('op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW', 0, 500000, '10', false, 1, false, NOW(), NOW());
+INSERT INTO xtz_tx (hash, idx, block_number, amount, broadcasted, status, pinned, timestamp, created_at) VALUES ('ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ', 0, 500001, '20', false, 1, false, NOW(), NOW());
+INSERT INTO xtz_tx (hash, idx, block_number, amount, broadcasted, status, pinned, timestamp, created_at) VALUES ('ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ', 1, 500001, '20', false, 1, false, NOW(), NOW());
+INSERT INTO xtz_tx (hash, idx, block_number, amount, broadcasted, status, pinned, timestamp, created_at) VALUES ('op3WBRzqfayJEbv7ApBkTBjHfqxRSoEwrpm16SjMn8wrUXiPjPc', 0, 500002, '30', false, 1, true, NOW(), NOW());
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, created_at) VALUES (500000, 'BLockA0', NOW(), NOW());
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, created_at) VALUES (500001, 'BLockA1', NOW(), NOW());
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, created_at) VALUES (500002, 'BLockA2', NOW(), NOW());
+`
+	_, err := db.ExecContext(ctx, q)
+	require.Nil(t, err)
+
+	hashes, err := s.GetBlocksTransactionHashes(ctx, []uint64{500001, 500002})
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ", "op3WBRzqfayJEbv7ApBkTBjHfqxRSoEwrpm16SjMn8wrUXiPjPc"}, hashes)
+
+	reorg := &model.Reorg{
+		DetectedAtBlock:   500003,
+		ForkBlock:         500000,
+		Depth:             2,
+		OldHashes:         []string{"BLockA1", "BLockA2"},
+		NewHashes:         []string{"BLockB1", "BLockB2"},
+		TransactionHashes: hashes,
+	}
+	require.Nil(t, s.SetIndexerProgress(ctx, "webhook:transfer.indexed:w1", 500003))
+	require.Nil(t, s.SetIndexerProgress(ctx, "webhook:transfer.confirmed:w1", 500001))
+	require.Nil(t, s.SetIndexerProgress(ctx, "verifier", 500003))
+	require.Nil(t, s.RollbackBlocks(ctx, reorg))
+
+	// The transfer checkpoints of the webhooks are moved back to the fork.
+	for name, expected := range map[string]uint64{"webhook:transfer.indexed:w1": 500001, "webhook:transfer.confirmed:w1": 500001, "verifier": 500003} {
+		progress, err := s.GetIndexerProgress(ctx, name)
+		require.Nil(t, err)
+		require.Equal(t, expected, progress, name)
+	}
+
+	var count int
+	err = db.Get(&count, "SELECT count(*) from xtz_tx")
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+
+	err = db.Get(&count, "SELECT count(*) from xtz_block")
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+
+	reorgs, total, err := s.GetReorgs(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), total)
+	require.Len(t, reorgs, 1)
+	require.Equal(t, reorg.DetectedAtBlock, reorgs[0].DetectedAtBlock)
+	require.Equal(t, reorg.ForkBlock, reorgs[0].ForkBlock)
+	require.Equal(t, reorg.Depth, reorgs[0].Depth)
+	require.Equal(t, reorg.OldHashes, reorgs[0].OldHashes)
+	require.Equal(t, reorg.NewHashes, reorgs[0].NewHashes)
+	require.ElementsMatch(t, reorg.TransactionHashes, reorgs[0].TransactionHashes)
+	require.NotNil(t, reorgs[0].CreatedAt)
+
+	// A reorg without block is rejected.
+	require.NotNil(t, s.RollbackBlocks(ctx, &model.Reorg{DetectedAtBlock: 500001, ForkBlock: 500000}))
+}
+
 func nowRounded() *time.Time {
 	t := time.Now().UTC().Round(time.Second)
 	return &t
@@ -531,7 +599,7 @@ func TestIntLBGetBetweenBlocks(t *testing.T) {
 	}
 
 	for _, tst := range tsts {
-		var rep, totalItems, err = s.GetTransactionsBetweenBlocks(ctx, []string{*tst.address}, tst.start, tst.end, 100, 0)
+		var rep, totalItems, err = s.GetTransactionsBetweenBlocks(ctx, []string{*tst.address}, tst.start, tst.end, 100, 0, nil)
 		require.Nil(t, err)
 		require.Equal(t, uint64(tst.expectedNbrTx), totalItems)
 		require.Equal(t, tst.expectedNbrTx, len(rep), fmt.Sprintf("GetBetween date %q and %q", tst.start, tst.end))
@@ -607,7 +675,7 @@ func TestIntLBGetBetweenDates(t *testing.T) {
 	}
 
 	for _, tst := range tsts {
-		var rep, totalItems, err = s.GetTransactionsBetweenDates(ctx, []string{*tst.address}, tst.start, tst.end, 100, 0)
+		var rep, totalItems, err = s.GetTransactionsBetweenDates(ctx, []string{*tst.address}, tst.start, tst.end, 100, 0, nil)
 		require.Nil(t, err)
 		require.Equal(t, uint64(tst.expectedNbrTx), totalItems)
 		require.Equal(t, tst.expectedNbrTx, len(rep), fmt.Sprintf("GetBetween date %q and %q", tst.start, tst.end))
@@ -855,7 +923,7 @@ func TestIntLBUpdateBroadcast(t *testing.T) {
 		newMessage         = helper.FromString("Dummy message")
 		broadcasterAtBlock = uint64(10000)
 	)
-	require.Nil(t, s.UpdateBroadcast(ctx, hash, newStatus, *newMessage, broadcasterAtBlock))
+	require.Nil(t, s.UpdateBroadcast(ctx, hash, newStatus, *newMessage, broadcasterAtBlock, broadcasterAtBlock+10))
 	var txs, err = s.GetTransactions(ctx, []string{hash})
 	require.Nil(t, err)
 	require.Len(t, txs, 1)
@@ -991,7 +1059,7 @@ func TestIntLBGetPendingBroadcasts(t *testing.T) {
 		{beforeBlock: 500010, limitBy: 0, expectedTxN: 0},
 	}
 	for _, test := range tests {
-		var txs, err = s.GetPendingBroadcasts(ctx, test.beforeBlock, test.limitBy)
+		var txs, err = s.GetPendingBroadcasts(ctx, test.beforeBlock, 0, test.limitBy)
 		require.Nil(t, err)
 		require.Len(t, txs, test.expectedTxN)
 	}
@@ -1040,3 +1108,1260 @@ func randomHexString(nbrBytes int) string {
 	rand.Read(b)
 	return fmt.Sprintf("0x%x", b)
 }
+
+func TestIndexerHalt(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+
+	halt, err := s.GetIndexerHalt(ctx)
+	require.Nil(t, err)
+	require.Nil(t, halt)
+
+	id, err := s.CreateIndexerHalt(ctx, &model.IndexerHalt{
+		BlockNumber: 500010,
+		Depth:       11,
+		MaxDepth:    10,
+		StoredHash:  helper.FromString("BLockA9"),
+		ChainHash:   helper.FromString("BLockB9"),
+	})
+	require.Nil(t, err)
+
+	halt, err = s.GetIndexerHalt(ctx)
+	require.Nil(t, err)
+	require.NotNil(t, halt)
+	require.Equal(t, id, halt.ID)
+	require.Equal(t, uint64(500010), halt.BlockNumber)
+	require.Equal(t, uint64(11), halt.Depth)
+	require.Equal(t, uint64(10), halt.MaxDepth)
+	require.Nil(t, halt.Decision)
+
+	require.Nil(t, s.DecideIndexerHalt(ctx, id, model.HaltDecisionAccept, "operator"))
+	// A halt is decided only once.
+	require.NotNil(t, s.DecideIndexerHalt(ctx, id, model.HaltDecisionSwitchNode, "operator"))
+
+	halt, err = s.GetIndexerHalt(ctx)
+	require.Nil(t, err)
+	require.NotNil(t, halt)
+	require.Equal(t, model.HaltDecisionAccept, *halt.Decision)
+	require.Equal(t, "operator", *halt.DecidedBy)
+
+	require.Nil(t, s.CloseIndexerHalt(ctx, id))
+
+	halt, err = s.GetIndexerHalt(ctx)
+	require.Nil(t, err)
+	require.Nil(t, halt)
+}
+
+func TestBackfill(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+
+	id, err := s.CreateBackfill(ctx, &model.Backfill{
+		FromBlock: 100,
+		ToBlock:   103,
+		Shards: []*model.BackfillShard{
+			{Shard: 0, FromBlock: 100, ToBlock: 101},
+			{Shard: 1, FromBlock: 102, ToBlock: 103},
+		},
+	})
+	require.Nil(t, err)
+
+	backfills, err := s.GetPendingBackfills(ctx)
+	require.Nil(t, err)
+	require.Len(t, backfills, 1)
+	require.Equal(t, id, backfills[0].ID)
+	require.Len(t, backfills[0].Shards, 2)
+	require.Equal(t, uint64(102), backfills[0].Shards[1].NextBlock)
+
+	var transactions []*model.Transaction
+	for i := 0; i < 3; i++ {
+		transactions = append(transactions, &model.Transaction{
+			Hash:        fmt.Sprintf("oo%049d", i),
+			BlockNumber: helper.FromUint64(100),
+			Amount:      big.NewInt(int64(i)),
+			Status:      common_model.SUCCESS.String(),
+		})
+	}
+	require.Nil(t, s.CommitBackfillBlock(ctx, id, 0, 100, transactions))
+	require.Nil(t, s.CommitBackfillBlock(ctx, id, 0, 101, nil))
+
+	// The backfilled transactions are stored, but not the block entries.
+	var count int
+	err = db.Get(&count, "SELECT count(*) from xtz_tx WHERE block_number = 100")
+	require.Nil(t, err)
+	require.Equal(t, 3, count)
+
+	err = db.Get(&count, "SELECT count(*) from xtz_block")
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+
+	// The backfill is completed only once all its shards are.
+	require.Nil(t, s.CompleteBackfill(ctx, id))
+	backfill, err := s.GetBackfill(ctx, id)
+	require.Nil(t, err)
+	require.Nil(t, backfill.CompletedAt)
+	require.NotNil(t, backfill.Shards[0].CompletedAt)
+	require.Equal(t, uint64(102), backfill.Shards[0].NextBlock)
+	require.Nil(t, backfill.Shards[1].CompletedAt)
+
+	for blockNumber := uint64(102); blockNumber <= 103; blockNumber++ {
+		require.Nil(t, s.CommitBackfillBlock(ctx, id, 1, blockNumber, nil))
+	}
+	require.Nil(t, s.CompleteBackfill(ctx, id))
+
+	backfill, err = s.GetBackfill(ctx, id)
+	require.Nil(t, err)
+	require.NotNil(t, backfill.CompletedAt)
+
+	backfills, err = s.GetPendingBackfills(ctx)
+	require.Nil(t, err)
+	require.Len(t, backfills, 0)
+}
+
+func TestAddressOnboardings(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+
+	id, err := s.CreateBackfill(ctx, &model.Backfill{
+		FromBlock: 100,
+		ToBlock:   101,
+		Addresses: []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"},
+		Shards:    []*model.BackfillShard{{Shard: 0, FromBlock: 100, ToBlock: 101}},
+	})
+	require.Nil(t, err)
+
+	err = s.CreateAddressOnboardings(ctx, []*model.AddressOnboarding{
+		{Address: "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", SinceBlock: 100, ToBlock: 101, BackfillID: &id},
+		{Address: "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2", SinceBlock: 102, ToBlock: 102},
+	})
+	require.Nil(t, err)
+
+	backfill, err := s.GetBackfill(ctx, id)
+	require.Nil(t, err)
+	require.Equal(t, []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"}, backfill.Addresses)
+
+	onboardings, err := s.GetAddressOnboardings(ctx, []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2", "tz1ihCKcZ8iRxK1NX35u5xXvGRvnDVCvfPu1"})
+	require.Nil(t, err)
+	require.Len(t, onboardings, 2)
+	require.Equal(t, model.OnboardingPending, onboardings[0].Status)
+	// Without backfill, there is nothing to scan.
+	require.Equal(t, model.OnboardingCompleted, onboardings[1].Status)
+
+	require.Nil(t, s.CommitBackfillBlock(ctx, id, 0, 100, nil))
+	onboardings, err = s.GetAddressOnboardings(ctx, []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"})
+	require.Nil(t, err)
+	require.Equal(t, model.OnboardingScanning, onboardings[0].Status)
+	require.Equal(t, uint64(1), onboardings[0].ScannedBlocks)
+
+	require.Nil(t, s.CommitBackfillBlock(ctx, id, 0, 101, nil))
+	require.Nil(t, s.CompleteBackfill(ctx, id))
+	onboardings, err = s.GetAddressOnboardings(ctx, []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"})
+	require.Nil(t, err)
+	require.Equal(t, model.OnboardingCompleted, onboardings[0].Status)
+	require.Equal(t, uint64(2), onboardings[0].ScannedBlocks)
+}
+
+func TestGetAddressesCreatedSince(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	q := `
+INSERT INTO xtz_addresses (address, chunk_id, created_at) VALUES ('tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d', 1, NOW() - INTERVAL '1 hour');
+INSERT INTO xtz_addresses (address, chunk_id, created_at) VALUES ('tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2', 1, NOW());
+INSERT INTO xtz_tx (hash, idx, block_number, amount, broadcasted, status, pinned, timestamp, created_at) VALUES ('op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW', 0, -1, '10', true, 1, false, NOW(), NOW());
+`
+	_, err := db.ExecContext(ctx, q)
+	require.Nil(t, err)
+
+	addresses, last, err := s.GetAddressesCreatedSince(ctx, time.Time{})
+	require.Nil(t, err)
+	require.Equal(t, []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2"}, addresses)
+
+	addresses, _, err = s.GetAddressesCreatedSince(ctx, last)
+	require.Nil(t, err)
+	require.Equal(t, []string{"tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2"}, addresses)
+
+	hashes, err := s.GetBroadcastedHashes(ctx, []string{"op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW", "ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW"}, hashes)
+}
+
+func TestRepairBlock(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	q := `
+INSERT INTO xtz_tx (hash, idx, block_number, amount, broadcasted, status, pinned, timestamp, created_at) VALUES ('op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW', 0, 500000, '10', false, 1, false, NOW(), NOW());
+INSERT INTO xtz_tx (hash, idx, block_number, amount, broadcasted, status, pinned, timestamp, created_at) VALUES ('ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ', 0, 500000, '20', false, 1, true, NOW(), NOW());
+INSERT INTO xtz_tx (hash, idx, block_number, amount, broadcasted, status, pinned, timestamp, created_at) VALUES ('op3WBRzqfayJEbv7ApBkTBjHfqxRSoEwrpm16SjMn8wrUXiPjPc', 0, 500000, '30', true, 1, false, NOW(), NOW());
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, tx_count, created_at) VALUES (500000, 'BLockA0', NOW(), 3, NOW());
+`
+	_, err := db.ExecContext(ctx, q)
+	require.Nil(t, err)
+
+	blocks, err := s.GetStoredBlocks(ctx, 499999, 500001)
+	require.Nil(t, err)
+	require.Len(t, blocks, 1)
+	require.Equal(t, "BLockA0", *blocks[0].Hash)
+	require.Equal(t, uint64(3), *blocks[0].TxCount)
+	require.Equal(t, uint64(3), blocks[0].StoredTxCount)
+
+	// A deleted transaction is not counted.
+	_, err = db.ExecContext(ctx, "DELETE FROM xtz_tx WHERE hash = 'op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW'")
+	require.Nil(t, err)
+	blocks, err = s.GetStoredBlocks(ctx, 500000, 500000)
+	require.Nil(t, err)
+	require.Equal(t, uint64(3), *blocks[0].TxCount)
+	require.Equal(t, uint64(2), blocks[0].StoredTxCount)
+
+	now := time.Now()
+	block := &common_model.Block{Number: 500000, Hash: helper.FromString("BLockB0"), Timestamp: &now}
+	transactions := []*model.Transaction{{
+		Hash:        "ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ",
+		BlockNumber: helper.FromUint64(500000),
+		Amount:      big.NewInt(20),
+		Status:      common_model.SUCCESS.String(),
+	}}
+	_, err = s.RepairBlock(ctx, block, transactions, 4)
+	require.Nil(t, err)
+
+	// The stale transaction is deleted, the pinned and broadcasted ones are kept.
+	stored, err := s.GetTransactions(ctx, []string{"op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW", "ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ", "op3WBRzqfayJEbv7ApBkTBjHfqxRSoEwrpm16SjMn8wrUXiPjPc"})
+	require.Nil(t, err)
+	require.Len(t, stored, 2)
+
+	blocks, err = s.GetStoredBlocks(ctx, 500000, 500000)
+	require.Nil(t, err)
+	require.Equal(t, "BLockB0", *blocks[0].Hash)
+	require.Equal(t, uint64(4), *blocks[0].TxCount)
+	require.Equal(t, uint64(2), blocks[0].StoredTxCount)
+
+	require.Nil(t, s.CreateIntegrityFindings(ctx, []*model.IntegrityFinding{
+		{BlockNumber: 500000, Kind: model.FindingHashMismatch, StoredValue: helper.FromString("BLockA0"), ChainValue: helper.FromString("BLockB0"), Repaired: true},
+	}))
+	findings, total, err := s.GetIntegrityFindings(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), total)
+	require.Equal(t, model.FindingHashMismatch, findings[0].Kind)
+	require.True(t, findings[0].Repaired)
+
+	progress, err := s.GetIndexerProgress(ctx, "verifier")
+	require.Nil(t, err)
+	require.Equal(t, uint64(0), progress)
+	require.Nil(t, s.SetIndexerProgress(ctx, "verifier", 500001))
+	progress, err = s.GetIndexerProgress(ctx, "verifier")
+	require.Nil(t, err)
+	require.Equal(t, uint64(500001), progress)
+}
+
+func TestWebhooks(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	id, err := s.CreateWebhook(ctx, &model.Webhook{CustomerID: "customer", URL: "https://example.com/hooks/xtz", Secret: "0123456789abcdef", Addresses: []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"}, Confirmations: 30, FromBlock: 100})
+	require.Nil(t, err)
+
+	webhooks, err := s.GetWebhooks(ctx, "customer")
+	require.Nil(t, err)
+	require.Len(t, webhooks, 1)
+	require.Equal(t, id, webhooks[0].ID)
+	require.Equal(t, []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"}, webhooks[0].Addresses)
+	require.Equal(t, uint64(100), webhooks[0].FromBlock)
+
+	webhooks, err = s.GetWebhooks(ctx, "other")
+	require.Nil(t, err)
+	require.Len(t, webhooks, 0)
+
+	// Deliveries are logged once per event.
+	deliveries := []*model.WebhookDelivery{
+		{WebhookID: id, EventType: model.EventTransferIndexed, EventKey: "transfer.indexed:op1:0:100", TransactionHash: "op1", Payload: "{}"},
+		{WebhookID: id, EventType: model.EventTransferIndexed, EventKey: "transfer.indexed:op2:0:101", TransactionHash: "op2", Payload: "{}"},
+	}
+	created, err := s.CreateWebhookDeliveries(ctx, deliveries)
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), created)
+	created, err = s.CreateWebhookDeliveries(ctx, deliveries)
+	require.Nil(t, err)
+	require.Equal(t, uint64(0), created)
+
+	hashes, err := s.GetWebhookTransactionHashes(ctx, id, model.EventTransferIndexed, []string{"op2", "op3"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"op2"}, hashes)
+
+	due, err := s.GetDueWebhookDeliveries(ctx, 10)
+	require.Nil(t, err)
+	require.Len(t, due, 2)
+
+	// A delivered event is not due anymore.
+	now := time.Now().UTC()
+	due[0].Status, due[0].Attempts, due[0].DeliveredAt = model.DeliveryDelivered, 1, &now
+	require.Nil(t, s.UpdateWebhookDelivery(ctx, due[0]))
+
+	due, err = s.GetDueWebhookDeliveries(ctx, 10)
+	require.Nil(t, err)
+	require.Len(t, due, 1)
+
+	log, total, err := s.GetWebhookDeliveries(ctx, "customer", id, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), total)
+	require.Len(t, log, 2)
+
+	_, total, err = s.GetWebhookDeliveries(ctx, "other", id, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, uint64(0), total)
+
+	// A deleted webhook keeps its log, but its deliveries are not due anymore.
+	require.NotNil(t, s.DeleteWebhook(ctx, "other", id))
+	require.Nil(t, s.DeleteWebhook(ctx, "customer", id))
+
+	due, err = s.GetDueWebhookDeliveries(ctx, 10)
+	require.Nil(t, err)
+	require.Len(t, due, 0)
+
+	_, total, err = s.GetWebhookDeliveries(ctx, "customer", id, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), total)
+}
+
+func TestGetCustomerBroadcasts(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	since := time.Now().Add(-time.Minute)
+	var broadcastedTransactions = randomBroadcastedEntries(3)
+	for i, tx := range broadcastedTransactions {
+		customerID := "customer"
+		if i == 2 {
+			customerID = "other"
+		}
+		tx.CustomerID = &customerID
+		require.Nil(t, s.Broadcast(ctx, tx))
+	}
+
+	// The broadcasts of the customer are paged by status change.
+	first, err := s.GetCustomerBroadcasts(ctx, "customer", since, "", 1)
+	require.Nil(t, err)
+	require.Len(t, first, 1)
+	require.NotNil(t, first[0].StatusUpdatedAt)
+	second, err := s.GetCustomerBroadcasts(ctx, "customer", *first[0].StatusUpdatedAt, first[0].Hash, 10)
+	require.Nil(t, err)
+	require.Len(t, second, 1)
+	require.ElementsMatch(t, []string{broadcastedTransactions[0].Hash, broadcastedTransactions[1].Hash}, []string{first[0].Hash, second[0].Hash})
+
+	// Nothing changed since the last one.
+	txs, err := s.GetCustomerBroadcasts(ctx, "customer", *second[0].StatusUpdatedAt, second[0].Hash, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 0)
+
+	// A status change is read again, an attempt without status change is not.
+	require.Nil(t, s.UpdateBroadcast(ctx, first[0].Hash, common_model.PENDING.String(), "", 500000, 500001))
+	require.Nil(t, s.UpdateBroadcast(ctx, second[0].Hash, common_model.NEW.String(), "", 500000, 500001))
+	txs, err = s.GetCustomerBroadcasts(ctx, "customer", *second[0].StatusUpdatedAt, second[0].Hash, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, first[0].Hash, txs[0].Hash)
+	require.Equal(t, common_model.PENDING.String(), txs[0].Status)
+}
+func TestOutbox(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var (
+		blockNumber = uint64(500000)
+		from        = "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"
+		to          = "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2"
+	)
+
+	// Indexed transfers, stored again without a new event.
+	var transfers = []*model.Transaction{
+		{Hash: "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW", BlockNumber: &blockNumber, SourceAddress: &from, DestinationAddress: &to, Amount: big.NewInt(10), Status: common_model.SUCCESS.String()},
+	}
+	require.Nil(t, s.CreateTransactions(ctx, transfers))
+	require.Nil(t, s.CreateTransactions(ctx, transfers))
+
+	// Broadcast status changes, the unchanged status is not written.
+	err := s.Broadcast(ctx, &model.Transaction{Hash: "ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ", RawTransaction: &from, Timestamp: &time.Time{}, CreatedAtBlockNumber: &blockNumber})
+	require.Nil(t, err)
+	require.Nil(t, s.UpdateBroadcast(ctx, "ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ", common_model.PENDING.String(), "", blockNumber, 0))
+	require.Nil(t, s.UpdateBroadcast(ctx, "ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ", common_model.PENDING.String(), "", blockNumber+1, 0))
+
+	// GC timeout.
+	require.Nil(t, s.GarbageCollectBroadcasts(ctx, []string{"ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ"}))
+
+	// Deletion by reorg.
+	require.Nil(t, s.RollbackBlocks(ctx, &model.Reorg{DetectedAtBlock: blockNumber + 1, ForkBlock: blockNumber - 1, Depth: 1, OldHashes: []string{"BLockA0"}, NewHashes: []string{"BLockB0"}, TransactionHashes: []string{"op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW"}}))
+
+	events, err := s.GetOutboxEvents(ctx, 10)
+	require.Nil(t, err)
+	require.Len(t, events, 4)
+
+	require.Equal(t, model.EventTransferIndexed, events[0].Type)
+	require.Equal(t, "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW", events[0].TransactionHash)
+	require.Equal(t, blockNumber, *events[0].BlockNumber)
+	require.Equal(t, big.NewInt(10), events[0].Amount)
+
+	require.Equal(t, model.EventBroadcastStatus, events[1].Type)
+	require.Equal(t, common_model.PENDING.String(), events[1].Status)
+	require.Equal(t, common_model.NEW.String(), *events[1].PreviousStatus)
+
+	require.Equal(t, model.EventBroadcastStatus, events[2].Type)
+	require.Equal(t, common_model.TIMEOUT.String(), events[2].Status)
+	require.Equal(t, common_model.PENDING.String(), *events[2].PreviousStatus)
+
+	require.Equal(t, model.EventTransferReorged, events[3].Type)
+	require.Equal(t, "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW", events[3].TransactionHash)
+
+	for i := 1; i < len(events); i++ {
+		require.True(t, events[i-1].ID < events[i].ID)
+	}
+
+	// Published events are not returned anymore, and deleted after the retention.
+	require.Nil(t, s.MarkOutboxPublished(ctx, []uint64{events[0].ID, events[1].ID}))
+
+	events, err = s.GetOutboxEvents(ctx, 10)
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+
+	require.Nil(t, s.DeletePublishedOutboxEvents(ctx, time.Now().Add(time.Minute)))
+
+	var count int
+	err = db.Get(&count, "SELECT count(*) from xtz_outbox")
+	require.Nil(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestConfirmationPolicy(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+
+	policy, err := s.GetConfirmationPolicy(ctx, "customer")
+	require.Nil(t, err)
+	require.Nil(t, policy)
+
+	err = s.SetConfirmationPolicy(ctx, &model.ConfirmationPolicy{
+		CustomerID:    "customer",
+		Confirmations: 2,
+		Thresholds:    []*model.ConfirmationThreshold{{MinAmount: big.NewInt(1000), Confirmations: 10}},
+	})
+	require.Nil(t, err)
+
+	policy, err = s.GetConfirmationPolicy(ctx, "customer")
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), policy.Confirmations)
+	require.Len(t, policy.Thresholds, 1)
+	require.Equal(t, big.NewInt(1000), policy.Thresholds[0].MinAmount)
+	require.Equal(t, uint64(10), policy.Thresholds[0].Confirmations)
+
+	var (
+		address = "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"
+		to      = "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2"
+	)
+	err = s.CreateTransactions(ctx, []*model.Transaction{
+		// Small amount with 2 confirmations at height 100.
+		{Hash: "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW", BlockNumber: helper.FromUint64(99), SourceAddress: &address, DestinationAddress: &to, Amount: big.NewInt(10), Status: common_model.SUCCESS.String(), Timestamp: nowRounded()},
+		// Small amount with 1 confirmation.
+		{Hash: "ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ", BlockNumber: helper.FromUint64(100), SourceAddress: &address, DestinationAddress: &to, Amount: big.NewInt(10), Status: common_model.SUCCESS.String(), Timestamp: nowRounded()},
+		// Large amount with 5 confirmations.
+		{Hash: "onkBhPbJR2cqiHuSn7ox3iypcZHSbRxCrNYCqxvKwUKqqXH5Pud", BlockNumber: helper.FromUint64(96), SourceAddress: &address, DestinationAddress: &to, Amount: big.NewInt(5000), Status: common_model.SUCCESS.String(), Timestamp: nowRounded()},
+		// Large amount with 10 confirmations.
+		{Hash: "oo6rNEbVJXzy3CpMc9iQJDstkbBrf8eFeAgCEdpEGzyx4AkRPmc", BlockNumber: helper.FromUint64(91), SourceAddress: &address, DestinationAddress: &to, Amount: big.NewInt(5000), Status: common_model.SUCCESS.String(), Timestamp: nowRounded()},
+		// Failed transaction with 10 confirmations.
+		{Hash: "opWQeB4LRpgtUrd7VcVZ2AzNWXLBiQb3UNSHPuk2hUeXvW1MxFR", BlockNumber: helper.FromUint64(91), SourceAddress: &address, DestinationAddress: &to, Amount: big.NewInt(10), Status: common_model.FAILURE.String(), Timestamp: nowRounded()},
+	})
+	require.Nil(t, err)
+
+	confirmed := &model.ConfirmedFilter{Policy: policy, Height: 100}
+	transactions, totalItems, err := s.GetTransactionsBetweenBlocks(ctx, []string{address}, 0, 100, 100, 0, confirmed)
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), totalItems)
+
+	hashes := []string{}
+	for _, transaction := range transactions {
+		hashes = append(hashes, transaction.Hash)
+	}
+	require.ElementsMatch(t, []string{"op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW", "oo6rNEbVJXzy3CpMc9iQJDstkbBrf8eFeAgCEdpEGzyx4AkRPmc"}, hashes)
+
+	transactions, totalItems, err = s.GetTransactionsBetweenDates(ctx, []string{address}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 100, 0, confirmed)
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), totalItems)
+	require.Len(t, transactions, 2)
+}
+
+func TestBalanceReconciliation(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var (
+		address = "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d"
+		other   = "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2"
+	)
+	_, err := db.Exec("INSERT INTO xtz_addresses (address) VALUES ($1)", address)
+	require.Nil(t, err)
+
+	// The addresses are assigned to a chunk, that can be leased once until it is released.
+	require.Nil(t, s.AssignAddressChunks(ctx, 1))
+
+	chunkID, ok, err := s.LeaseChunk(ctx, "worker-1", time.Minute, time.Now())
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(0), chunkID)
+
+	_, ok, err = s.LeaseChunk(ctx, "worker-2", time.Minute, time.Now())
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	addresses, err := s.GetChunkAddresses(ctx, chunkID)
+	require.Nil(t, err)
+	require.Equal(t, []string{address}, addresses)
+
+	// The lease can be renewed by its holder only.
+	ok, err = s.RenewChunk(ctx, chunkID, "worker-1", time.Minute)
+	require.Nil(t, err)
+	require.True(t, ok)
+	ok, err = s.RenewChunk(ctx, chunkID, "worker-2", time.Minute)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// A processed chunk is not leased again before the interval.
+	require.Nil(t, s.ReleaseChunk(ctx, chunkID, "worker-1", true))
+	_, ok, err = s.LeaseChunk(ctx, "worker-2", time.Minute, time.Now().Add(-time.Hour))
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// Received 1000, sent 300 with a fee of 10, and a failed transfer of 500 with a fee of 10.
+	err = s.CreateTransactions(ctx, []*model.Transaction{
+		{Hash: "op5AGD3VrzgdzwTk7eNMGYEoQS6Zcsz6PWyYMk5kNvqSumDZReW", BlockNumber: helper.FromUint64(10), SourceAddress: &other, DestinationAddress: &address, Amount: big.NewInt(1000), Fee: big.NewInt(10), Status: common_model.SUCCESS.String()},
+		{Hash: "ooXh2FstoqHnXD9Kqu7CVWtrs8VNVN2u3XyCnked7v38kjKVdyQ", BlockNumber: helper.FromUint64(11), SourceAddress: &address, DestinationAddress: &other, Amount: big.NewInt(300), Fee: big.NewInt(10), Status: common_model.SUCCESS.String()},
+		{Hash: "onkBhPbJR2cqiHuSn7ox3iypcZHSbRxCrNYCqxvKwUKqqXH5Pud", BlockNumber: helper.FromUint64(12), SourceAddress: &address, DestinationAddress: &other, Amount: big.NewInt(500), Fee: big.NewInt(10), Status: common_model.FAILURE.String()},
+		// After the reconciled block.
+		{Hash: "oo6rNEbVJXzy3CpMc9iQJDstkbBrf8eFeAgCEdpEGzyx4AkRPmc", BlockNumber: helper.FromUint64(20), SourceAddress: &other, DestinationAddress: &address, Amount: big.NewInt(1000), Fee: big.NewInt(10), Status: common_model.SUCCESS.String()},
+	})
+	require.Nil(t, err)
+
+	// No baseline yet.
+	balances, err := s.GetIndexedBalances(ctx, []string{address}, 15)
+	require.Nil(t, err)
+	require.Empty(t, balances)
+
+	// The baseline at block 10 includes the transfer received in that block, it is never replaced.
+	require.Nil(t, s.CreateBalanceBaselines(ctx, []*model.BalanceBaseline{{Address: address, BlockNumber: 10, Balance: big.NewInt(5000)}}))
+	require.Nil(t, s.CreateBalanceBaselines(ctx, []*model.BalanceBaseline{{Address: address, BlockNumber: 11, Balance: big.NewInt(1)}}))
+
+	balances, err = s.GetIndexedBalances(ctx, []string{address, "tz1ihCKcZ8iRxK1NX35u5xXvGRvnDVCvfPu1"}, 15)
+	require.Nil(t, err)
+	require.Len(t, balances, 1)
+	require.Equal(t, big.NewInt(4680), balances[address])
+
+	// Before the baseline.
+	balances, err = s.GetIndexedBalances(ctx, []string{address}, 9)
+	require.Nil(t, err)
+	require.Empty(t, balances)
+
+	err = s.CreateBalanceDiscrepancies(ctx, []*model.BalanceDiscrepancy{{Address: address, ChunkID: chunkID, BlockNumber: 15, NodeBalance: big.NewInt(4700), IndexedBalance: big.NewInt(4680)}})
+	require.Nil(t, err)
+
+	discrepancies, totalItems, err := s.GetBalanceDiscrepancies(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), totalItems)
+	require.Equal(t, address, discrepancies[0].Address)
+	require.Equal(t, big.NewInt(4700), discrepancies[0].NodeBalance)
+	require.Equal(t, big.NewInt(4680), discrepancies[0].IndexedBalance)
+}
+
+func TestBalanceSnapshots(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	q := `
+INSERT INTO xtz_addresses (address) VALUES ('tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d'), ('tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2'), ('tz1ihCKcZ8iRxK1NX35u5xXvGRvnDVCvfPu1');
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, created_at) VALUES (500000, 'BLockA0', '2021-03-01T23:59:00Z', NOW());
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, created_at) VALUES (500001, 'BLockA1', '2021-03-01T23:59:30Z', NOW());
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, created_at) VALUES (500002, 'BLockA2', '2021-03-02T00:00:00Z', NOW());
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, created_at) VALUES (500003, 'BLockA3', '2021-03-02T23:59:30Z', NOW());
+`
+	_, err := db.ExecContext(ctx, q)
+	require.Nil(t, err)
+
+	// The last block of 2021-03-02 has no next block yet.
+	blocks, err := s.GetDayEndBlocks(ctx, 500000, 500003)
+	require.Nil(t, err)
+	require.Len(t, blocks, 1)
+	require.Equal(t, uint64(500001), blocks[0].Number)
+
+	addresses, err := s.GetAddresses(ctx, "", 2)
+	require.Nil(t, err)
+	require.Equal(t, []string{"tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2"}, addresses)
+
+	addresses, err = s.GetAddresses(ctx, addresses[1], 2)
+	require.Nil(t, err)
+	require.Equal(t, []string{"tz1ihCKcZ8iRxK1NX35u5xXvGRvnDVCvfPu1"}, addresses)
+
+	var (
+		day1 = time.Date(2021, time.March, 1, 23, 59, 30, 0, time.UTC)
+		day2 = time.Date(2021, time.March, 2, 23, 59, 30, 0, time.UTC)
+	)
+	err = s.CreateBalanceSnapshots(ctx, []*model.BalanceSnapshot{
+		{Address: "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", BlockNumber: 500001, Boundary: model.SnapshotDaily, BlockTimestamp: &day1, Balance: big.NewInt(100)},
+		{Address: "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", BlockNumber: 500003, Boundary: model.SnapshotDaily, BlockTimestamp: &day2, Balance: big.NewInt(150)},
+		{Address: "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2", BlockNumber: 500001, Boundary: model.SnapshotDaily, BlockTimestamp: &day1, Balance: big.NewInt(7)},
+	})
+	require.Nil(t, err)
+
+	// Snapshotting a boundary again replaces its snapshots.
+	err = s.CreateBalanceSnapshots(ctx, []*model.BalanceSnapshot{
+		{Address: "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", BlockNumber: 500003, Boundary: model.SnapshotDaily, BlockTimestamp: &day2, Balance: big.NewInt(120)},
+	})
+	require.Nil(t, err)
+
+	snapshots, err := s.GetBalanceSnapshots(ctx, "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", "", day1.Add(-time.Hour), day2)
+	require.Nil(t, err)
+	require.Len(t, snapshots, 2)
+	require.Equal(t, uint64(500001), snapshots[0].BlockNumber)
+	require.Equal(t, big.NewInt(100), snapshots[0].Balance)
+	require.Equal(t, big.NewInt(120), snapshots[1].Balance)
+
+	snapshots, err = s.GetBalanceSnapshots(ctx, "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", model.SnapshotCycle, day1.Add(-time.Hour), day2)
+	require.Nil(t, err)
+	require.Len(t, snapshots, 0)
+
+	// A cycle snapshot at the same block does not replace the daily one, and a failed balance is recorded.
+	var msg = "node timeout"
+	err = s.CreateBalanceSnapshots(ctx, []*model.BalanceSnapshot{
+		{Address: "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", BlockNumber: 500003, Boundary: model.SnapshotCycle, BlockTimestamp: &day2, Balance: big.NewInt(120)},
+		{Address: "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2", BlockNumber: 500003, Boundary: model.SnapshotCycle, BlockTimestamp: &day2, Error: &msg},
+	})
+	require.Nil(t, err)
+
+	snapshots, err = s.GetBalanceSnapshots(ctx, "tz1SYq214SCBy9naR6cvycQsYcUGpBqQAE8d", "", day1.Add(-time.Hour), day2)
+	require.Nil(t, err)
+	require.Len(t, snapshots, 3)
+	require.Equal(t, model.SnapshotCycle, snapshots[1].Boundary)
+	require.Equal(t, model.SnapshotDaily, snapshots[2].Boundary)
+
+	snapshots, err = s.GetBalanceSnapshots(ctx, "tz1bY8g2N558B2SoyriM5WeGsXSWtaf6qHP2", model.SnapshotCycle, day1.Add(-time.Hour), day2)
+	require.Nil(t, err)
+	require.Len(t, snapshots, 1)
+	require.Nil(t, snapshots[0].Balance)
+	require.Equal(t, &msg, snapshots[0].Error)
+}
+
+func TestGetBlockAtTime(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	q := `
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, created_at) VALUES (500000, 'BLockA0', '2021-03-01T12:00:00Z', NOW());
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, created_at) VALUES (500001, 'BLockA1', '2021-03-01T12:01:00Z', NOW());
+INSERT INTO xtz_block (block_number, block_hash, block_timestamp, created_at) VALUES (500002, 'BLockA2', '2021-03-01T12:02:00Z', NOW());
+`
+	_, err := db.ExecContext(ctx, q)
+	require.Nil(t, err)
+
+	block, err := s.GetBlockAtTime(ctx, time.Date(2021, time.March, 1, 12, 0, 30, 0, time.UTC))
+	require.Nil(t, err)
+	require.NotNil(t, block)
+	require.Equal(t, uint64(500000), block.Number)
+
+	// A block produced at the date is the one at the date.
+	block, err = s.GetBlockAtTime(ctx, time.Date(2021, time.March, 1, 12, 1, 0, 0, time.UTC))
+	require.Nil(t, err)
+	require.NotNil(t, block)
+	require.Equal(t, uint64(500001), block.Number)
+
+	// Before the first stored block.
+	block, err = s.GetBlockAtTime(ctx, time.Date(2021, time.March, 1, 11, 0, 0, 0, time.UTC))
+	require.Nil(t, err)
+	require.Nil(t, block)
+
+	// After the last stored block, a later block may not be stored yet.
+	block, err = s.GetBlockAtTime(ctx, time.Date(2021, time.March, 1, 12, 3, 0, 0, time.UTC))
+	require.Nil(t, err)
+	require.Nil(t, block)
+}
+
+func TestBroadcastAttempts(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var broadcastedTransaction = randomBroadcastedEntries(1)[0]
+	require.Nil(t, s.Broadcast(ctx, broadcastedTransaction))
+	hash := broadcastedTransaction.Hash
+
+	txs, err := s.GetPendingBroadcasts(ctx, 500000, 2, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, uint64(0), txs[0].BroadcastAttempts)
+
+	// The next attempt is not due before its block.
+	require.Nil(t, s.UpdateBroadcast(ctx, hash, common_model.PENDING.String(), "", 500000, 500010))
+	txs, err = s.GetPendingBroadcasts(ctx, 500009, 2, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 0)
+
+	txs, err = s.GetPendingBroadcasts(ctx, 500010, 2, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, uint64(1), txs[0].BroadcastAttempts)
+	require.Equal(t, uint64(500010), txs[0].NextAttemptBlock)
+
+	// No more attempts after the maximum.
+	require.Nil(t, s.UpdateBroadcast(ctx, hash, common_model.PENDING.String(), "", 500010, 500030))
+	txs, err = s.GetPendingBroadcasts(ctx, 500030, 2, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 0)
+
+	txs, err = s.GetPendingBroadcasts(ctx, 500030, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+
+	// Broadcasting the transaction again resets its attempts.
+	require.Nil(t, s.Broadcast(ctx, broadcastedTransaction))
+	txs, err = s.GetPendingBroadcasts(ctx, 500000, 2, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, uint64(0), txs[0].BroadcastAttempts)
+}
+
+func TestExpiredBroadcasts(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var broadcastedTransactions = randomBroadcastedEntries(2)
+	broadcastedTransactions[0].Branch = helper.FromString("BLj9beWDct8x7v83NAv5zViYFWJcYPJiqXehPXudGs83XNCbZB9")
+	broadcastedTransactions[0].ExpiryBlock = helper.FromUint64(500120)
+	for _, tx := range broadcastedTransactions {
+		require.Nil(t, s.Broadcast(ctx, tx))
+	}
+
+	// The broadcast can still be included in the block at its expiry level.
+	hashes, err := s.GetExpiredBroadcasts(ctx, 500119)
+	require.Nil(t, err)
+	require.Len(t, hashes, 0)
+
+	// Once the block at its expiry level is indexed without it, it cannot be included anymore.
+	hashes, err = s.GetExpiredBroadcasts(ctx, 500120)
+	require.Nil(t, err)
+	require.Equal(t, []string{broadcastedTransactions[0].Hash}, hashes)
+
+	// It is not broadcasted again once it cannot be included in the next block.
+	txs, err := s.GetPendingBroadcasts(ctx, 500119, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 2)
+
+	txs, err = s.GetPendingBroadcasts(ctx, 500120, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, broadcastedTransactions[1].Hash, txs[0].Hash)
+
+	txs, err = s.GetTransactions(ctx, []string{broadcastedTransactions[0].Hash})
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, broadcastedTransactions[0].Branch, txs[0].Branch)
+	require.Equal(t, uint64(500120), *txs[0].ExpiryBlock)
+}
+
+func TestReplaceBroadcast(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var broadcastedTransactions = randomBroadcastedEntries(3)
+	broadcastedTransactions[0].CustomerID = helper.FromString("customer")
+	require.Nil(t, s.Broadcast(ctx, broadcastedTransactions[0]))
+
+	tx, err := s.GetBroadcast(ctx, broadcastedTransactions[1].Hash)
+	require.Nil(t, err)
+	require.Nil(t, tx)
+
+	broadcastedTransactions[1].CustomerID = helper.FromString("customer")
+	require.Nil(t, s.ReplaceBroadcast(ctx, broadcastedTransactions[0].Hash, broadcastedTransactions[1]))
+
+	replaced, err := s.GetBroadcast(ctx, broadcastedTransactions[0].Hash)
+	require.Nil(t, err)
+	require.Equal(t, broadcastedTransactions[1].Hash, *replaced.ReplacedBy)
+	require.Nil(t, replaced.Replaces)
+	require.Equal(t, "customer", *replaced.CustomerID)
+
+	replacement, err := s.GetBroadcast(ctx, broadcastedTransactions[1].Hash)
+	require.Nil(t, err)
+	require.Equal(t, broadcastedTransactions[0].Hash, *replacement.Replaces)
+	require.Nil(t, replacement.ReplacedBy)
+	require.Equal(t, broadcastedTransactions[1].RawTransaction, replacement.RawTransaction)
+
+	// Only the replacement is broadcasted.
+	txs, err := s.GetPendingBroadcasts(ctx, 500000, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, broadcastedTransactions[1].Hash, txs[0].Hash)
+
+	// A broadcast is replaced once, the second replacement is not stored.
+	require.NotNil(t, s.ReplaceBroadcast(ctx, broadcastedTransactions[0].Hash, broadcastedTransactions[2]))
+	tx, err = s.GetBroadcast(ctx, broadcastedTransactions[2].Hash)
+	require.Nil(t, err)
+	require.Nil(t, tx)
+
+	require.NotNil(t, s.ReplaceBroadcast(ctx, "invalid'hash", broadcastedTransactions[1]))
+}
+
+func TestBroadcastQueue(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var broadcastedTransactions = randomBroadcastedEntries(4)
+	for i, tx := range broadcastedTransactions[:3] {
+		tx.CustomerID = helper.FromString("customer")
+		tx.BroadcastSource = helper.FromString("tz1bqyMeoid3NKnK1bPtnD1ceb2eSSqAd7Qi")
+		// Broadcasted in the reverse order of their counter.
+		tx.BroadcastCounter = helper.FromUint64(uint64(12 - i))
+		tx.ExpiryBlock = helper.FromUint64(600000)
+	}
+	for _, tx := range broadcastedTransactions {
+		require.Nil(t, s.Broadcast(ctx, tx))
+	}
+
+	// Only the first one of the queue is broadcasted, with the one without source.
+	txs, err := s.GetPendingBroadcasts(ctx, 500000, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 2)
+	require.ElementsMatch(t, []string{broadcastedTransactions[2].Hash, broadcastedTransactions[3].Hash}, []string{txs[0].Hash, txs[1].Hash})
+
+	queues, err := s.GetBroadcastQueues(ctx, "customer", "")
+	require.Nil(t, err)
+	require.Equal(t, []*model.BroadcastQueue{{Source: "tz1bqyMeoid3NKnK1bPtnD1ceb2eSSqAd7Qi", Depth: 3, NextCounter: 10}}, queues)
+
+	queues, err = s.GetBroadcastQueues(ctx, "other", "")
+	require.Nil(t, err)
+	require.Len(t, queues, 0)
+
+	// The next one is broadcasted once its predecessor is included.
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE xtz_tx SET block_number = 500000 WHERE hash = '%s';", broadcastedTransactions[2].Hash))
+	require.Nil(t, err)
+
+	txs, err = s.GetPendingBroadcasts(ctx, 500000, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 2)
+	require.ElementsMatch(t, []string{broadcastedTransactions[1].Hash, broadcastedTransactions[3].Hash}, []string{txs[0].Hash, txs[1].Hash})
+
+	queues, err = s.GetBroadcastQueues(ctx, "customer", "tz1bqyMeoid3NKnK1bPtnD1ceb2eSSqAd7Qi")
+	require.Nil(t, err)
+	require.Equal(t, []*model.BroadcastQueue{{Source: "tz1bqyMeoid3NKnK1bPtnD1ceb2eSSqAd7Qi", Depth: 2, NextCounter: 11}}, queues)
+
+	// A predecessor attempted the maximum number of times still holds back its successor.
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE xtz_tx SET broadcast_attempts = 3 WHERE hash = '%s';", broadcastedTransactions[1].Hash))
+	require.Nil(t, err)
+
+	txs, err = s.GetPendingBroadcasts(ctx, 500000, 3, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, broadcastedTransactions[3].Hash, txs[0].Hash)
+
+	// So does a predecessor whose expiry is unknown.
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE xtz_tx SET broadcast_attempts = 0, expiry_block = NULL WHERE hash = '%s';", broadcastedTransactions[1].Hash))
+	require.Nil(t, err)
+
+	txs, err = s.GetPendingBroadcasts(ctx, 500000, 3, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 2)
+	require.ElementsMatch(t, []string{broadcastedTransactions[1].Hash, broadcastedTransactions[3].Hash}, []string{txs[0].Hash, txs[1].Hash})
+
+	// The successor is returned once its predecessor is timed out.
+	require.Nil(t, s.GarbageCollectBroadcasts(ctx, []string{broadcastedTransactions[1].Hash}))
+
+	txs, err = s.GetPendingBroadcasts(ctx, 500000, 3, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 2)
+	require.ElementsMatch(t, []string{broadcastedTransactions[0].Hash, broadcastedTransactions[3].Hash}, []string{txs[0].Hash, txs[1].Hash})
+}
+
+func TestOperationGroup(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var broadcast = randomBroadcastedEntries(1)[0]
+	broadcast.CustomerID = helper.FromString("customer")
+	require.Nil(t, s.Broadcast(ctx, broadcast))
+
+	txs, err := s.GetOperationGroup(ctx, broadcast.Hash)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.True(t, txs[0].Broadcasted)
+	require.Nil(t, txs[0].BlockNumber)
+	require.Equal(t, "customer", *txs[0].CustomerID)
+
+	// The contents of the operation are included, a reveal and a transaction: the transaction failed and the reveal
+	// is backtracked.
+	var contents = randomEntries(2)
+	for i, tx := range contents {
+		tx.Hash = broadcast.Hash
+		tx.Index = uint64(i)
+		tx.BlockNumber = helper.FromUint64(500000)
+		tx.Status = common_model.FAILURE.String()
+	}
+	contents[0].Kind, contents[0].Amount, contents[0].DestinationAddress = helper.FromString("reveal"), nil, nil
+	contents[0].Message = helper.FromString("operation content backtracked")
+	contents[1].Message = helper.FromString("operation content failed")
+	_, err = s.CommitBlock(ctx, &common_model.Block{Number: 500000, Hash: helper.FromString("BLa")}, contents, 1)
+	require.Nil(t, err)
+
+	txs, err = s.GetOperationGroup(ctx, broadcast.Hash)
+	require.Nil(t, err)
+	require.Len(t, txs, 2)
+	for i, tx := range txs {
+		require.Equal(t, uint64(i), tx.Index)
+		require.Equal(t, uint64(500000), *tx.BlockNumber)
+		require.Equal(t, common_model.FAILURE.String(), tx.Status)
+		require.Equal(t, contents[i].Message, tx.Message)
+		require.Equal(t, contents[i].Kind, tx.Kind)
+		// Every content belongs to the customer of the broadcast.
+		require.True(t, tx.Broadcasted)
+		require.Equal(t, "customer", *tx.CustomerID)
+	}
+
+	// Only the transaction is a transfer.
+	txs, err = s.GetTransactions(ctx, []string{broadcast.Hash})
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, uint64(1), txs[0].Index)
+}
+
+func TestBroadcastHistory(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var hash = randomBroadcastedEntries(1)[0].Hash
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var entries = []*model.BroadcastHistoryEntry{
+		{Hash: hash, Action: "store", Status: common_model.NEW.String(), BlockNumber: 500000, CreatedAt: now},
+		{Hash: hash, Action: "broadcast", Status: common_model.FAILURE.String(), Message: helper.FromString(`could not send transaction: "counter_in_the_past"`), BlockNumber: 500001, CreatedAt: now.Add(time.Minute)},
+		{Hash: hash, Action: "broadcast", Status: common_model.PENDING.String(), BlockNumber: 500003, CreatedAt: now.Add(2 * time.Minute)},
+	}
+	// Inserted out of order, they are returned in chronological order.
+	require.Nil(t, s.InsertBroadcastHistory(ctx, entries[1:]))
+	require.Nil(t, s.InsertBroadcastHistory(ctx, entries[:1]))
+
+	res, err := s.GetBroadcastHistory(ctx, hash)
+	require.Nil(t, err)
+	require.Len(t, res, 3)
+	for i, e := range res {
+		require.Equal(t, entries[i].Action, e.Action)
+		require.Equal(t, entries[i].Status, e.Status)
+		require.Equal(t, entries[i].Message, e.Message)
+		require.Equal(t, entries[i].BlockNumber, e.BlockNumber)
+		require.True(t, entries[i].CreatedAt.Equal(e.CreatedAt))
+	}
+
+	res, err = s.GetBroadcastHistory(ctx, "unknown")
+	require.Nil(t, err)
+	require.Len(t, res, 0)
+
+	// The history of a broadcast that is not stored is garbage collected, the one of a stored broadcast is kept.
+	var broadcast = randomBroadcastedEntries(1)[0]
+	require.Nil(t, s.Broadcast(ctx, broadcast))
+	require.Nil(t, s.InsertBroadcastHistory(ctx, []*model.BroadcastHistoryEntry{{Hash: broadcast.Hash, Action: "store", Status: common_model.NEW.String(), BlockNumber: 500000, CreatedAt: now}}))
+	require.Nil(t, s.GarbageCollectTransactions(ctx, 0))
+
+	res, err = s.GetBroadcastHistory(ctx, hash)
+	require.Nil(t, err)
+	require.Len(t, res, 0)
+	res, err = s.GetBroadcastHistory(ctx, broadcast.Hash)
+	require.Nil(t, err)
+	require.Len(t, res, 1)
+}
+
+func TestCancelBroadcast(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var broadcastedTransactions = randomBroadcastedEntries(2)
+	for _, tx := range broadcastedTransactions {
+		require.Nil(t, s.Broadcast(ctx, tx))
+	}
+
+	require.Nil(t, s.CancelBroadcast(ctx, broadcastedTransactions[0].Hash, "compliance", "withdrawal cancelled"))
+
+	tx, err := s.GetBroadcast(ctx, broadcastedTransactions[0].Hash)
+	require.Nil(t, err)
+	require.Equal(t, model.StatusCancelled, tx.Status)
+	require.Equal(t, "compliance", *tx.CancelledBy)
+	require.Equal(t, "withdrawal cancelled", *tx.CancelReason)
+	require.Equal(t, "cancelled by compliance: withdrawal cancelled", *tx.Message)
+	require.NotNil(t, tx.CancelledAt)
+
+	// It is not broadcasted anymore.
+	txs, err := s.GetPendingBroadcasts(ctx, 500000, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, broadcastedTransactions[1].Hash, txs[0].Hash)
+
+	// An attempt finishing after the cancellation, and the garbage collection, leave it cancelled.
+	require.Nil(t, s.UpdateBroadcast(ctx, broadcastedTransactions[0].Hash, common_model.PENDING.String(), "", 500000, 500010))
+	require.Nil(t, s.GarbageCollectBroadcasts(ctx, []string{broadcastedTransactions[0].Hash}))
+
+	tx, err = s.GetBroadcast(ctx, broadcastedTransactions[0].Hash)
+	require.Nil(t, err)
+	require.Equal(t, model.StatusCancelled, tx.Status)
+	require.Equal(t, uint64(0), tx.BroadcastAttempts)
+
+	// A mined broadcast is not cancelled.
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE xtz_tx SET block_number = 500000, status = %d WHERE hash = '%s';", common_model.SUCCESS, broadcastedTransactions[1].Hash))
+	require.Nil(t, err)
+	require.Nil(t, s.CancelBroadcast(ctx, broadcastedTransactions[1].Hash, "compliance", "withdrawal cancelled"))
+
+	tx, err = s.GetBroadcast(ctx, broadcastedTransactions[1].Hash)
+	require.Nil(t, err)
+	require.Equal(t, common_model.SUCCESS.String(), tx.Status)
+	require.Nil(t, tx.CancelledAt)
+}
+
+func TestGetDependentBroadcasts(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var broadcastedTransactions = randomBroadcastedEntries(4)
+	for i, tx := range broadcastedTransactions {
+		tx.CustomerID = helper.FromString("customer")
+		tx.BroadcastSource = helper.FromString("tz1source")
+		tx.BroadcastCounter = helper.FromUint64(uint64(10 + i))
+	}
+	// The third one is to be broadcasted after the first one, from another source.
+	broadcastedTransactions[2].BroadcastSource, broadcastedTransactions[2].BroadcastCounter = helper.FromString("tz1other"), helper.FromUint64(1)
+	broadcastedTransactions[2].AfterHash = helper.FromString(broadcastedTransactions[0].Hash)
+	// The last one belongs to another customer.
+	broadcastedTransactions[3].CustomerID = helper.FromString("other")
+	for _, tx := range broadcastedTransactions {
+		require.Nil(t, s.Broadcast(ctx, tx))
+	}
+
+	hashes, err := s.GetDependentBroadcasts(ctx, broadcastedTransactions[0].Hash)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{broadcastedTransactions[1].Hash, broadcastedTransactions[2].Hash}, hashes)
+
+	// A cancelled dependent is not pending anymore.
+	require.Nil(t, s.CancelBroadcast(ctx, broadcastedTransactions[1].Hash, "compliance", "withdrawal cancelled"))
+	hashes, err = s.GetDependentBroadcasts(ctx, broadcastedTransactions[0].Hash)
+	require.Nil(t, err)
+	require.Equal(t, []string{broadcastedTransactions[2].Hash}, hashes)
+
+	hashes, err = s.GetDependentBroadcasts(ctx, "unknown")
+	require.Nil(t, err)
+	require.Len(t, hashes, 0)
+}
+
+func TestFailDependentBroadcasts(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var broadcastedTransactions = randomBroadcastedEntries(5)
+	// The second one depends on the first one and the third one on the second one, the fourth one depends on the last one.
+	broadcastedTransactions[1].AfterHash = helper.FromString(broadcastedTransactions[0].Hash)
+	broadcastedTransactions[2].AfterHash = helper.FromString(broadcastedTransactions[1].Hash)
+	broadcastedTransactions[3].AfterHash = helper.FromString(broadcastedTransactions[4].Hash)
+	for _, tx := range broadcastedTransactions {
+		require.Nil(t, s.Broadcast(ctx, tx))
+	}
+
+	// No predecessor failed.
+	txs, err := s.FailDependentBroadcasts(ctx)
+	require.Nil(t, err)
+	require.Len(t, txs, 0)
+
+	// The first one times out and the last one is included, the dependents of the first one fail one after the other.
+	require.Nil(t, s.GarbageCollectBroadcasts(ctx, []string{broadcastedTransactions[0].Hash}))
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE xtz_tx SET block_number = 500000, status = %d WHERE hash = '%s';", common_model.SUCCESS, broadcastedTransactions[4].Hash))
+	require.Nil(t, err)
+
+	for i := 1; i <= 2; i++ {
+		txs, err = s.FailDependentBroadcasts(ctx)
+		require.Nil(t, err)
+		require.Len(t, txs, 1)
+		require.Equal(t, broadcastedTransactions[i].Hash, txs[0].Hash)
+		require.Equal(t, common_model.INVALID.String(), txs[0].Status)
+		require.Equal(t, fmt.Sprintf("predecessor %s failed", broadcastedTransactions[i-1].Hash), *txs[0].Message)
+		require.Equal(t, broadcastedTransactions[i-1].Hash, *txs[0].AfterHash)
+	}
+
+	txs, err = s.FailDependentBroadcasts(ctx)
+	require.Nil(t, err)
+	require.Len(t, txs, 0)
+
+	tx, err := s.GetBroadcast(ctx, broadcastedTransactions[3].Hash)
+	require.Nil(t, err)
+	require.Equal(t, common_model.NEW.String(), tx.Status)
+}
+
+func TestConditionalBroadcasts(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var broadcastedTransactions = randomBroadcastedEntries(4)
+	broadcastedTransactions[0].NotBeforeBlock = helper.FromUint64(500010)
+	notBefore := nowRounded().Add(time.Hour)
+	broadcastedTransactions[1].NotBefore = &notBefore
+	broadcastedTransactions[2].AfterHash = helper.FromString(broadcastedTransactions[3].Hash)
+	for _, tx := range broadcastedTransactions {
+		require.Nil(t, s.Broadcast(ctx, tx))
+	}
+
+	// Only the unconditional one is broadcasted.
+	txs, err := s.GetPendingBroadcasts(ctx, 500000, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, broadcastedTransactions[3].Hash, txs[0].Hash)
+
+	// The scheduled one is broadcasted from its level.
+	txs, err = s.GetPendingBroadcasts(ctx, 500010, 0, 10)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{broadcastedTransactions[0].Hash, broadcastedTransactions[3].Hash}, hashesOf(txs))
+
+	// The conditional one is broadcasted once its predecessor is included successfully.
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE xtz_tx SET block_number = 500000, status = %d WHERE hash = '%s';", common_model.FAILURE, broadcastedTransactions[3].Hash))
+	require.Nil(t, err)
+
+	txs, err = s.GetPendingBroadcasts(ctx, 500000, 0, 10)
+	require.Nil(t, err)
+	require.Len(t, txs, 0)
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE xtz_tx SET status = %d WHERE hash = '%s';", common_model.SUCCESS, broadcastedTransactions[3].Hash))
+	require.Nil(t, err)
+
+	txs, err = s.GetPendingBroadcasts(ctx, 500000, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []string{broadcastedTransactions[2].Hash}, hashesOf(txs))
+
+	tx, err := s.GetBroadcast(ctx, broadcastedTransactions[1].Hash)
+	require.Nil(t, err)
+	require.True(t, broadcastedTransactions[1].NotBefore.Equal(*tx.NotBefore))
+}
+
+func TestReplacedPredecessor(t *testing.T) {
+	var db = helper.Setup(currency)
+	defer helper.Cleanup(currency, db)
+
+	s := NewTransactionStorage(db)
+
+	ctx := context.Background()
+	var broadcastedTransactions = randomBroadcastedEntries(3)
+	for _, tx := range broadcastedTransactions {
+		tx.CustomerID = helper.FromString("customer")
+		tx.BroadcastSource = helper.FromString("tz1source")
+		tx.BroadcastCounter = helper.FromUint64(10)
+	}
+	// The second one replaces the first one, the last one is to be broadcasted after the first one.
+	broadcastedTransactions[2].BroadcastSource, broadcastedTransactions[2].BroadcastCounter = helper.FromString("tz1other"), helper.FromUint64(1)
+	broadcastedTransactions[2].AfterHash = helper.FromString(broadcastedTransactions[0].Hash)
+	require.Nil(t, s.Broadcast(ctx, broadcastedTransactions[0]))
+	require.Nil(t, s.Broadcast(ctx, broadcastedTransactions[2]))
+	require.Nil(t, s.ReplaceBroadcast(ctx, broadcastedTransactions[0].Hash, broadcastedTransactions[1]))
+
+	// The dependent depends on the replacement.
+	hashes, err := s.GetDependentBroadcasts(ctx, broadcastedTransactions[0].Hash)
+	require.Nil(t, err)
+	require.Len(t, hashes, 0)
+	hashes, err = s.GetDependentBroadcasts(ctx, broadcastedTransactions[1].Hash)
+	require.Nil(t, err)
+	require.Equal(t, []string{broadcastedTransactions[2].Hash}, hashes)
+
+	// The replaced one timing out does not fail the dependent while the replacement is pending.
+	require.Nil(t, s.GarbageCollectBroadcasts(ctx, []string{broadcastedTransactions[0].Hash}))
+	txs, err := s.FailDependentBroadcasts(ctx)
+	require.Nil(t, err)
+	require.Len(t, txs, 0)
+
+	// The dependent is broadcasted once the replacement is included successfully.
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE xtz_tx SET block_number = 500000, status = %d WHERE hash = '%s';", common_model.SUCCESS, broadcastedTransactions[1].Hash))
+	require.Nil(t, err)
+
+	txs, err = s.GetPendingBroadcasts(ctx, 500000, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, []string{broadcastedTransactions[2].Hash}, hashesOf(txs))
+	txs, err = s.FailDependentBroadcasts(ctx)
+	require.Nil(t, err)
+	require.Len(t, txs, 0)
+
+	// It fails if the replacement is included but not applied.
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE xtz_tx SET status = %d WHERE hash = '%s';", common_model.FAILURE, broadcastedTransactions[1].Hash))
+	require.Nil(t, err)
+
+	txs, err = s.FailDependentBroadcasts(ctx)
+	require.Nil(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, broadcastedTransactions[2].Hash, txs[0].Hash)
+	require.Equal(t, broadcastedTransactions[0].Hash, *txs[0].AfterHash)
+}
+
+func hashesOf(transactions []*model.Transaction) []string {
+	var hashes = []string{}
+	for _, tx := range transactions {
+		hashes = append(hashes, tx.Hash)
+	}
+	return hashes
+}
@@ -3,9 +3,11 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/t-dx/tg-blocksd/internal/logger"
+	common_model "github.com/t-dx/tg-blocksd/pkg/common/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
 	"github.com/t-dx/tg-blocksd/pkg/xtz/service"
 
@@ -52,6 +54,50 @@ func (mw *storageLogging) CreateTransactions(ctx context.Context, transactions [
 	return nil
 }
 
+func (mw *storageLogging) CommitBlock(ctx context.Context, block *common_model.Block, transactions []*model.Transaction, txCount uint64) (time.Duration, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CommitBlock"), zap.Uint64("block_number", block.Number), zap.Int("num_transactions", len(transactions)), zap.Uint64("tx_count", txCount))
+
+	now := time.Now()
+
+	insertDuration, err := mw.next.CommitBlock(ctx, block, transactions, txCount)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CommitBlock"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return 0, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CommitBlock"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return insertDuration, nil
+}
+
+func (mw *storageLogging) RepairBlock(ctx context.Context, block *common_model.Block, transactions []*model.Transaction, txCount uint64) (time.Duration, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "RepairBlock"), zap.Uint64("block_number", block.Number), zap.Int("num_transactions", len(transactions)), zap.Uint64("tx_count", txCount))
+
+	now := time.Now()
+
+	insertDuration, err := mw.next.RepairBlock(ctx, block, transactions, txCount)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "RepairBlock"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return 0, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "RepairBlock"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return insertDuration, nil
+}
+
 func (mw *storageLogging) GetTransactions(ctx context.Context, hashes []string) ([]*model.Transaction, error) {
 	mw.logger.Debug(ctx, "request started", zap.String("method", "GetTransactions"), zap.Strings("hashes", hashes))
 
@@ -75,12 +121,12 @@ func (mw *storageLogging) GetTransactions(ctx context.Context, hashes []string)
 	return res, nil
 }
 
-func (mw *storageLogging) GetTransactionsBetweenBlocks(ctx context.Context, addresses []string, fromBlock, toBlock uint64, limit, offset uint64) ([]*model.Transaction, uint64, error) {
-	mw.logger.Debug(ctx, "request started", zap.String("method", "GetTransactionsBetweenBlocks"), zap.Strings("addresses", addresses), zap.Uint64("from_block", fromBlock), zap.Uint64("to_block", toBlock), zap.Uint64("limit", limit), zap.Uint64("offset", offset))
+func (mw *storageLogging) GetTransactionsBetweenBlocks(ctx context.Context, addresses []string, fromBlock, toBlock uint64, limit, offset uint64, confirmed *model.ConfirmedFilter) ([]*model.Transaction, uint64, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetTransactionsBetweenBlocks"), zap.Strings("addresses", addresses), zap.Uint64("from_block", fromBlock), zap.Uint64("to_block", toBlock), zap.Uint64("limit", limit), zap.Uint64("offset", offset), zap.String("confirmed", fmt.Sprintf("%+v", confirmed)))
 
 	now := time.Now()
 
-	res, totalItems, err := mw.next.GetTransactionsBetweenBlocks(ctx, addresses, fromBlock, toBlock, limit, offset)
+	res, totalItems, err := mw.next.GetTransactionsBetweenBlocks(ctx, addresses, fromBlock, toBlock, limit, offset, confirmed)
 	if err != nil {
 		mw.logger.Error(ctx, "request failed",
 			zap.String("method", "GetTransactionsBetweenBlocks"),
@@ -103,12 +149,12 @@ func (mw *storageLogging) GetTransactionsBetweenBlocks(ctx context.Context, addr
 	return res, totalItems, nil
 }
 
-func (mw *storageLogging) GetTransactionsBetweenDates(ctx context.Context, addresses []string, fromDate, toDate time.Time, limit, offset uint64) ([]*model.Transaction, uint64, error) {
-	mw.logger.Debug(ctx, "request started", zap.String("method", "GetTransactionsBetweenDates"), zap.Strings("addresses", addresses), zap.Time("from_date", fromDate), zap.Time("to_date", toDate), zap.Uint64("limit", limit), zap.Uint64("offset", offset))
+func (mw *storageLogging) GetTransactionsBetweenDates(ctx context.Context, addresses []string, fromDate, toDate time.Time, limit, offset uint64, confirmed *model.ConfirmedFilter) ([]*model.Transaction, uint64, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetTransactionsBetweenDates"), zap.Strings("addresses", addresses), zap.Time("from_date", fromDate), zap.Time("to_date", toDate), zap.Uint64("limit", limit), zap.Uint64("offset", offset), zap.String("confirmed", fmt.Sprintf("%+v", confirmed)))
 
 	now := time.Now()
 
-	res, totalItems, err := mw.next.GetTransactionsBetweenDates(ctx, addresses, fromDate, toDate, limit, offset)
+	res, totalItems, err := mw.next.GetTransactionsBetweenDates(ctx, addresses, fromDate, toDate, limit, offset, confirmed)
 	if err != nil {
 		mw.logger.Error(ctx, "request failed",
 			zap.String("method", "GetTransactionsBetweenDates"),
@@ -175,12 +221,12 @@ func (mw *storageLogging) Broadcast(ctx context.Context, transaction *model.Tran
 	return nil
 }
 
-func (mw *storageLogging) GetPendingBroadcasts(ctx context.Context, broadcastedBeforeBlock, limit uint64) ([]*model.Transaction, error) {
-	mw.logger.Debug(ctx, "request started", zap.String("method", "GetPendingBroadcasts"), zap.Uint64("before_block", broadcastedBeforeBlock), zap.Uint64("limit", limit))
+func (mw *storageLogging) GetPendingBroadcasts(ctx context.Context, blockNumber, maxAttempts, limit uint64) ([]*model.Transaction, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetPendingBroadcasts"), zap.Uint64("block_number", blockNumber), zap.Uint64("max_attempts", maxAttempts), zap.Uint64("limit", limit))
 
 	now := time.Now()
 
-	res, err := mw.next.GetPendingBroadcasts(ctx, broadcastedBeforeBlock, limit)
+	res, err := mw.next.GetPendingBroadcasts(ctx, blockNumber, maxAttempts, limit)
 	if err != nil {
 		mw.logger.Error(ctx, "request failed",
 			zap.String("method", "GetPendingBroadcasts"),
@@ -202,12 +248,12 @@ func (mw *storageLogging) GetPendingBroadcasts(ctx context.Context, broadcastedB
 	return res, nil
 }
 
-func (mw *storageLogging) UpdateBroadcast(ctx context.Context, hash, status, message string, broadcastedAtBlock uint64) error {
-	mw.logger.Debug(ctx, "request started", zap.String("method", "UpdateBroadcast"), zap.String("hash", hash), zap.String("status", status), zap.String("message", message), zap.Uint64("broadcasted_at_block", broadcastedAtBlock))
+func (mw *storageLogging) UpdateBroadcast(ctx context.Context, hash, status, message string, broadcastedAtBlock, nextAttemptBlock uint64) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "UpdateBroadcast"), zap.String("hash", hash), zap.String("status", status), zap.String("message", message), zap.Uint64("broadcasted_at_block", broadcastedAtBlock), zap.Uint64("next_attempt_block", nextAttemptBlock))
 
 	now := time.Now()
 
-	err := mw.next.UpdateBroadcast(ctx, hash, status, message, broadcastedAtBlock)
+	err := mw.next.UpdateBroadcast(ctx, hash, status, message, broadcastedAtBlock, nextAttemptBlock)
 	if err != nil {
 		mw.logger.Error(ctx, "request failed",
 			zap.String("method", "UpdateBroadcast"),
@@ -246,6 +292,29 @@ func (mw *storageLogging) GetBroadcastsToGarbageCollect(ctx context.Context, bef
 	return res, nil
 }
 
+func (mw *storageLogging) GetExpiredBroadcasts(ctx context.Context, lastBlock uint64) ([]string, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetExpiredBroadcasts"), zap.Uint64("last_block", lastBlock))
+
+	now := time.Now()
+
+	res, err := mw.next.GetExpiredBroadcasts(ctx, lastBlock)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetExpiredBroadcasts"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return nil, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetExpiredBroadcasts"),
+		zap.Strings("result", res),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
 func (mw *storageLogging) GarbageCollectBroadcasts(ctx context.Context, broadcastHashes []string) error {
 	mw.logger.Debug(ctx, "request started", zap.String("method", "GarbageCollectBroadcasts"))
 
@@ -355,3 +424,1344 @@ func (mw *storageLogging) DeleteBlockTransactions(ctx context.Context, blockNumb
 	)
 	return err
 }
+
+func (mw *storageLogging) GetBlocksTransactionHashes(ctx context.Context, blockNumbers []uint64) ([]string, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetBlocksTransactionHashes"), zap.Uint64s("block_numbers", blockNumbers))
+
+	now := time.Now()
+
+	res, err := mw.next.GetBlocksTransactionHashes(ctx, blockNumbers)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBlocksTransactionHashes"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetBlocksTransactionHashes"),
+		zap.Int("num_hashes", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) RollbackBlocks(ctx context.Context, reorg *model.Reorg) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "RollbackBlocks"), zap.String("reorg", fmt.Sprintf("%+v", reorg)))
+
+	now := time.Now()
+
+	err := mw.next.RollbackBlocks(ctx, reorg)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "RollbackBlocks"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "RollbackBlocks"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) GetReorgs(ctx context.Context, fromDate, toDate time.Time, limit, offset uint64) ([]*model.Reorg, uint64, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetReorgs"), zap.Time("from_date", fromDate), zap.Time("to_date", toDate), zap.Uint64("limit", limit), zap.Uint64("offset", offset))
+
+	now := time.Now()
+
+	res, totalItems, err := mw.next.GetReorgs(ctx, fromDate, toDate, limit, offset)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetReorgs"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, totalItems, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetReorgs"),
+		zap.Int("num_reorgs", len(res)),
+		zap.Uint64("total_items", totalItems),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, totalItems, nil
+}
+
+func (mw *storageLogging) CreateIndexerHalt(ctx context.Context, halt *model.IndexerHalt) (string, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CreateIndexerHalt"), zap.Uint64("block_number", halt.BlockNumber), zap.Uint64("depth", halt.Depth))
+
+	now := time.Now()
+
+	res, err := mw.next.CreateIndexerHalt(ctx, halt)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateIndexerHalt"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CreateIndexerHalt"),
+		zap.String("id", res),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetIndexerHalt(ctx context.Context) (*model.IndexerHalt, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetIndexerHalt"))
+
+	now := time.Now()
+
+	res, err := mw.next.GetIndexerHalt(ctx)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetIndexerHalt"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetIndexerHalt"),
+		zap.Bool("halted", res != nil),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) DecideIndexerHalt(ctx context.Context, id, decision, decidedBy string) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "DecideIndexerHalt"), zap.String("id", id), zap.String("decision", decision), zap.String("decided_by", decidedBy))
+
+	now := time.Now()
+
+	err := mw.next.DecideIndexerHalt(ctx, id, decision, decidedBy)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "DecideIndexerHalt"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "DecideIndexerHalt"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) CloseIndexerHalt(ctx context.Context, id string) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CloseIndexerHalt"), zap.String("id", id))
+
+	now := time.Now()
+
+	err := mw.next.CloseIndexerHalt(ctx, id)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CloseIndexerHalt"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CloseIndexerHalt"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) CreateBackfill(ctx context.Context, backfill *model.Backfill) (string, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CreateBackfill"), zap.Uint64("from_block", backfill.FromBlock), zap.Uint64("to_block", backfill.ToBlock), zap.Int("num_shards", len(backfill.Shards)))
+
+	now := time.Now()
+
+	res, err := mw.next.CreateBackfill(ctx, backfill)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateBackfill"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CreateBackfill"),
+		zap.String("id", res),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetBackfill(ctx context.Context, id string) (*model.Backfill, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetBackfill"), zap.String("id", id))
+
+	now := time.Now()
+
+	res, err := mw.next.GetBackfill(ctx, id)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBackfill"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetBackfill"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetPendingBackfills(ctx context.Context) ([]*model.Backfill, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetPendingBackfills"))
+
+	now := time.Now()
+
+	res, err := mw.next.GetPendingBackfills(ctx)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetPendingBackfills"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetPendingBackfills"),
+		zap.Int("num_backfills", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) CommitBackfillBlock(ctx context.Context, backfillID string, shard, blockNumber uint64, transactions []*model.Transaction) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CommitBackfillBlock"), zap.String("backfill_id", backfillID), zap.Uint64("shard", shard), zap.Uint64("block_number", blockNumber), zap.Int("num_transactions", len(transactions)))
+
+	now := time.Now()
+
+	err := mw.next.CommitBackfillBlock(ctx, backfillID, shard, blockNumber, transactions)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CommitBackfillBlock"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CommitBackfillBlock"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) CompleteBackfill(ctx context.Context, id string) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CompleteBackfill"), zap.String("id", id))
+
+	now := time.Now()
+
+	err := mw.next.CompleteBackfill(ctx, id)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CompleteBackfill"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CompleteBackfill"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) CreateAddressOnboardings(ctx context.Context, onboardings []*model.AddressOnboarding) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CreateAddressOnboardings"), zap.Int("num_onboardings", len(onboardings)))
+
+	now := time.Now()
+
+	err := mw.next.CreateAddressOnboardings(ctx, onboardings)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateAddressOnboardings"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CreateAddressOnboardings"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) GetAddressOnboardings(ctx context.Context, addresses []string) ([]*model.AddressOnboarding, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetAddressOnboardings"), zap.Int("num_addresses", len(addresses)))
+
+	now := time.Now()
+
+	res, err := mw.next.GetAddressOnboardings(ctx, addresses)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetAddressOnboardings"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetAddressOnboardings"),
+		zap.Int("num_onboardings", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetAddressesCreatedSince(ctx context.Context, since time.Time) ([]string, time.Time, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetAddressesCreatedSince"), zap.Time("since", since))
+
+	now := time.Now()
+
+	res, last, err := mw.next.GetAddressesCreatedSince(ctx, since)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetAddressesCreatedSince"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, last, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetAddressesCreatedSince"),
+		zap.Int("num_addresses", len(res)),
+		zap.Time("last_created_at", last),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, last, nil
+}
+
+func (mw *storageLogging) GetBroadcastedHashes(ctx context.Context, hashes []string) ([]string, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetBroadcastedHashes"), zap.Int("num_hashes", len(hashes)))
+
+	now := time.Now()
+
+	res, err := mw.next.GetBroadcastedHashes(ctx, hashes)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBroadcastedHashes"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetBroadcastedHashes"),
+		zap.Int("num_broadcasted", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetStoredBlocks(ctx context.Context, fromBlock, toBlock uint64) ([]*model.StoredBlock, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetStoredBlocks"), zap.Uint64("from_block", fromBlock), zap.Uint64("to_block", toBlock))
+
+	now := time.Now()
+
+	res, err := mw.next.GetStoredBlocks(ctx, fromBlock, toBlock)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetStoredBlocks"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetStoredBlocks"),
+		zap.Int("num_blocks", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) CreateIntegrityFindings(ctx context.Context, findings []*model.IntegrityFinding) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CreateIntegrityFindings"), zap.Int("num_findings", len(findings)))
+
+	now := time.Now()
+
+	err := mw.next.CreateIntegrityFindings(ctx, findings)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateIntegrityFindings"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CreateIntegrityFindings"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) GetIntegrityFindings(ctx context.Context, fromDate, toDate time.Time, limit, offset uint64) ([]*model.IntegrityFinding, uint64, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetIntegrityFindings"), zap.Time("from_date", fromDate), zap.Time("to_date", toDate), zap.Uint64("limit", limit), zap.Uint64("offset", offset))
+
+	now := time.Now()
+
+	res, totalItems, err := mw.next.GetIntegrityFindings(ctx, fromDate, toDate, limit, offset)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetIntegrityFindings"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, totalItems, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetIntegrityFindings"),
+		zap.Int("num_findings", len(res)),
+		zap.Uint64("total_items", totalItems),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, totalItems, nil
+}
+
+func (mw *storageLogging) GetIndexerProgress(ctx context.Context, name string) (uint64, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetIndexerProgress"), zap.String("name", name))
+
+	now := time.Now()
+
+	res, err := mw.next.GetIndexerProgress(ctx, name)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetIndexerProgress"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetIndexerProgress"),
+		zap.Uint64("block_number", res),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) SetIndexerProgress(ctx context.Context, name string, blockNumber uint64) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "SetIndexerProgress"), zap.String("name", name), zap.Uint64("block_number", blockNumber))
+
+	now := time.Now()
+
+	err := mw.next.SetIndexerProgress(ctx, name, blockNumber)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "SetIndexerProgress"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "SetIndexerProgress"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) CreateWebhook(ctx context.Context, webhook *model.Webhook) (string, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CreateWebhook"), zap.String("customer_id", webhook.CustomerID), zap.Int("num_addresses", len(webhook.Addresses)))
+
+	now := time.Now()
+
+	res, err := mw.next.CreateWebhook(ctx, webhook)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateWebhook"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CreateWebhook"),
+		zap.String("webhook_id", res),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetWebhooks(ctx context.Context, customerID string) ([]*model.Webhook, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetWebhooks"), zap.String("customer_id", customerID))
+
+	now := time.Now()
+
+	res, err := mw.next.GetWebhooks(ctx, customerID)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetWebhooks"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetWebhooks"),
+		zap.Int("num_webhooks", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetActiveWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetActiveWebhooks"))
+
+	now := time.Now()
+
+	res, err := mw.next.GetActiveWebhooks(ctx)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetActiveWebhooks"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetActiveWebhooks"),
+		zap.Int("num_webhooks", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) DeleteWebhook(ctx context.Context, customerID, id string) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "DeleteWebhook"), zap.String("customer_id", customerID), zap.String("webhook_id", id))
+
+	now := time.Now()
+
+	err := mw.next.DeleteWebhook(ctx, customerID, id)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "DeleteWebhook"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "DeleteWebhook"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) CreateWebhookDeliveries(ctx context.Context, deliveries []*model.WebhookDelivery) (uint64, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CreateWebhookDeliveries"), zap.Int("num_deliveries", len(deliveries)))
+
+	now := time.Now()
+
+	res, err := mw.next.CreateWebhookDeliveries(ctx, deliveries)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateWebhookDeliveries"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CreateWebhookDeliveries"),
+		zap.Uint64("num_created", res),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetDueWebhookDeliveries(ctx context.Context, limit uint64) ([]*model.WebhookDelivery, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetDueWebhookDeliveries"), zap.Uint64("limit", limit))
+
+	now := time.Now()
+
+	res, err := mw.next.GetDueWebhookDeliveries(ctx, limit)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetDueWebhookDeliveries"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetDueWebhookDeliveries"),
+		zap.Int("num_deliveries", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "UpdateWebhookDelivery"), zap.String("delivery_id", delivery.ID), zap.String("status", delivery.Status), zap.Uint64("attempts", delivery.Attempts))
+
+	now := time.Now()
+
+	err := mw.next.UpdateWebhookDelivery(ctx, delivery)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "UpdateWebhookDelivery"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "UpdateWebhookDelivery"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) GetWebhookDeliveries(ctx context.Context, customerID, webhookID string, limit, offset uint64) ([]*model.WebhookDelivery, uint64, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetWebhookDeliveries"), zap.String("customer_id", customerID), zap.String("webhook_id", webhookID), zap.Uint64("limit", limit), zap.Uint64("offset", offset))
+
+	now := time.Now()
+
+	res, totalItems, err := mw.next.GetWebhookDeliveries(ctx, customerID, webhookID, limit, offset)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetWebhookDeliveries"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, totalItems, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetWebhookDeliveries"),
+		zap.Int("num_deliveries", len(res)),
+		zap.Uint64("total_items", totalItems),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, totalItems, nil
+}
+
+func (mw *storageLogging) GetWebhookTransactionHashes(ctx context.Context, webhookID, eventType string, hashes []string) ([]string, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetWebhookTransactionHashes"), zap.String("webhook_id", webhookID), zap.String("event_type", eventType), zap.Int("num_hashes", len(hashes)))
+
+	now := time.Now()
+
+	res, err := mw.next.GetWebhookTransactionHashes(ctx, webhookID, eventType, hashes)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetWebhookTransactionHashes"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetWebhookTransactionHashes"),
+		zap.Int("num_notified", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetCustomerBroadcasts(ctx context.Context, customerID string, since time.Time, sinceHash string, limit uint64) ([]*model.Transaction, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetCustomerBroadcasts"), zap.String("customer_id", customerID), zap.Time("since", since), zap.String("since_hash", sinceHash), zap.Uint64("limit", limit))
+
+	now := time.Now()
+
+	res, err := mw.next.GetCustomerBroadcasts(ctx, customerID, since, sinceHash, limit)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetCustomerBroadcasts"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetCustomerBroadcasts"),
+		zap.Int("num_broadcasts", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetOutboxEvents(ctx context.Context, limit uint64) ([]*model.OutboxEvent, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetOutboxEvents"), zap.Uint64("limit", limit))
+
+	now := time.Now()
+
+	res, err := mw.next.GetOutboxEvents(ctx, limit)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetOutboxEvents"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetOutboxEvents"),
+		zap.Int("num_events", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) MarkOutboxPublished(ctx context.Context, ids []uint64) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "MarkOutboxPublished"), zap.Int("num_events", len(ids)))
+
+	now := time.Now()
+
+	err := mw.next.MarkOutboxPublished(ctx, ids)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "MarkOutboxPublished"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "MarkOutboxPublished"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) DeletePublishedOutboxEvents(ctx context.Context, before time.Time) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "DeletePublishedOutboxEvents"), zap.Time("before", before))
+
+	now := time.Now()
+
+	err := mw.next.DeletePublishedOutboxEvents(ctx, before)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "DeletePublishedOutboxEvents"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "DeletePublishedOutboxEvents"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) GetConfirmationPolicy(ctx context.Context, customerID string) (*model.ConfirmationPolicy, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetConfirmationPolicy"), zap.String("customer_id", customerID))
+
+	now := time.Now()
+
+	res, err := mw.next.GetConfirmationPolicy(ctx, customerID)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetConfirmationPolicy"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetConfirmationPolicy"),
+		zap.String("result", fmt.Sprintf("%+v", res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) SetConfirmationPolicy(ctx context.Context, policy *model.ConfirmationPolicy) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "SetConfirmationPolicy"), zap.String("policy", fmt.Sprintf("%+v", policy)))
+
+	now := time.Now()
+
+	err := mw.next.SetConfirmationPolicy(ctx, policy)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "SetConfirmationPolicy"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "SetConfirmationPolicy"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) AssignAddressChunks(ctx context.Context, numChunks uint64) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "AssignAddressChunks"), zap.Uint64("num_chunks", numChunks))
+
+	now := time.Now()
+
+	err := mw.next.AssignAddressChunks(ctx, numChunks)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "AssignAddressChunks"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "AssignAddressChunks"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) LeaseChunk(ctx context.Context, lockID string, lease time.Duration, processedBefore time.Time) (uint64, bool, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "LeaseChunk"), zap.String("lock_id", lockID), zap.Duration("lease", lease), zap.Time("processed_before", processedBefore))
+
+	now := time.Now()
+
+	chunkID, ok, err := mw.next.LeaseChunk(ctx, lockID, lease, processedBefore)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "LeaseChunk"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return chunkID, ok, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "LeaseChunk"),
+		zap.Uint64("chunk_id", chunkID),
+		zap.Bool("ok", ok),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return chunkID, ok, nil
+}
+
+func (mw *storageLogging) ReleaseChunk(ctx context.Context, chunkID uint64, lockID string, processed bool) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "ReleaseChunk"), zap.Uint64("chunk_id", chunkID), zap.String("lock_id", lockID), zap.Bool("processed", processed))
+
+	now := time.Now()
+
+	err := mw.next.ReleaseChunk(ctx, chunkID, lockID, processed)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "ReleaseChunk"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "ReleaseChunk"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) RenewChunk(ctx context.Context, chunkID uint64, lockID string, lease time.Duration) (bool, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "RenewChunk"), zap.Uint64("chunk_id", chunkID), zap.String("lock_id", lockID), zap.Duration("lease", lease))
+
+	now := time.Now()
+
+	ok, err := mw.next.RenewChunk(ctx, chunkID, lockID, lease)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "RenewChunk"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return ok, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "RenewChunk"),
+		zap.Bool("ok", ok),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return ok, nil
+}
+
+func (mw *storageLogging) GetChunkAddresses(ctx context.Context, chunkID uint64) ([]string, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetChunkAddresses"), zap.Uint64("chunk_id", chunkID))
+
+	now := time.Now()
+
+	res, err := mw.next.GetChunkAddresses(ctx, chunkID)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetChunkAddresses"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetChunkAddresses"),
+		zap.Strings("result", res),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) CreateBalanceBaselines(ctx context.Context, baselines []*model.BalanceBaseline) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CreateBalanceBaselines"), zap.Int("num_baselines", len(baselines)))
+
+	now := time.Now()
+
+	err := mw.next.CreateBalanceBaselines(ctx, baselines)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateBalanceBaselines"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CreateBalanceBaselines"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) GetIndexedBalances(ctx context.Context, addresses []string, blockNumber uint64) (map[string]*big.Int, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetIndexedBalances"), zap.Strings("addresses", addresses), zap.Uint64("block_number", blockNumber))
+
+	now := time.Now()
+
+	res, err := mw.next.GetIndexedBalances(ctx, addresses, blockNumber)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetIndexedBalances"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetIndexedBalances"),
+		zap.String("result", fmt.Sprintf("%v", res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) CreateBalanceDiscrepancies(ctx context.Context, discrepancies []*model.BalanceDiscrepancy) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CreateBalanceDiscrepancies"), zap.Int("num_discrepancies", len(discrepancies)))
+
+	now := time.Now()
+
+	err := mw.next.CreateBalanceDiscrepancies(ctx, discrepancies)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateBalanceDiscrepancies"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CreateBalanceDiscrepancies"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) GetBalanceDiscrepancies(ctx context.Context, fromDate, toDate time.Time, limit, offset uint64) ([]*model.BalanceDiscrepancy, uint64, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetBalanceDiscrepancies"), zap.Time("from_date", fromDate), zap.Time("to_date", toDate), zap.Uint64("limit", limit), zap.Uint64("offset", offset))
+
+	now := time.Now()
+
+	res, totalItems, err := mw.next.GetBalanceDiscrepancies(ctx, fromDate, toDate, limit, offset)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBalanceDiscrepancies"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, totalItems, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetBalanceDiscrepancies"),
+		zap.Int("num_discrepancies", len(res)),
+		zap.Uint64("total_items", totalItems),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, totalItems, nil
+}
+
+func (mw *storageLogging) GetAddresses(ctx context.Context, afterAddress string, limit uint64) ([]string, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetAddresses"), zap.String("after_address", afterAddress), zap.Uint64("limit", limit))
+
+	now := time.Now()
+
+	res, err := mw.next.GetAddresses(ctx, afterAddress, limit)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetAddresses"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetAddresses"),
+		zap.Int("num_addresses", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetDayEndBlocks(ctx context.Context, fromBlock, toBlock uint64) ([]*common_model.Block, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetDayEndBlocks"), zap.Uint64("from_block", fromBlock), zap.Uint64("to_block", toBlock))
+
+	now := time.Now()
+
+	res, err := mw.next.GetDayEndBlocks(ctx, fromBlock, toBlock)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetDayEndBlocks"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetDayEndBlocks"),
+		zap.Int("num_blocks", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) CreateBalanceSnapshots(ctx context.Context, snapshots []*model.BalanceSnapshot) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CreateBalanceSnapshots"), zap.Int("num_snapshots", len(snapshots)))
+
+	now := time.Now()
+
+	err := mw.next.CreateBalanceSnapshots(ctx, snapshots)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CreateBalanceSnapshots"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CreateBalanceSnapshots"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) GetBalanceSnapshots(ctx context.Context, address, boundary string, fromDate, toDate time.Time) ([]*model.BalanceSnapshot, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetBalanceSnapshots"), zap.String("address", address), zap.String("boundary", boundary), zap.Time("from_date", fromDate), zap.Time("to_date", toDate))
+
+	now := time.Now()
+
+	res, err := mw.next.GetBalanceSnapshots(ctx, address, boundary, fromDate, toDate)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBalanceSnapshots"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetBalanceSnapshots"),
+		zap.Int("num_snapshots", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetBlockAtTime(ctx context.Context, date time.Time) (*common_model.Block, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetBlockAtTime"), zap.Time("date", date))
+
+	now := time.Now()
+
+	res, err := mw.next.GetBlockAtTime(ctx, date)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBlockAtTime"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetBlockAtTime"),
+		zap.String("result", fmt.Sprintf("%+v", res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetBroadcast(ctx context.Context, hash string) (*model.Transaction, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetBroadcast"), zap.String("hash", hash))
+
+	now := time.Now()
+
+	res, err := mw.next.GetBroadcast(ctx, hash)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBroadcast"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetBroadcast"),
+		zap.String("result", fmt.Sprintf("%+v", res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) ReplaceBroadcast(ctx context.Context, replacedHash string, transaction *model.Transaction) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "ReplaceBroadcast"), zap.String("replaced_hash", replacedHash))
+
+	now := time.Now()
+
+	err := mw.next.ReplaceBroadcast(ctx, replacedHash, transaction)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "ReplaceBroadcast"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "ReplaceBroadcast"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) GetBroadcastQueues(ctx context.Context, customerID, source string) ([]*model.BroadcastQueue, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetBroadcastQueues"), zap.String("customer_id", customerID), zap.String("source", source))
+
+	now := time.Now()
+
+	res, err := mw.next.GetBroadcastQueues(ctx, customerID, source)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBroadcastQueues"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetBroadcastQueues"),
+		zap.Int("num_queues", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetOperationGroup(ctx context.Context, hash string) ([]*model.Transaction, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetOperationGroup"), zap.String("hash", hash))
+
+	now := time.Now()
+
+	res, err := mw.next.GetOperationGroup(ctx, hash)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetOperationGroup"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetOperationGroup"),
+		zap.Int("num_transactions", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) InsertBroadcastHistory(ctx context.Context, entries []*model.BroadcastHistoryEntry) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "InsertBroadcastHistory"), zap.Int("num_entries", len(entries)))
+
+	now := time.Now()
+
+	err := mw.next.InsertBroadcastHistory(ctx, entries)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "InsertBroadcastHistory"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "InsertBroadcastHistory"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) GetBroadcastHistory(ctx context.Context, hash string) ([]*model.BroadcastHistoryEntry, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetBroadcastHistory"), zap.String("hash", hash))
+
+	now := time.Now()
+
+	res, err := mw.next.GetBroadcastHistory(ctx, hash)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetBroadcastHistory"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetBroadcastHistory"),
+		zap.Int("num_entries", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) CancelBroadcast(ctx context.Context, hash, cancelledBy, reason string) error {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "CancelBroadcast"), zap.String("hash", hash), zap.String("cancelled_by", cancelledBy))
+
+	now := time.Now()
+
+	err := mw.next.CancelBroadcast(ctx, hash, cancelledBy, reason)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "CancelBroadcast"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "CancelBroadcast"),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return nil
+}
+
+func (mw *storageLogging) FailDependentBroadcasts(ctx context.Context) ([]*model.Transaction, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "FailDependentBroadcasts"))
+
+	now := time.Now()
+
+	res, err := mw.next.FailDependentBroadcasts(ctx)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "FailDependentBroadcasts"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "FailDependentBroadcasts"),
+		zap.Int("num_transactions", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
+
+func (mw *storageLogging) GetDependentBroadcasts(ctx context.Context, hash string) ([]string, error) {
+	mw.logger.Debug(ctx, "request started", zap.String("method", "GetDependentBroadcasts"), zap.String("hash", hash))
+
+	now := time.Now()
+
+	res, err := mw.next.GetDependentBroadcasts(ctx, hash)
+	if err != nil {
+		mw.logger.Error(ctx, "request failed",
+			zap.String("method", "GetDependentBroadcasts"),
+			zap.Error(err),
+			zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+		)
+		return res, err
+	}
+
+	mw.logger.Debug(ctx, "request completed",
+		zap.String("method", "GetDependentBroadcasts"),
+		zap.Int("num_hashes", len(res)),
+		zap.Float64("elapsed_ms", float64(time.Since(now).Nanoseconds())/1000000.0),
+	)
+	return res, nil
+}
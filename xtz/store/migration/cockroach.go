@@ -101,6 +101,436 @@ CREATE TABLE IF NOT EXISTS xtz_tx_attributes
 )
 -- +migrate StatementEnd
 
+-- +migrate Down
+`,
+	"2_xtz_reorg_journal": `
+-- +migrate Up
+
+----------------
+-- XTZ reorg journal
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_reorg
+(
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	detected_at_block INT64 NOT NULL,
+	fork_block INT64 NOT NULL,
+	depth INT64 NOT NULL,
+	old_hashes STRING[] NOT NULL,
+	new_hashes STRING[] NOT NULL,
+	tx_hashes STRING[] NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	INDEX xtz_reorg_created_at_idx (created_at)
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"3_xtz_indexer_halt": `
+-- +migrate Up
+
+----------------
+-- XTZ indexer halt
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_indexer_halt
+(
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	block_number INT64 NOT NULL,
+	depth INT64 NOT NULL,
+	max_depth INT64 NOT NULL,
+	stored_hash STRING,
+	chain_hash STRING,
+	decision STRING,
+	decided_by STRING,
+	decided_at TIMESTAMPTZ,
+	closed_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL,
+	INDEX xtz_indexer_halt_closed_at_idx (closed_at)
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"4_xtz_backfill": `
+-- +migrate Up
+
+----------------
+-- XTZ backfill
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_backfill
+(
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	from_block INT64 NOT NULL,
+	to_block INT64 NOT NULL,
+	shards INT NOT NULL,
+	completed_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL,
+	INDEX xtz_backfill_completed_at_idx (completed_at)
+)
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_backfill_shard
+(
+	backfill_id UUID NOT NULL REFERENCES xtz_backfill (id),
+	shard INT NOT NULL,
+	from_block INT64 NOT NULL,
+	to_block INT64 NOT NULL,
+	next_block INT64 NOT NULL,
+	completed_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (backfill_id, shard)
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"5_xtz_address_onboarding": `
+-- +migrate Up
+
+ALTER TABLE xtz_backfill ADD COLUMN IF NOT EXISTS addresses STRING[];
+
+----------------
+-- XTZ address onboarding
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_address_onboarding
+(
+	address STRING PRIMARY KEY,
+	since_block INT64 NOT NULL,
+	to_block INT64 NOT NULL,
+	backfill_id UUID REFERENCES xtz_backfill (id),
+	created_at TIMESTAMPTZ NOT NULL
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"6_xtz_addresses_created_at": `
+-- +migrate Up
+
+ALTER TABLE xtz_addresses ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ DEFAULT now();
+CREATE INDEX IF NOT EXISTS xtz_addresses_created_at_idx ON xtz_addresses (created_at);
+
+-- +migrate Down
+`,
+	"7_xtz_integrity": `
+-- +migrate Up
+
+ALTER TABLE xtz_block ADD COLUMN IF NOT EXISTS tx_count INT64;
+
+----------------
+-- XTZ integrity findings
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_integrity_finding
+(
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	block_number INT64 NOT NULL,
+	kind STRING NOT NULL,
+	stored_value STRING,
+	chain_value STRING,
+	repaired BOOL NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	INDEX xtz_integrity_finding_created_at_idx (created_at)
+)
+-- +migrate StatementEnd
+
+----------------
+-- XTZ indexer progress
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_indexer_progress
+(
+	name STRING PRIMARY KEY,
+	block_number INT64 NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"8_xtz_webhook": `
+-- +migrate Up
+
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS customer_id STRING;
+CREATE INDEX IF NOT EXISTS xtz_tx_customer_id_broadcasted_idx ON xtz_tx (customer_id, broadcasted);
+
+----------------
+-- XTZ webhook
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_webhook
+(
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	customer_id STRING NOT NULL,
+	url STRING NOT NULL,
+	secret STRING NOT NULL,
+	addresses STRING[] NOT NULL,
+	confirmations INT64 NOT NULL,
+	from_block INT64 NOT NULL,
+	disabled_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL,
+	INDEX xtz_webhook_customer_id_idx (customer_id)
+)
+-- +migrate StatementEnd
+
+----------------
+-- XTZ webhook delivery
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_webhook_delivery
+(
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	webhook_id UUID NOT NULL REFERENCES xtz_webhook (id),
+	event_type STRING NOT NULL,
+	event_key STRING NOT NULL,
+	tx_hash STRING NOT NULL,
+	payload STRING NOT NULL,
+	status STRING NOT NULL,
+	attempts INT64 NOT NULL,
+	next_attempt_at TIMESTAMPTZ NOT NULL,
+	last_status_code INT,
+	last_error STRING,
+	delivered_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (webhook_id, event_key),
+	INDEX xtz_webhook_delivery_status_next_attempt_at_idx (status, next_attempt_at),
+	INDEX xtz_webhook_delivery_webhook_id_created_at_idx (webhook_id, created_at),
+	INDEX xtz_webhook_delivery_webhook_id_tx_hash_idx (webhook_id, tx_hash)
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"9_xtz_outbox": `
+-- +migrate Up
+
+----------------
+-- XTZ outbox
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_outbox
+(
+	id INT8 PRIMARY KEY DEFAULT unique_rowid(),
+	event_type STRING NOT NULL,
+	tx_hash STRING NOT NULL,
+	idx INT64 NOT NULL,
+	block_number INT64,
+	addr_from STRING,
+	addr_to STRING,
+	amount DECIMAL(38),
+	fee DECIMAL(38),
+	status INT NOT NULL,
+	previous_status INT,
+	published_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL,
+	INDEX xtz_outbox_published_at_id_idx (published_at, id)
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"10_xtz_confirmation_policy": `
+-- +migrate Up
+
+----------------
+-- XTZ confirmation policy
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_confirmation_policy
+(
+	customer_id STRING PRIMARY KEY,
+	confirmations INT64 NOT NULL,
+	threshold_amounts STRING[] NOT NULL,
+	threshold_confirmations INT8[] NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"11_xtz_balance_discrepancy": `
+-- +migrate Up
+
+----------------
+-- XTZ balance discrepancies
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_balance_discrepancy
+(
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	address STRING NOT NULL,
+	chunk_id INT64 NOT NULL,
+	block_number INT64 NOT NULL,
+	node_balance DECIMAL(38) NOT NULL,
+	indexed_balance DECIMAL(38) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	INDEX xtz_balance_discrepancy_created_at_idx (created_at),
+	INDEX xtz_balance_discrepancy_address_idx (address)
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"12_xtz_balance_snapshot": `
+-- +migrate Up
+
+----------------
+-- XTZ balance snapshots
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_balance_snapshot
+(
+	address STRING NOT NULL,
+	block_number INT64 NOT NULL,
+	boundary STRING NOT NULL,
+	block_timestamp TIMESTAMPTZ NOT NULL,
+	balance DECIMAL(38) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (address, block_number),
+	INDEX xtz_balance_snapshot_address_block_timestamp_idx (address, block_timestamp)
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"13_xtz_block_timestamp_idx": `
+-- +migrate Up
+
+CREATE INDEX IF NOT EXISTS xtz_block_block_timestamp_idx ON xtz_block (block_timestamp);
+
+-- +migrate Down
+`,
+	"14_xtz_tx_broadcast_attempts": `
+-- +migrate Up
+
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS broadcast_attempts INT64 NOT NULL DEFAULT 0;
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS next_attempt_block INT64 NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS xtz_tx_broadcasted_status_block_number_next_attempt_block_idx ON xtz_tx (broadcasted, status, block_number, next_attempt_block);
+
+-- +migrate Down
+`,
+	"15_xtz_tx_expiry": `
+-- +migrate Up
+
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS branch STRING;
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS expiry_block INT64;
+CREATE INDEX IF NOT EXISTS xtz_tx_broadcasted_status_block_number_expiry_block_idx ON xtz_tx (broadcasted, status, block_number, expiry_block);
+
+-- +migrate Down
+`,
+	"16_xtz_tx_replacement": `
+-- +migrate Up
+
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS replaces STRING;
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS replaced_by STRING;
+
+-- +migrate Down
+`,
+	"17_xtz_tx_broadcast_queue": `
+-- +migrate Up
+
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS broadcast_source STRING;
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS broadcast_counter INT64;
+CREATE INDEX IF NOT EXISTS xtz_tx_broadcast_source_broadcast_counter_idx ON xtz_tx (broadcast_source, broadcast_counter);
+
+-- +migrate Down
+`,
+	"18_xtz_broadcast_history": `
+-- +migrate Up
+
+----------------
+-- XTZ broadcast history
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_broadcast_history
+(
+	id INT8 PRIMARY KEY DEFAULT unique_rowid(),
+	tx_hash STRING NOT NULL,
+	action STRING NOT NULL,
+	status INT NOT NULL,
+	message STRING,
+	block_number INT64 NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	INDEX xtz_broadcast_history_tx_hash_id_idx (tx_hash, id)
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"19_xtz_tx_cancellation": `
+-- +migrate Up
+
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS cancelled_by STRING;
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS cancel_reason STRING;
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+
+-- +migrate Down
+`,
+	"20_xtz_tx_broadcast_conditions": `
+-- +migrate Up
+
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS not_before_block INT64;
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ;
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS after_hash STRING;
+
+-- +migrate Down
+`,
+	"21_xtz_tx_status_updated_at": `
+-- +migrate Up
+
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS status_updated_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS xtz_tx_customer_id_status_updated_at_hash_idx ON xtz_tx (customer_id, status_updated_at, hash);
+
+-- +migrate Down
+`,
+	"22_xtz_outbox_id_seq": `
+-- +migrate Up
+
+-- The IDs of the outbox are allocated in order, from after the existing ones.
+CREATE SEQUENCE IF NOT EXISTS xtz_outbox_id_seq;
+SELECT setval('xtz_outbox_id_seq', (SELECT COALESCE(max(id), 0) + 1 FROM xtz_outbox), false);
+ALTER TABLE xtz_outbox ALTER COLUMN id SET DEFAULT nextval('xtz_outbox_id_seq');
+
+-- +migrate Down
+`,
+	"23_xtz_balance_baseline": `
+-- +migrate Up
+
+----------------
+-- XTZ balance baselines
+----------------
+-- +migrate StatementBegin
+CREATE TABLE IF NOT EXISTS xtz_balance_baseline
+(
+	address STRING PRIMARY KEY,
+	block_number INT64 NOT NULL,
+	balance DECIMAL(38) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+)
+-- +migrate StatementEnd
+
+-- +migrate Down
+`,
+	"24_xtz_balance_snapshot_boundary": `
+-- +migrate Up
+
+-- The daily and cycle snapshots of an address can be taken at the same block.
+ALTER TABLE xtz_balance_snapshot ALTER PRIMARY KEY USING COLUMNS (address, block_number, boundary);
+ALTER TABLE xtz_balance_snapshot ALTER COLUMN balance DROP NOT NULL;
+ALTER TABLE xtz_balance_snapshot ADD COLUMN IF NOT EXISTS error STRING;
+
+-- +migrate Down
+`,
+	"25_xtz_tx_kind": `
+-- +migrate Up
+
+-- The contents of a broadcasted operation other than transactions are stored with their kind, transfers have none.
+ALTER TABLE xtz_tx ADD COLUMN IF NOT EXISTS kind STRING;
+
 -- +migrate Down
 `,
 }
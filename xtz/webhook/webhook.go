@@ -0,0 +1,171 @@
+// Package webhook delivers the events of the tezos indexer to the HTTP webhooks of the customers.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
+
+	"github.com/pkg/errors"
+)
+
+// Headers set on each delivery.
+const (
+	// SignatureHeader carries the HMAC-SHA256 signature of the delivery, see Sign.
+	SignatureHeader = "X-Blocksd-Signature"
+	// TimestampHeader carries the unix timestamp of the delivery, part of the signed message.
+	TimestampHeader = "X-Blocksd-Timestamp"
+	EventHeader     = "X-Blocksd-Event"
+	DeliveryHeader  = "X-Blocksd-Delivery"
+)
+
+// Sign returns the signature of a delivery: the hex encoded HMAC-SHA256 with the webhook secret of
+// the timestamp and the payload joined by a dot, prefixed by "sha256=".
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the next attempt of a delivery already attempted the given number of times.
+// It doubles at each attempt from base, up to max.
+func Backoff(attempts uint64, base, max time.Duration) time.Duration {
+	var delay = base
+	for i := uint64(1); i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// CheckURL checks that a webhook URL is an absolute http or https URL whose host is not a loopback, private,
+// link-local or unspecified address. The host names are resolved when sending, see Sender.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Wrap(err, "invalid webhook url")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("webhook url scheme should be http or https, not %q", u.Scheme)
+	}
+	host := u.Hostname()
+	switch {
+	case host == "":
+		return errors.New("webhook url has no host")
+	case strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost"):
+		return errors.Errorf("webhook url host %q is not public", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublic(ip) {
+		return errors.Errorf("webhook url host %q is not public", host)
+	}
+	return nil
+}
+
+// privateNetworks are the networks of the private, shared and unique local addresses.
+var privateNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// isPublic returns whether the IP address is routable on the internet.
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sender posts the deliveries to the webhooks.
+// It only connects to public addresses, so that a webhook host resolving to an internal address is not reached.
+type Sender struct {
+	client *http.Client
+	// allowPrivate disables the check of the addresses, for tests.
+	allowPrivate bool
+}
+
+// NewSender returns a sender whose requests time out after the given duration.
+func NewSender(timeout time.Duration) *Sender {
+	s := &Sender{}
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// Control is called with the resolved address, before connecting.
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); !s.allowPrivate && (ip == nil || !isPublic(ip)) {
+				return errors.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		},
+	}
+	s.client = &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, DialContext: dialer.DialContext},
+		// The redirections are not followed, a webhook responds to the delivery itself.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return s
+}
+
+// Send posts the payload of the delivery to the webhook. It returns the HTTP status code of the response,
+// or 0 if there is none, and an error unless the status code is 2xx.
+func (s *Sender) Send(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	var (
+		payload   = []byte(delivery.Payload)
+		timestamp = time.Now().Unix()
+	)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, errors.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/t-dx/tg-blocksd/pkg/xtz/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Sign(t *testing.T) {
+	var (
+		payload   = []byte(`{"type":"transfer.indexed"}`)
+		signature = Sign("secret", 1600000000, payload)
+	)
+
+	require.Equal(t, "sha256=", signature[:7])
+	require.Len(t, signature, 7+64)
+	require.Equal(t, signature, Sign("secret", 1600000000, payload))
+	require.NotEqual(t, signature, Sign("other secret", 1600000000, payload))
+	require.NotEqual(t, signature, Sign("secret", 1600000001, payload))
+}
+
+func Test_Backoff(t *testing.T) {
+	var tests = []struct {
+		attempts uint64
+		expected time.Duration
+	}{
+		{attempts: 0, expected: time.Minute},
+		{attempts: 1, expected: time.Minute},
+		{attempts: 2, expected: 2 * time.Minute},
+		{attempts: 3, expected: 4 * time.Minute},
+		{attempts: 6, expected: 32 * time.Minute},
+		{attempts: 7, expected: time.Hour},
+		{attempts: 100, expected: time.Hour},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, Backoff(test.attempts, time.Minute, time.Hour), test.attempts)
+	}
+}
+
+func Test_Send(t *testing.T) {
+	var (
+		webhook  = &model.Webhook{Secret: "secret"}
+		delivery = &model.WebhookDelivery{ID: "delivery", EventType: model.EventTransferIndexed, Payload: `{"type":"transfer.indexed"}`}
+		status   = http.StatusOK
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.Nil(t, err)
+		require.Equal(t, delivery.Payload, string(body))
+		require.Equal(t, model.EventTransferIndexed, r.Header.Get(EventHeader))
+		require.Equal(t, "delivery", r.Header.Get(DeliveryHeader))
+
+		timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		require.Nil(t, err)
+		require.Equal(t, Sign("secret", timestamp, body), r.Header.Get(SignatureHeader))
+
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	webhook.URL = server.URL
+
+	sender := NewSender(time.Second)
+
+	// The test server listens on a loopback address.
+	_, err := sender.Send(context.Background(), webhook, delivery)
+	require.NotNil(t, err)
+
+	sender.allowPrivate = true
+	code, err := sender.Send(context.Background(), webhook, delivery)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	status = http.StatusInternalServerError
+	code, err = sender.Send(context.Background(), webhook, delivery)
+	require.NotNil(t, err)
+	require.Equal(t, http.StatusInternalServerError, code)
+}
+
+func Test_CheckURL(t *testing.T) {
+	var tests = []struct {
+		url   string
+		valid bool
+	}{
+		{url: "https://example.com/hooks/xtz", valid: true},
+		{url: "http://93.184.216.34:8080/hooks", valid: true},
+		{url: "ftp://example.com/hooks"},
+		{url: "file:///etc/passwd"},
+		{url: "https:///hooks"},
+		{url: "http://localhost:8080/hooks"},
+		{url: "http://api.localhost/hooks"},
+		{url: "http://127.0.0.1/hooks"},
+		{url: "http://10.0.0.1/hooks"},
+		{url: "http://172.20.0.1/hooks"},
+		{url: "http://192.168.1.1/hooks"},
+		{url: "http://[fd00::1]/hooks"},
+		{url: "http://169.254.169.254/latest/meta-data"},
+		{url: "http://[::1]/hooks"},
+		{url: "http://0.0.0.0/hooks"},
+	}
+
+	for _, test := range tests {
+		err := CheckURL(test.url)
+		if test.valid {
+			require.Nil(t, err, test.url)
+		} else {
+			require.NotNil(t, err, test.url)
+		}
+	}
+}